@@ -0,0 +1,97 @@
+package porcupine
+
+import (
+	"math"
+	"reflect"
+)
+
+// ApproximatelyEqual returns an equality function on states, suitable for
+// use as the Equal function of a [Model] or [NondeterministicModel], that
+// considers floating-point numbers equal if they differ by at most the given
+// tolerance.
+//
+// States are compared like [reflect.DeepEqual], recursing into structs
+// (including unexported fields), arrays, slices, maps, pointers, and
+// interfaces, except that floating-point numbers (and the parts of complex
+// numbers) are compared within the tolerance. This is useful for models with
+// floating-point state, where rounding errors make exact equality
+// over-fragment the set of states; for example, [NondeterministicModel.ToModel]
+// uses Equal to merge states, so with exact equality, the sets of states can
+// grow large.
+//
+// Approximate equality is not transitive, so which states are merged can
+// depend on the order in which they are found, and differences smaller than
+// the tolerance can't be detected, so the tolerance should be small relative
+// to the differences that the model needs to distinguish. Functions and
+// channels are compared by identity, and states must not contain cycles.
+func ApproximatelyEqual(tolerance float64) func(state1, state2 interface{}) bool {
+	return func(state1, state2 interface{}) bool {
+		return approximatelyEqual(reflect.ValueOf(state1), reflect.ValueOf(state2), tolerance)
+	}
+}
+
+func approximatelyEqual(v1, v2 reflect.Value, tolerance float64) bool {
+	if !v1.IsValid() || !v2.IsValid() {
+		return v1.IsValid() == v2.IsValid()
+	}
+	if v1.Type() != v2.Type() {
+		return false
+	}
+	switch v1.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return math.Abs(v1.Float()-v2.Float()) <= tolerance
+	case reflect.Complex64, reflect.Complex128:
+		c1, c2 := v1.Complex(), v2.Complex()
+		return math.Abs(real(c1)-real(c2)) <= tolerance && math.Abs(imag(c1)-imag(c2)) <= tolerance
+	case reflect.Bool:
+		return v1.Bool() == v2.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v1.Int() == v2.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v1.Uint() == v2.Uint()
+	case reflect.String:
+		return v1.String() == v2.String()
+	case reflect.Struct:
+		for i := 0; i < v1.NumField(); i++ {
+			if !approximatelyEqual(v1.Field(i), v2.Field(i), tolerance) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if v1.IsNil() != v2.IsNil() {
+			return false
+		}
+		fallthrough
+	case reflect.Array:
+		if v1.Len() != v2.Len() {
+			return false
+		}
+		for i := 0; i < v1.Len(); i++ {
+			if !approximatelyEqual(v1.Index(i), v2.Index(i), tolerance) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if v1.IsNil() != v2.IsNil() || v1.Len() != v2.Len() {
+			return false
+		}
+		iter := v1.MapRange()
+		for iter.Next() {
+			value2 := v2.MapIndex(iter.Key())
+			if !value2.IsValid() || !approximatelyEqual(iter.Value(), value2, tolerance) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr, reflect.Interface:
+		if v1.IsNil() || v2.IsNil() {
+			return v1.IsNil() == v2.IsNil()
+		}
+		return approximatelyEqual(v1.Elem(), v2.Elem(), tolerance)
+	default:
+		// functions, channels, and unsafe pointers
+		return v1.Pointer() == v2.Pointer()
+	}
+}
@@ -0,0 +1,114 @@
+package porcupine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApproximatelyEqual(t *testing.T) {
+	type state struct {
+		name   string
+		values []float64
+		totals map[string]float64
+		ptr    *float64
+	}
+	a, b := 1.0, 1.0+1e-12
+	eq := ApproximatelyEqual(1e-9)
+	s1 := state{"x", []float64{0.1 + 0.2}, map[string]float64{"k": 0.3}, &a}
+	s2 := state{"x", []float64{0.3}, map[string]float64{"k": 0.1 + 0.2}, &b}
+	if !eq(s1, s2) {
+		t.Fatal("expected states to be approximately equal")
+	}
+	s3 := s2
+	s3.name = "y"
+	if eq(s1, s3) {
+		t.Fatal("expected states with different names not to be equal")
+	}
+	s4 := s2
+	s4.values = []float64{0.31}
+	if eq(s1, s4) {
+		t.Fatal("expected states with different values not to be equal")
+	}
+	s5 := s2
+	s5.totals = map[string]float64{"j": 0.3}
+	if eq(s1, s5) {
+		t.Fatal("expected states with different keys not to be equal")
+	}
+	if eq(1.0, float32(1.0)) {
+		t.Fatal("expected values of different types not to be equal")
+	}
+	if !eq(nil, nil) || eq(nil, 1.0) {
+		t.Fatal("unexpected result comparing nil")
+	}
+}
+
+// a nondeterministic model of a balance, where a deposit may be applied in
+// one step or in ten installments, which are equivalent except for rounding
+// errors
+type depositInput struct {
+	read   bool
+	amount float64
+}
+
+var depositModel = NondeterministicModel{
+	Init: func() []interface{} {
+		return []interface{}{0.0}
+	},
+	Step: func(state, input, output interface{}) []interface{} {
+		st := state.(float64)
+		inp := input.(depositInput)
+		if inp.read {
+			if math.Abs(output.(float64)-st) > 1e-9 {
+				return nil
+			}
+			return []interface{}{st}
+		}
+		installments := st
+		for i := 0; i < 10; i++ {
+			installments += inp.amount / 10
+		}
+		return []interface{}{st + inp.amount, installments}
+	},
+}
+
+func TestApproximatelyEqualNondeterministic(t *testing.T) {
+	amounts := []float64{0.1, 0.7, 0.3, 1.1, 0.9, 0.2}
+	countStates := func(model NondeterministicModel) int {
+		m := model.ToModel()
+		state := m.Init()
+		for _, amount := range amounts {
+			var ok bool
+			ok, state = m.Step(state, depositInput{amount: amount}, nil)
+			if !ok {
+				t.Fatal("expected deposit to be legal")
+			}
+		}
+		return len(state.([]interface{}))
+	}
+	exact := countStates(depositModel)
+	model := depositModel
+	model.Equal = ApproximatelyEqual(1e-9)
+	approximate := countStates(model)
+	t.Logf("%d states with exact equality, %d with approximate equality", exact, approximate)
+	if exact <= 1 {
+		t.Fatalf("expected exact equality to produce multiple states, got %d", exact)
+	}
+	if approximate != 1 {
+		t.Fatalf("expected approximate equality to produce a single state, got %d", approximate)
+	}
+
+	var ops []Operation
+	total := 0.0
+	for i, amount := range amounts {
+		ops = append(ops, Operation{i % 2, depositInput{amount: amount}, int64(2 * i), nil, int64(2*i + 3)})
+		total += amount
+	}
+	ops = append(ops, Operation{2, depositInput{read: true}, 100, total, 101})
+	if !CheckOperations(model.ToModel(), ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+	ops[len(ops)-1].Output = total + 0.1
+	if CheckOperations(model.ToModel(), ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
@@ -0,0 +1,74 @@
+package porcupine
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+const binaryVersion = 1
+
+// binaryMaxPrealloc is the largest number of operations that ReadBinary
+// allocates space for before reading them, so a corrupted header can't make
+// it allocate a huge history.
+const binaryMaxPrealloc = 1 << 16
+
+type binaryHeader struct {
+	Version int
+	Length  int
+}
+
+// WriteBinary writes a history to w in a compact binary format, which can be
+// read back using [ReadBinary]. This is useful for storing large histories.
+//
+// The format is based on [encoding/gob]. The concrete types used for the
+// Input and Output of operations must be registered using [gob.Register],
+// both when writing and when reading the history, and they must be
+// encodable by gob (e.g., structs must have exported fields).
+func WriteBinary(w io.Writer, history []Operation) error {
+	bw := bufio.NewWriter(w)
+	enc := gob.NewEncoder(bw)
+	if err := enc.Encode(binaryHeader{Version: binaryVersion, Length: len(history)}); err != nil {
+		return err
+	}
+	for i := range history {
+		if err := enc.Encode(&history[i]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadBinary reads a history that was written using [WriteBinary]. It
+// returns an error if the history is malformed, e.g., if it is truncated or
+// its header is corrupted, so it can be used on untrusted input.
+func ReadBinary(r io.Reader) ([]Operation, error) {
+	dec := gob.NewDecoder(bufio.NewReader(r))
+	var header binaryHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Version != binaryVersion {
+		return nil, fmt.Errorf("unsupported binary history version %d", header.Version)
+	}
+	if header.Length < 0 {
+		return nil, fmt.Errorf("invalid binary history length %d", header.Length)
+	}
+	prealloc := header.Length
+	if prealloc > binaryMaxPrealloc {
+		prealloc = binaryMaxPrealloc
+	}
+	history := make([]Operation, 0, prealloc)
+	for i := 0; i < header.Length; i++ {
+		var op Operation
+		if err := dec.Decode(&op); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("reading operation %d of %d: %w", i, header.Length, err)
+		}
+		history = append(history, op)
+	}
+	return history, nil
+}
@@ -0,0 +1,104 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func init() {
+	gob.Register(LogInput{})
+	gob.Register(LogOutput{})
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	var history []Operation
+	for i := 0; i < 1000; i++ {
+		history = append(history,
+			Operation{i % 10, LogInput{Op: LogAppend, Topic: "a", Value: i}, int64(2 * i), LogOutput{Offset: i}, int64(2*i + 1)},
+			Operation{i % 10, LogInput{Op: LogRead, Topic: "a", Offset: i}, int64(2*i + 1), LogOutput{Value: i, Exists: true}, int64(2*i + 3)},
+		)
+	}
+	var b bytes.Buffer
+	if err := WriteBinary(&b, history); err != nil {
+		t.Fatalf("failed to write history: %v", err)
+	}
+	size := b.Len()
+	read, err := ReadBinary(&b)
+	if err != nil {
+		t.Fatalf("failed to read history: %v", err)
+	}
+	if !reflect.DeepEqual(history, read) {
+		t.Fatal("history changed in round trip")
+	}
+	if !CheckOperations(LogModel(), read) {
+		t.Fatal("expected operations to be linearizable")
+	}
+
+	jsonData, err := json.Marshal(history)
+	if err != nil {
+		t.Fatalf("failed to marshal history: %v", err)
+	}
+	t.Logf("binary: %d bytes, JSON: %d bytes", size, len(jsonData))
+	if size >= len(jsonData) {
+		t.Fatalf("expected binary (%d bytes) to be smaller than JSON (%d bytes)", size, len(jsonData))
+	}
+}
+
+func TestBinaryEmpty(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteBinary(&b, nil); err != nil {
+		t.Fatalf("failed to write history: %v", err)
+	}
+	read, err := ReadBinary(&b)
+	if err != nil {
+		t.Fatalf("failed to read history: %v", err)
+	}
+	if len(read) != 0 {
+		t.Fatalf("expected empty history, got %v", read)
+	}
+	if _, err := ReadBinary(&b); err == nil {
+		t.Fatal("expected error reading from empty input")
+	}
+}
+
+func TestBinaryCorrupted(t *testing.T) {
+	history := []Operation{
+		{0, LogInput{Op: LogAppend, Topic: "a", Value: 1}, 0, LogOutput{Offset: 0}, 10},
+		{1, LogInput{Op: LogReadLatest, Topic: "a"}, 20, LogOutput{Value: 1, Exists: true}, 30},
+	}
+	withHeader := func(header binaryHeader) []byte {
+		var b bytes.Buffer
+		enc := gob.NewEncoder(&b)
+		if err := enc.Encode(header); err != nil {
+			t.Fatal(err)
+		}
+		for i := range history {
+			if err := enc.Encode(&history[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return b.Bytes()
+	}
+
+	if _, err := ReadBinary(bytes.NewReader(withHeader(binaryHeader{Version: binaryVersion, Length: len(history)}))); err != nil {
+		t.Fatalf("failed to read history: %v", err)
+	}
+	for _, length := range []int{-1, 3, 1 << 40} {
+		// a huge length must not be allocated up front
+		if _, err := ReadBinary(bytes.NewReader(withHeader(binaryHeader{Version: binaryVersion, Length: length}))); err == nil {
+			t.Fatalf("expected error reading history with length %d", length)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := WriteBinary(&b, history); err != nil {
+		t.Fatalf("failed to write history: %v", err)
+	}
+	data := b.Bytes()
+	if _, err := ReadBinary(bytes.NewReader(data[:len(data)-5])); err == nil {
+		t.Fatal("expected error reading truncated history")
+	}
+}
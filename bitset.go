@@ -16,6 +16,21 @@ func newBitset(bits uint) bitset {
 	return bitset(make([]uint64, chunks))
 }
 
+// grow returns a bitset that can hold at least the given number of bits,
+// with the same bits set as b. If b is already large enough, it is returned
+// as is; otherwise, the data is copied to a new bitset, so b must not be
+// used afterwards. Because a bitset's size is part of its hash, and bitsets
+// of different sizes are never equal, all bitsets that are compared must be
+// grown to the same size.
+func (b bitset) grow(bits uint) bitset {
+	grown := newBitset(bits)
+	if len(grown) <= len(b) {
+		return b
+	}
+	copy(grown, b)
+	return grown
+}
+
 func (b bitset) clone() bitset {
 	dataCopy := make([]uint64, len(b))
 	copy(dataCopy, b)
@@ -38,6 +53,11 @@ func (b bitset) clear(pos uint) bitset {
 	return b
 }
 
+func (b bitset) get(pos uint) bool {
+	major, minor := bitsetIndex(pos)
+	return b[major]&(1<<minor) != 0
+}
+
 func (b bitset) popcnt() uint {
 	total := 0
 	for _, v := range b {
@@ -46,11 +66,31 @@ func (b bitset) popcnt() uint {
 	return uint(total)
 }
 
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// hash computes a hash of the bitset's data using the mixing functions from
+// xxHash64, processing a 64-bit chunk at a time. The result depends only on
+// the contents of the bitset, so it is deterministic across runs and
+// architectures.
 func (b bitset) hash() uint64 {
-	hash := uint64(b.popcnt())
+	hash := xxPrime5 + 8*uint64(len(b))
 	for _, v := range b {
-		hash ^= v
+		k := bits.RotateLeft64(v*xxPrime2, 31) * xxPrime1
+		hash ^= k
+		hash = bits.RotateLeft64(hash, 27)*xxPrime1 + xxPrime4
 	}
+	// final avalanche
+	hash ^= hash >> 33
+	hash *= xxPrime2
+	hash ^= hash >> 29
+	hash *= xxPrime3
+	hash ^= hash >> 32
 	return hash
 }
 
@@ -0,0 +1,66 @@
+package porcupine
+
+import "testing"
+
+func TestBitsetHashDeterministic(t *testing.T) {
+	// the hash should never change across runs, platforms, or Go versions
+	b := newBitset(130).set(0).set(63).set(64).set(129)
+	expected := uint64(0xb7963280bde0a045)
+	if b.hash() != expected {
+		t.Fatalf("expected hash %#x, got %#x", expected, b.hash())
+	}
+}
+
+func TestBitsetHashEqual(t *testing.T) {
+	b1 := newBitset(100).set(3).set(70).set(99)
+	b2 := newBitset(100).set(99).set(3).set(70).set(50).clear(50)
+	if !b1.equals(b2) {
+		t.Fatal("expected bitsets to be equal")
+	}
+	if b1.hash() != b2.hash() {
+		t.Fatal("expected equal bitsets to have equal hashes")
+	}
+	if b1.clone().hash() != b1.hash() {
+		t.Fatal("expected clone to have equal hash")
+	}
+}
+
+func TestBitsetHashDistribution(t *testing.T) {
+	// all bitsets with exactly two bits set should have distinct hashes
+	const n = 128
+	hashes := make(map[uint64]struct{})
+	count := 0
+	for i := uint(0); i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			hashes[newBitset(n).set(i).set(j).hash()] = struct{}{}
+			count++
+		}
+	}
+	if len(hashes) != count {
+		t.Fatalf("expected %d distinct hashes, got %d", count, len(hashes))
+	}
+}
+
+func TestBitsetGrow(t *testing.T) {
+	b := newBitset(64).set(0).set(63)
+	if len(b.grow(64)) != 1 || len(b.grow(10)) != 1 {
+		t.Fatal("expected bitset not to grow")
+	}
+	b = b.grow(65).set(64)
+	if len(b) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(b))
+	}
+	for i := uint(0); i < 128; i++ {
+		expected := i == 0 || i == 63 || i == 64
+		if b.get(i) != expected {
+			t.Fatalf("expected bit %d to be %v", i, expected)
+		}
+	}
+	b = b.grow(200).set(199).clear(63)
+	if b.popcnt() != 3 || !b.get(199) || b.get(63) {
+		t.Fatalf("unexpected bits after growing: %v", b)
+	}
+	if !b.equals(newBitset(200).set(0).set(64).set(199)) {
+		t.Fatal("expected grown bitset to equal a new bitset with the same bits")
+	}
+}
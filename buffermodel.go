@@ -0,0 +1,132 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A BufferOp is the kind of an operation on a bounded buffer.
+type BufferOp int
+
+const (
+	BufferEnqueue BufferOp = iota // add a value to the back of the buffer
+	BufferDequeue                 // remove the value at the front of the buffer
+)
+
+// A BufferInput is the input of an operation for the model returned by
+// [BoundedBufferModel].
+type BufferInput struct {
+	Op    BufferOp
+	Value interface{} // value to enqueue, for BufferEnqueue
+}
+
+// A BufferOutput is the output of an operation for the model returned by
+// [BoundedBufferModel].
+type BufferOutput struct {
+	Ok    bool        // whether the operation succeeded
+	Value interface{} // value that was dequeued, for BufferDequeue
+}
+
+// BoundedBufferModel returns a model of a FIFO buffer with the given
+// capacity, such as a buffered Go channel.
+//
+// An enqueue succeeds only if the buffer is not full, and a dequeue succeeds
+// only if the buffer is not empty, returning the value at the front of the
+// buffer. An operation that does not succeed (i.e., its output's Ok is false)
+// must have observed a full buffer, for an enqueue, or an empty buffer, for a
+// dequeue, and it doesn't change the buffer. Values are compared using
+// [reflect.DeepEqual].
+//
+// Blocking operations, which wait until they can succeed, should be recorded
+// with a call time of when the operation started waiting and a return time
+// of when it succeeded, so the checker can linearize them at any point in
+// between, when the buffer was not full (or empty).
+//
+// Inputs must be of type [BufferInput] and outputs must be of type
+// [BufferOutput]. The capacity must be positive.
+func BoundedBufferModel(capacity int) Model {
+	if capacity <= 0 {
+		panic("porcupine: bounded buffer capacity must be positive")
+	}
+	return Model{
+		Init: func() interface{} {
+			return []interface{}{}
+		},
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			buf := state.([]interface{})
+			inp := input.(BufferInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(BufferOutput)
+			switch inp.Op {
+			case BufferEnqueue:
+				if pending {
+					// fails if and only if the buffer is full
+					out.Ok = len(buf) < capacity
+				}
+				if !out.Ok {
+					return len(buf) == capacity, state
+				}
+				if len(buf) == capacity {
+					return false, state
+				}
+				newBuf := make([]interface{}, len(buf)+1)
+				copy(newBuf, buf)
+				newBuf[len(buf)] = inp.Value
+				return true, newBuf
+			case BufferDequeue:
+				if pending {
+					// fails if and only if the buffer is empty, and
+					// otherwise dequeues whatever value is at the front
+					if len(buf) == 0 {
+						return true, state
+					}
+					return true, buf[1:]
+				}
+				if !out.Ok {
+					return len(buf) == 0, state
+				}
+				if len(buf) == 0 || !reflect.DeepEqual(out.Value, buf[0]) {
+					return false, state
+				}
+				return true, buf[1:]
+			}
+			return false, state
+		},
+		Equal: func(state1, state2 interface{}) bool {
+			return reflect.DeepEqual(state1, state2)
+		},
+		DescribeOperation: func(input, output interface{}) string {
+			inp := input.(BufferInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(BufferOutput)
+			switch inp.Op {
+			case BufferEnqueue:
+				if pending {
+					return fmt.Sprintf("enqueue(%v) -> pending", inp.Value)
+				}
+				if !out.Ok {
+					return fmt.Sprintf("enqueue(%v) -> full", inp.Value)
+				}
+				return fmt.Sprintf("enqueue(%v)", inp.Value)
+			case BufferDequeue:
+				if pending {
+					return "dequeue() -> pending"
+				}
+				if !out.Ok {
+					return "dequeue() -> empty"
+				}
+				return fmt.Sprintf("dequeue() -> %v", out.Value)
+			}
+			return "<invalid>"
+		},
+		DescribeState: func(state interface{}) string {
+			buf := state.([]interface{})
+			values := make([]string, len(buf))
+			for i, v := range buf {
+				values[i] = fmt.Sprintf("%v", v)
+			}
+			return fmt.Sprintf("[%s]", strings.Join(values, ", "))
+		},
+	}
+}
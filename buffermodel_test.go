@@ -0,0 +1,73 @@
+package porcupine
+
+import "testing"
+
+func TestBoundedBufferModelFull(t *testing.T) {
+	model := BoundedBufferModel(2)
+	// the third enqueue blocks until the dequeue makes room
+	ops := []Operation{
+		{0, BufferInput{BufferEnqueue, 1}, 0, BufferOutput{Ok: true}, 10},
+		{0, BufferInput{BufferEnqueue, 2}, 20, BufferOutput{Ok: true}, 30},
+		{1, BufferInput{BufferEnqueue, 3}, 40, BufferOutput{Ok: true}, 100},
+		{2, BufferInput{BufferDequeue, nil}, 50, BufferOutput{Ok: true, Value: 1}, 60},
+		{0, BufferInput{BufferEnqueue, 4}, 70, BufferOutput{Ok: false}, 80},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// the third enqueue can't succeed before the dequeue makes room
+	ops = []Operation{
+		{0, BufferInput{BufferEnqueue, 1}, 0, BufferOutput{Ok: true}, 10},
+		{0, BufferInput{BufferEnqueue, 2}, 20, BufferOutput{Ok: true}, 30},
+		{1, BufferInput{BufferEnqueue, 3}, 40, BufferOutput{Ok: true}, 45},
+		{2, BufferInput{BufferDequeue, nil}, 50, BufferOutput{Ok: true, Value: 1}, 60},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+
+	// an enqueue can't fail if the buffer isn't full
+	ops = []Operation{
+		{0, BufferInput{BufferEnqueue, 1}, 0, BufferOutput{Ok: true}, 10},
+		{0, BufferInput{BufferEnqueue, 2}, 20, BufferOutput{Ok: false}, 30},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
+
+func TestBoundedBufferModelEmpty(t *testing.T) {
+	model := BoundedBufferModel(1)
+	// the first dequeue blocks until the enqueue, and the second one fails
+	ops := []Operation{
+		{0, BufferInput{BufferDequeue, nil}, 0, BufferOutput{Ok: true, Value: 1}, 100},
+		{1, BufferInput{BufferEnqueue, 1}, 50, BufferOutput{Ok: true}, 60},
+		{1, BufferInput{BufferDequeue, nil}, 110, BufferOutput{Ok: false}, 120},
+	}
+	if !CheckOperations(model, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+
+	// a dequeue can't fail if the buffer isn't empty
+	ops = []Operation{
+		{0, BufferInput{BufferEnqueue, 1}, 0, BufferOutput{Ok: true}, 10},
+		{1, BufferInput{BufferDequeue, nil}, 20, BufferOutput{Ok: false}, 30},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+
+	// values are dequeued in FIFO order
+	model = BoundedBufferModel(2)
+	ops = []Operation{
+		{0, BufferInput{BufferEnqueue, 1}, 0, BufferOutput{Ok: true}, 10},
+		{0, BufferInput{BufferEnqueue, 2}, 20, BufferOutput{Ok: true}, 30},
+		{1, BufferInput{BufferDequeue, nil}, 40, BufferOutput{Ok: true, Value: 2}, 50},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
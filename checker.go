@@ -1,7 +1,11 @@
 package porcupine
 
 import (
+	"fmt"
+	"math/bits"
+	"reflect"
 	"sort"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -24,7 +28,73 @@ type entry struct {
 type LinearizationInfo struct {
 	history               [][]entry // for each partition, a list of entries
 	partialLinearizations [][][]int // for each partition, a set of histories (list of ids)
-	annotations           []annotation
+	partitions            []PartitionResult
+	annotations           []VisualizationAnnotation
+	metadata              []OperationMetadata
+	tagStyles             map[string]TagStyle
+	labeled               bool  // whether the partitions have labels
+	callOffset            int64 // how much earlier calls are than in the history, from CheckOptions.TimestampEpsilon
+	returnOffset          int64 // how much later returns are than in the history
+}
+
+// A PartitionResult summarizes the linearizability check of a single
+// partition of a history.
+type PartitionResult struct {
+	// Label of this partition, if the model's partition function gives
+	// partitions labels (see [Model]).
+	Label string
+	// Whether or not this partition is linearizable.
+	Linearizable bool
+	// Number of distinct (linearized operations, state) pairs that the
+	// checker explored for this partition. A very large number might
+	// indicate a poorly-chosen state representation or a missing partition
+	// function.
+	States int
+	// Whether the check of this partition was stopped because it exceeded
+	// its own timeout (see [CheckOptions]), in which case it is unknown
+	// whether the partition is linearizable.
+	TimedOut bool
+	// Whether the check of this partition may have been stopped before it
+	// finished, because the overall check timed out or, in a check that
+	// isn't verbose, because another partition was found not to be
+	// linearizable. In this case, it is unknown whether the partition is
+	// linearizable, and States is a lower bound (or 0, if the partition's
+	// result was never collected). A partition that is not linearizable
+	// and was neither stopped nor timed out caused the history to be
+	// reported as not linearizable.
+	Stopped bool
+	// Whether the check of this partition was abandoned because the
+	// search reached a point with more pending operations than the limit
+	// given by [CheckOptions.MaxPending], in which case it is unknown
+	// whether the partition is linearizable: the partition is too hard to
+	// check with that limit, rather than definitely (not) linearizable.
+	MaxPendingExceeded bool
+	// Error that stopped the check of this partition, if any: a panic in
+	// one of the model's functions, if [CheckOptions.RecoverPanics] is
+	// set, or a disagreement with the brute-force search, if
+	// [CheckOptions.CrossCheck] is set. In this case, it is unknown
+	// whether the partition is linearizable, and the checks of the other
+	// partitions are stopped, so the result of the whole check is
+	// Unknown.
+	Err error
+}
+
+// PartitionResults returns a summary of the linearizability check for each
+// partition, in the same order as [LinearizationInfo.PartialLinearizations].
+func (li *LinearizationInfo) PartitionResults() []PartitionResult {
+	return li.partitions
+}
+
+// Err returns the error that stopped the linearizability check, if any: the
+// first error among the [PartitionResult.Err] of the partitions, in order,
+// or nil if there is none.
+func (li *LinearizationInfo) Err() error {
+	for _, p := range li.partitions {
+		if p.Err != nil {
+			return p.Err
+		}
+	}
+	return nil
 }
 
 // PartialLinearizations returns partial linearizations found during the
@@ -97,6 +167,124 @@ func (li *LinearizationInfo) PartialLinearizationsOperations() [][][]Operation {
 	return result
 }
 
+// Merge combines the partial linearizations found by another check of the
+// same history into li, removing duplicates.
+//
+// This is useful when a check times out, because different runs may find
+// different partial linearizations: merging the results of several runs
+// gives a more complete visualization than any single run. A partition is
+// considered linearizable if it was found to be linearizable by either
+// check. It returns an error, without modifying li, if the histories of the
+// two checks differ.
+func (li *LinearizationInfo) Merge(other LinearizationInfo) error {
+	if len(li.history) != len(other.history) {
+		return fmt.Errorf("histories have different numbers of partitions: %d and %d", len(li.history), len(other.history))
+	}
+	for p := range li.history {
+		a, b := li.history[p], other.history[p]
+		if len(a) != len(b) {
+			return fmt.Errorf("partition %d: histories have different lengths: %d and %d", p, len(a)/2, len(b)/2)
+		}
+		for i := range a {
+			if a[i].kind != b[i].kind || a[i].id != b[i].id || a[i].time != b[i].time || a[i].clientId != b[i].clientId || !reflect.DeepEqual(a[i].value, b[i].value) {
+				return fmt.Errorf("partition %d: histories differ", p)
+			}
+		}
+	}
+	for p := range li.history {
+		for _, partial := range other.partialLinearizations[p] {
+			found := false
+			for _, existing := range li.partialLinearizations[p] {
+				if reflect.DeepEqual(partial, existing) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				li.partialLinearizations[p] = append(li.partialLinearizations[p], partial)
+			}
+		}
+		if p < len(li.partitions) && p < len(other.partitions) {
+			li.partitions[p].Linearizable = li.partitions[p].Linearizable || other.partitions[p].Linearizable
+			if other.partitions[p].States > li.partitions[p].States {
+				li.partitions[p].States = other.partitions[p].States
+			}
+		}
+	}
+	return nil
+}
+
+// Canonical returns a deterministic textual representation of the
+// linearizations found by the linearizability check, suitable for comparing
+// against a golden file in tests.
+//
+// For each partition, the representation gives whether the partition is
+// linearizable and its (partial) linearizations, with each operation given
+// by its ID and a description of its input and output using the "%v" format
+// specifier. If the model's partition function gives partitions labels, each
+// partition's label is given after "partition", as a quoted string. The
+// format is:
+//
+//	partition: linearizable
+//	  linearization:
+//	    0: <input> -> <output>
+//	    1: <input> -> <output>
+//	partition: not linearizable
+//	  linearization:
+//	    ...
+//
+// Partitions are sorted by their representation, and within each partition,
+// partial linearizations are sorted by decreasing length and then by IDs, so
+// the result does not depend on the order in which partitions or partial
+// linearizations are found.
+func (li *LinearizationInfo) Canonical() string {
+	blocks := make([]string, len(li.history))
+	for p, partition := range li.history {
+		callValue := make(map[int]interface{})
+		returnValue := make(map[int]interface{})
+		for _, e := range partition {
+			if e.kind == callEntry {
+				callValue[e.id] = e.value
+			} else {
+				returnValue[e.id] = e.value
+			}
+		}
+		partials := make([][]int, len(li.partialLinearizations[p]))
+		copy(partials, li.partialLinearizations[p])
+		sort.Slice(partials, func(i, j int) bool {
+			a, b := partials[i], partials[j]
+			if len(a) != len(b) {
+				return len(a) > len(b)
+			}
+			for k := range a {
+				if a[k] != b[k] {
+					return a[k] < b[k]
+				}
+			}
+			return false
+		})
+		var b strings.Builder
+		b.WriteString("partition")
+		if p < len(li.partitions) && li.partitions[p].Label != "" {
+			fmt.Fprintf(&b, " %q", li.partitions[p].Label)
+		}
+		if p < len(li.partitions) && li.partitions[p].Linearizable {
+			b.WriteString(": linearizable\n")
+		} else {
+			b.WriteString(": not linearizable\n")
+		}
+		for _, partial := range partials {
+			b.WriteString("  linearization:\n")
+			for _, id := range partial {
+				fmt.Fprintf(&b, "    %d: %s\n", id, defaultDescribeOperation(callValue[id], returnValue[id]))
+			}
+		}
+		blocks[p] = b.String()
+	}
+	sort.Strings(blocks)
+	return strings.Join(blocks, "")
+}
+
 type byTime []entry
 
 func (a byTime) Len() int {
@@ -116,17 +304,114 @@ func (a byTime) Less(i, j int) bool {
 	return a[i].kind == callEntry && a[j].kind == returnEntry
 }
 
-func makeEntries(history []Operation) []entry {
-	var entries []entry = nil
-	id := 0
-	for _, elem := range history {
-		entries = append(entries, entry{
-			callEntry, elem.Input, id, elem.Call, elem.ClientId})
-		entries = append(entries, entry{
-			returnEntry, elem.Output, id, elem.Return, elem.ClientId})
-		id++
-	}
-	sort.Sort(byTime(entries))
+// makeEntries converts a history to a list of entries sorted by time,
+// widening the interval of every operation by epsilon (half on each side).
+//
+// Calls and returns are sorted separately and then merged, and sorting is
+// skipped for either if they are already sorted, which is common, e.g., for
+// histories that are recorded in order of invocation.
+func makeEntries(history []Operation, epsilon int64) []entry {
+	calls := make([]entry, len(history))
+	returns := make([]entry, len(history))
+	for id, elem := range history {
+		calls[id] = entry{callEntry, elem.Input, id, elem.Call - epsilon/2, elem.ClientId}
+		returns[id] = entry{returnEntry, elem.Output, id, elem.Return + (epsilon - epsilon/2), elem.ClientId}
+	}
+	if !sort.IsSorted(byTime(calls)) {
+		sort.Sort(byTime(calls))
+	}
+	if !sort.IsSorted(byTime(returns)) {
+		sort.Sort(byTime(returns))
+	}
+	entries := make([]entry, 0, 2*len(history))
+	i, j := 0, 0
+	for i < len(calls) || j < len(returns) {
+		// at the same time, calls are ordered before returns
+		if j == len(returns) || (i < len(calls) && calls[i].time <= returns[j].time) {
+			entries = append(entries, calls[i])
+			i++
+		} else {
+			entries = append(entries, returns[j])
+			j++
+		}
+	}
+	return entries
+}
+
+// makeStrictEntries is like makeEntries, but it orders entries at the same
+// time for [CheckOptions.StrictTimestamps]: first returns, then calls and
+// returns of zero-duration operations (each operation's return right after
+// its call, ordered by position in the history), and then calls.
+func makeStrictEntries(history []Operation, epsilon int64) []entry {
+	entries := makeEntries(history, epsilon)
+	instant := make([]bool, len(history))
+	for id, elem := range history {
+		instant[id] = elem.Call == elem.Return && epsilon == 0
+	}
+	rank := func(e entry) int {
+		switch {
+		case instant[e.id]:
+			return 1
+		case e.kind == returnEntry:
+			return 0
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.time != b.time {
+			return a.time < b.time
+		}
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if instant[a.id] && a.id != b.id {
+			return a.id < b.id
+		}
+		return a.kind == callEntry && b.kind == returnEntry
+	})
+	return entries
+}
+
+// makeTieBreakEntries is like makeEntries, but it orders entries at the same
+// time using the given policy (see [CheckOptions.TieBreak]).
+func makeTieBreakEntries(history []Operation, epsilon int64, tieBreak func(a Operation, aKind EventKind, b Operation, bKind EventKind) bool) []entry {
+	entries := makeEntries(history, epsilon)
+	kind := func(e entry) EventKind {
+		if e.kind == callEntry {
+			return CallEvent
+		}
+		return ReturnEvent
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.time != b.time {
+			return a.time < b.time
+		}
+		if a.id == b.id {
+			return a.kind == callEntry && b.kind == returnEntry
+		}
+		return tieBreak(history[a.id], kind(a), history[b.id], kind(b))
+	})
+	return entries
+}
+
+// makeBreakTiesEntries is like makeEntries, but it orders entries at the same
+// time by the position of their operations in the history, with each
+// operation's call before its return (see [CheckOptions.BreakTies]).
+func makeBreakTiesEntries(history []Operation, epsilon int64) []entry {
+	entries := makeEntries(history, epsilon)
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.time != b.time {
+			return a.time < b.time
+		}
+		if a.id != b.id {
+			return a.id < b.id
+		}
+		return a.kind == callEntry && b.kind == returnEntry
+	})
 	return entries
 }
 
@@ -176,6 +461,29 @@ func renumber(events []Event) []Event {
 	return e
 }
 
+// completePending adds a return event with a [PendingOutput] at the end of the
+// history for every call event that has no matching return event.
+func completePending(events []Event) []Event {
+	returned := make(map[int]struct{})
+	for _, v := range events {
+		if v.Kind == ReturnEvent {
+			returned[v.Id] = struct{}{}
+		}
+	}
+	var pending []Event
+	for _, v := range events {
+		if _, ok := returned[v.Id]; !ok && v.Kind == CallEvent {
+			pending = append(pending, Event{v.ClientId, ReturnEvent, PendingOutput{}, v.Id})
+		}
+	}
+	if len(pending) == 0 {
+		return events
+	}
+	completed := make([]Event, 0, len(events)+len(pending))
+	completed = append(completed, events...)
+	return append(completed, pending...)
+}
+
 func convertEntries(events []Event) []entry {
 	var entries []entry
 	for i, elem := range events {
@@ -189,33 +497,109 @@ func convertEntries(events []Event) []entry {
 	return entries
 }
 
+// makeLinkedEntries converts entries, which must have dense ids, to a
+// doubly-linked list. All of the nodes are allocated at once, and calls are
+// matched to returns by id, so this is linear in the number of entries, with
+// a constant number of allocations.
 func makeLinkedEntries(entries []entry) *node {
-	var root *node = nil
-	match := make(map[int]*node)
-	for i := len(entries) - 1; i >= 0; i-- {
-		elem := entries[i]
+	if len(entries) == 0 {
+		return nil
+	}
+	nodes := make([]node, len(entries))
+	match := make([]*node, len(entries)/2)
+	for i, elem := range entries {
+		n := &nodes[i]
+		n.value = elem.value
+		n.id = elem.id
+		if i > 0 {
+			n.prev = &nodes[i-1]
+		}
+		if i < len(entries)-1 {
+			n.next = &nodes[i+1]
+		}
 		if elem.kind == returnEntry {
-			entry := &node{value: elem.value, match: nil, id: elem.id}
-			match[elem.id] = entry
-			insertBefore(entry, root)
-			root = entry
-		} else {
-			entry := &node{value: elem.value, match: match[elem.id], id: elem.id}
-			insertBefore(entry, root)
-			root = entry
+			match[elem.id] = n
 		}
 	}
-	return root
+	for i, elem := range entries {
+		if elem.kind == callEntry {
+			nodes[i].match = match[elem.id]
+		}
+	}
+	return &nodes[0]
 }
 
+// A cacheEntry records that the search has reached a state after linearizing
+// a set of operations, so the search never needs to explore the same pair
+// twice. Caches are per partition: the bitset refers to operations of a single
+// partition, and a state alone says nothing about which operations remain to
+// be linearized, so entries can't be shared between partitions, even if the
+// partitions reach the same states.
 type cacheEntry struct {
 	linearized bitset
 	state      interface{}
 }
 
-func cacheContains(model Model, cache map[uint64][]cacheEntry, entry cacheEntry) bool {
-	for _, elem := range cache[entry.linearized.hash()] {
-		if entry.linearized.equals(elem.linearized) && model.Equal(entry.state, elem.state) {
+// cacheEntryOverhead is an estimate of the memory used by a cache entry, not
+// including the bitset data or anything referenced by the state.
+const cacheEntryOverhead = 64
+
+func (c cacheEntry) size() int64 {
+	return cacheEntryOverhead + 8*int64(len(c.linearized))
+}
+
+// A memoryBudget is a limit on the total size of the caches of all
+// partitions that are checked in parallel.
+type memoryBudget struct {
+	limit int64
+	used  int64 // accessed atomically
+}
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &memoryBudget{limit: limit}
+}
+
+// reserve tries to reserve n bytes, returning whether it succeeded. A nil
+// budget is unlimited.
+func (b *memoryBudget) reserve(n int64) bool {
+	if b == nil {
+		return true
+	}
+	for {
+		used := atomic.LoadInt64(&b.used)
+		if used+n > b.limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.used, used, used+n) {
+			return true
+		}
+	}
+}
+
+func (b *memoryBudget) release(n int64) {
+	if b == nil {
+		return
+	}
+	atomic.AddInt64(&b.used, -n)
+}
+
+// cacheKey computes the key of a cache entry, which combines the hash of the
+// linearized operations with the hash of the state, if the model can hash
+// states.
+func cacheKey(model Model, entry cacheEntry) uint64 {
+	hash := entry.linearized.hash()
+	if model.HashState != nil {
+		hash ^= bits.RotateLeft64(model.HashState(entry.state)*xxPrime2, 31) * xxPrime1
+	}
+	return hash
+}
+
+func cacheContains(model Model, cache map[uint64][]cacheEntry, key uint64, entry cacheEntry) bool {
+	for _, elem := range cache[key] {
+		if entry.linearized.equals(elem.linearized) && model.ObservationallyEqual(entry.state, elem.state) {
 			return true
 		}
 	}
@@ -247,34 +631,196 @@ func unlift(entry *node) {
 	entry.next.prev = entry
 }
 
-func checkSingle(model Model, history []entry, computePartial bool, kill *int32) (bool, []*[]int) {
+// predecessorsLinearized returns whether all of the given operations have been
+// linearized.
+func predecessorsLinearized(linearized bitset, preds []int) bool {
+	for _, p := range preds {
+		if !linearized.get(uint(p)) {
+			return false
+		}
+	}
+	return true
+}
+
+// pendingExceeds reports whether more than max operations are pending at the
+// start of the given list of entries, i.e., called before the first return,
+// so they are all candidates to be linearized next.
+func pendingExceeds(head *node, max int) bool {
+	pending := 0
+	for e := head.next; e != nil && e.match != nil; e = e.next {
+		pending++
+		if pending > max {
+			return true
+		}
+	}
+	return false
+}
+
+// A searchPosition is a position in the depth-first search of checkSingle,
+// from which the search can be resumed: the operations linearized so far, in
+// order, and the entry to consider next. It also holds the search's cache,
+// so that a search resumed in the same process doesn't repeat work; a
+// position restored without its cache is still valid.
+type searchPosition struct {
+	saved      bool // whether the search was stopped at this position
+	path       []int
+	next       int
+	nextReturn bool
+	cache      map[uint64][]cacheEntry
+}
+
+// save records the position of a search that is being stopped.
+func (p *searchPosition) save(calls []callsEntry, entry *node, cache map[uint64][]cacheEntry) {
+	p.saved = true
+	p.cache = cache
+	p.path = make([]int, len(calls))
+	for i, c := range calls {
+		p.path[i] = c.entry.id
+	}
+	p.next = entry.id
+	p.nextReturn = entry.match == nil
+}
+
+// restore brings a search back to the position, linearizing the operations
+// on the path from the initial state. It returns the resulting calls stack,
+// state, and entry to consider next, or an error if the position is not
+// valid for the given history.
+func (p *searchPosition) restore(model Model, headEntry *node, linearized bitset) ([]callsEntry, interface{}, *node, error) {
+	state := model.Init()
+	var calls []callsEntry
+	for _, id := range p.path {
+		var next *node
+		for e := headEntry.next; e != nil && e.match != nil; e = e.next {
+			if e.id == id {
+				next = e
+				break
+			}
+		}
+		if next == nil {
+			return nil, nil, nil, fmt.Errorf("operation %d can't be linearized after %d operations", id, len(calls))
+		}
+		ok, newState := model.Step(state, next.value, next.match.value)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("operation %d is rejected by the model after %d operations", id, len(calls))
+		}
+		calls = append(calls, callsEntry{next, state})
+		state = newState
+		linearized.set(uint(id))
+		lift(next)
+	}
+	for e := headEntry.next; e != nil; e = e.next {
+		if e.id == p.next && (e.match == nil) == p.nextReturn {
+			return calls, state, e, nil
+		}
+	}
+	return nil, nil, nil, fmt.Errorf("operation %d is not pending", p.next)
+}
+
+// checkSingle checks a single partition. It returns whether the partition is
+// linearizable, the longest partial linearizations, the number of states
+// explored, and whether the search was abandoned because more than maxPending
+// operations were pending (if maxPending is positive).
+//
+// If pos is not nil, the search starts from pos if it was saved, rather than
+// from the beginning, and if the search is killed, its position is saved in
+// pos. This isn't supported together with a memory budget.
+func checkSingle(model Model, history []entry, computePartial bool, kill *int32, budget *memoryBudget, hint []int, preds [][]int, maxPending int, pos *searchPosition) (bool, []*[]int, int, bool) {
 	entry := makeLinkedEntries(history)
 	n := length(entry) / 2
 	linearized := newBitset(uint(n))
 	cache := make(map[uint64][]cacheEntry) // map from hash to cache entry
+	cacheBytes := int64(0)                 // reserved from budget
+	defer func() { budget.release(cacheBytes) }()
+	states := 0
 	var calls []callsEntry
 	// longest linearizable prefix that includes the given entry
 	longest := make([]*[]int, n)
 
 	state := model.Init()
 	headEntry := insertBefore(&node{value: nil, match: nil, id: -1}, entry)
+	if maxPending > 0 && pendingExceeds(headEntry, maxPending) {
+		return false, longest, states, true
+	}
+	// follow the hint as far as possible; we don't cache states along the
+	// hint, because we don't search exhaustively from them
+	hintDepth := 0
+	for _, id := range hint {
+		var next *node
+		for e := headEntry.next; e != nil && e.match != nil; e = e.next {
+			if e.id == id {
+				next = e
+				break
+			}
+		}
+		if next == nil || (preds != nil && !predecessorsLinearized(linearized, preds[next.id])) {
+			break
+		}
+		ok, newState := model.Step(state, next.value, next.match.value)
+		if !ok {
+			break
+		}
+		calls = append(calls, callsEntry{next, state})
+		state = newState
+		linearized.set(uint(next.id))
+		lift(next)
+		hintDepth++
+	}
+	if hintDepth > 0 && maxPending > 0 && pendingExceeds(headEntry, maxPending) {
+		return false, longest, states, true
+	}
+	entry = headEntry.next
+	if pos != nil && pos.saved {
+		var err error
+		calls, state, entry, err = pos.restore(model, headEntry, linearized)
+		if err != nil {
+			panic(fmt.Sprintf("porcupine: can't resume search: %v", err))
+		}
+		if pos.cache != nil {
+			cache = pos.cache
+		}
+		pos.saved = false
+		pos.cache = nil
+	}
 	for headEntry.next != nil {
 		if atomic.LoadInt32(kill) != 0 {
-			return false, longest
+			if pos != nil {
+				pos.save(calls, entry, cache)
+			}
+			return false, longest, states, false
 		}
-		if entry.match != nil {
+		if entry.match != nil && preds != nil && !predecessorsLinearized(linearized, preds[entry.id]) {
+			entry = entry.next
+		} else if entry.match != nil {
 			matching := entry.match // the return entry
 			ok, newState := model.Step(state, entry.value, matching.value)
 			if ok {
 				newLinearized := linearized.clone().set(uint(entry.id))
 				newCacheEntry := cacheEntry{newLinearized, newState}
-				if !cacheContains(model, cache, newCacheEntry) {
-					hash := newLinearized.hash()
-					cache[hash] = append(cache[hash], newCacheEntry)
+				key := cacheKey(model, newCacheEntry)
+				if !cacheContains(model, cache, key, newCacheEntry) {
+					size := newCacheEntry.size()
+					reserved := budget.reserve(size)
+					if !reserved {
+						// drop our cache to make room; if that's not
+						// enough, continue without caching this entry,
+						// which is still correct but may be slower
+						cache = make(map[uint64][]cacheEntry)
+						budget.release(cacheBytes)
+						cacheBytes = 0
+						reserved = budget.reserve(size)
+					}
+					if reserved {
+						cache[key] = append(cache[key], newCacheEntry)
+						cacheBytes += size
+					}
+					states++
 					calls = append(calls, callsEntry{entry, state})
 					state = newState
 					linearized.set(uint(entry.id))
 					lift(entry)
+					if maxPending > 0 && pendingExceeds(headEntry, maxPending) {
+						return false, longest, states, true
+					}
 					entry = headEntry.next
 				} else {
 					entry = entry.next
@@ -284,7 +830,7 @@ func checkSingle(model Model, history []entry, computePartial bool, kill *int32)
 			}
 		} else {
 			if len(calls) == 0 {
-				return false, longest
+				return false, longest, states, false
 			}
 			// longest
 			if computePartial {
@@ -303,6 +849,21 @@ func checkSingle(model Model, history []entry, computePartial bool, kill *int32)
 					}
 				}
 			}
+			if len(calls) <= hintDepth {
+				// the hint is a dead end, so fall back to searching from
+				// scratch; states that are already cached were explored
+				// exhaustively, so they remain valid
+				for len(calls) > 0 {
+					callsTop := calls[len(calls)-1]
+					state = callsTop.state
+					linearized.clear(uint(callsTop.entry.id))
+					calls = calls[:len(calls)-1]
+					unlift(callsTop.entry)
+				}
+				hintDepth = 0
+				entry = headEntry.next
+				continue
+			}
 			callsTop := calls[len(calls)-1]
 			entry = callsTop.entry
 			state = callsTop.state
@@ -320,7 +881,7 @@ func checkSingle(model Model, history []entry, computePartial bool, kill *int32)
 	for i := 0; i < n; i++ {
 		longest[i] = &seq
 	}
-	return true, longest
+	return true, longest, states, false
 }
 
 func fillDefault(model Model) Model {
@@ -330,9 +891,28 @@ func fillDefault(model Model) Model {
 	if model.PartitionEvent == nil {
 		model.PartitionEvent = noPartitionEvent
 	}
+	if model.Init == nil {
+		panic("porcupine: model has no Init function")
+	}
+	if model.Step == nil && model.StepVerbose != nil {
+		stepVerbose := model.StepVerbose
+		model.Step = func(state, input, output interface{}) (bool, interface{}) {
+			ok, newState, _ := stepVerbose(state, input, output)
+			return ok, newState
+		}
+	}
+	if model.Step == nil {
+		panic("porcupine: model has no Step function (or StepVerbose function)")
+	}
 	if model.Equal == nil {
 		model.Equal = shallowEqual
 	}
+	if model.ObservationallyEqual == nil {
+		model.ObservationallyEqual = model.Equal
+	}
+	if model.EqualOutput == nil {
+		model.EqualOutput = reflect.DeepEqual
+	}
 	if model.DescribeOperation == nil {
 		model.DescribeOperation = defaultDescribeOperation
 	}
@@ -342,32 +922,106 @@ func fillDefault(model Model) Model {
 	return model
 }
 
-func checkParallel(model Model, history [][]entry, computeInfo bool, timeout time.Duration) (CheckResult, LinearizationInfo) {
+// checkParallel checks each partition of a history in parallel. If preds is
+// not nil, it contains additional ordering constraints for each partition:
+// for each operation, the operations that must be linearized before it.
+func checkParallel(model Model, history [][]entry, opts CheckOptions, preds [][][]int, labels []string) (CheckResult, LinearizationInfo) {
+	computeInfo := opts.Verbose
 	ok := true
 	timedOut := false
-	results := make(chan bool, len(history))
+	failed := false
+	type partitionOutcome struct {
+		index  int
+		result PartitionResult
+	}
+	results := make(chan partitionOutcome, len(history))
 	longest := make([][]*[]int, len(history))
-	kill := int32(0)
+	partitions := make([]PartitionResult, len(history))
+	// each partition has its own kill flag, so it can have its own timeout
+	kills := make([]int32, len(history))
+	killAll := func() {
+		for i := range kills {
+			atomic.StoreInt32(&kills[i], 1)
+		}
+	}
+	budget := newMemoryBudget(opts.MemoryLimit)
 	for i, subhistory := range history {
-		go func(i int, subhistory []entry) {
-			ok, l := checkSingle(model, subhistory, computeInfo, &kill)
+		var hint []int
+		if i < len(opts.Hints) {
+			hint = opts.Hints[i]
+		}
+		var p [][]int
+		if preds != nil {
+			p = preds[i]
+		}
+		go func(i int, subhistory []entry, hint []int, preds [][]int) {
+			if opts.RecoverPanics {
+				defer func() {
+					if r := recover(); r != nil {
+						results <- partitionOutcome{i, PartitionResult{Err: fmt.Errorf("porcupine: model panicked in partition %d: %v", i, r)}}
+					}
+				}()
+			}
+			model := model
+			if labels != nil {
+				model = model.partitionModel(labels[i])
+			}
+			if opts.RecoverPanics {
+				model = model.recovering()
+			}
+			var timer *time.Timer
+			if opts.PartitionTimeout > 0 {
+				timer = time.AfterFunc(opts.PartitionTimeout, func() {
+					atomic.StoreInt32(&kills[i], 1)
+				})
+			}
+			ok, l, states, exceeded := checkSingle(model, subhistory, computeInfo, &kills[i], budget, hint, preds, opts.MaxPending, nil)
+			// if the timer already fired, the check might have been
+			// stopped early; otherwise, stop it, so it doesn't fire
+			// after the result is reported
+			fired := false
+			if timer != nil {
+				fired = !timer.Stop()
+			}
+			partitionTimedOut := !ok && fired
+			// if we were killed otherwise, we can't tell whether the
+			// check finished before that
+			stopped := !ok && !partitionTimedOut && !exceeded && atomic.LoadInt32(&kills[i]) != 0
+			var crossCheckErr error
+			if opts.CrossCheck && !partitionTimedOut && !stopped && !exceeded {
+				crossCheckErr = crossCheck(model, subhistory, preds, ok)
+			}
 			longest[i] = l
-			results <- ok
-		}(i, subhistory)
+			results <- partitionOutcome{i, PartitionResult{Linearizable: ok, States: states, TimedOut: partitionTimedOut, Stopped: stopped, MaxPendingExceeded: exceeded, Err: crossCheckErr}}
+		}(i, subhistory, hint, p)
 	}
 	var timeoutChan <-chan time.Time
-	if timeout > 0 {
-		timeoutChan = time.After(timeout)
+	if opts.Timeout > 0 {
+		timeoutChan = time.After(opts.Timeout)
 	}
 	count := 0
+	collected := make([]bool, len(history))
 loop:
 	for {
 		select {
-		case result := <-results:
+		case outcome := <-results:
 			count++
-			ok = ok && result
-			if !ok && !computeInfo {
-				atomic.StoreInt32(&kill, 1)
+			partitions[outcome.index] = outcome.result
+			collected[outcome.index] = true
+			if outcome.result.Err != nil {
+				// the result is unknown no matter what the other
+				// partitions find, so stop them
+				failed = true
+				killAll()
+				break loop
+			}
+			if outcome.result.TimedOut || outcome.result.Stopped || outcome.result.MaxPendingExceeded {
+				timedOut = true
+			} else {
+				ok = ok && outcome.result.Linearizable
+			}
+			if !ok && (!computeInfo || opts.StopOnFirstFailure) {
+				killAll()
 				break loop
 			}
 			if count >= len(history) {
@@ -375,7 +1029,7 @@ loop:
 			}
 		case <-timeoutChan:
 			timedOut = true
-			atomic.StoreInt32(&kill, 1)
+			killAll()
 			break loop // if we time out, we might get a false positive
 		}
 	}
@@ -384,8 +1038,10 @@ loop:
 		// make sure we've waited for all goroutines to finish,
 		// otherwise we might race on access to longest[]
 		for count < len(history) {
-			<-results
+			outcome := <-results
 			count++
+			partitions[outcome.index] = outcome.result
+			collected[outcome.index] = true
 		}
 		// return longest linearizable prefixes that include each history element
 		partialLinearizations := make([][][]int, len(history))
@@ -408,11 +1064,21 @@ loop:
 		info.history = history
 		info.partialLinearizations = partialLinearizations
 	}
+	for i := range partitions {
+		if !collected[i] {
+			partitions[i].Stopped = true
+		}
+		if computeInfo && opts.StopOnFirstFailure && partitions[i].Stopped {
+			info.partialLinearizations[i] = nil
+		}
+	}
+	info.partitions = partitions
+	info.setLabels(labels)
 	var result CheckResult
-	if !ok {
+	if !ok && !failed {
 		result = Illegal
 	} else {
-		if timedOut {
+		if timedOut || failed {
 			result = Unknown
 		} else {
 			result = Ok
@@ -421,22 +1087,267 @@ loop:
 	return result, info
 }
 
-func checkEvents(model Model, history []Event, verbose bool, timeout time.Duration) (CheckResult, LinearizationInfo) {
+// orderPredecessors converts ordering constraints between operations, given
+// as pairs of operation IDs, to a list of predecessors for each of n
+// operations. Constraints that refer to nonexistent operations are ignored.
+func orderPredecessors(order [][2]int, n int) [][]int {
+	preds := make([][]int, n)
+	for _, edge := range order {
+		a, b := edge[0], edge[1]
+		if a < 0 || a >= n || b < 0 || b >= n {
+			continue
+		}
+		preds[b] = append(preds[b], a)
+	}
+	return preds
+}
+
+func checkEvents(model Model, history []Event, opts CheckOptions) (CheckResult, LinearizationInfo) {
 	model = fillDefault(model)
-	partitions := model.PartitionEvent(history)
+	if opts.Order != nil {
+		// ordering constraints can span partitions, so we can't partition
+		// the history; we also need to translate event IDs to entry IDs
+		events := renumber(completePending(history))
+		ids := make(map[int]int)
+		for i, v := range history {
+			ids[v.Id] = events[i].Id
+		}
+		var order [][2]int
+		for _, edge := range opts.Order {
+			a, aOk := ids[edge[0]]
+			b, bOk := ids[edge[1]]
+			if aOk && bOk {
+				order = append(order, [2]int{a, b})
+			}
+		}
+		entries := convertEntries(events)
+		preds := orderPredecessors(order, len(entries)/2)
+		return checkParallel(model, [][]entry{entries}, opts, [][][]int{preds}, nil)
+	}
+	partitions, labels := partitionEvents(model, history)
 	l := make([][]entry, len(partitions))
 	for i, subhistory := range partitions {
-		l[i] = convertEntries(renumber(subhistory))
+		l[i] = convertEntries(renumber(completePending(subhistory)))
+	}
+	return checkParallel(model, l, opts, nil, labels)
+}
+
+func operationEntries(history []Operation, opts CheckOptions) []entry {
+	if opts.TieBreak != nil {
+		return makeTieBreakEntries(history, opts.TimestampEpsilon, opts.TieBreak)
+	}
+	if opts.BreakTies {
+		return makeBreakTiesEntries(history, opts.TimestampEpsilon)
+	}
+	if opts.StrictTimestamps {
+		return makeStrictEntries(history, opts.TimestampEpsilon)
+	}
+	return makeEntries(history, opts.TimestampEpsilon)
+}
+
+// partitionOperations partitions a history using the model's Partition or
+// PartitionLabeled function; labels is nil if the model doesn't label
+// partitions, and otherwise, it is sorted, with the partitions in the same
+// order.
+func partitionOperations(model Model, history []Operation) ([][]Operation, []string) {
+	if model.PartitionLabeled == nil {
+		return model.Partition(history), nil
+	}
+	var partitions [][]Operation
+	var labels []string
+	labeled := model.PartitionLabeled(history)
+	for label := range labeled {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		partitions = append(partitions, labeled[label])
 	}
-	return checkParallel(model, l, verbose, timeout)
+	return partitions, labels
 }
 
-func checkOperations(model Model, history []Operation, verbose bool, timeout time.Duration) (CheckResult, LinearizationInfo) {
+// partitionEvents is like partitionOperations, but for histories of events.
+// It panics if the partitions aren't well-formed (see checkEventPartitions).
+func partitionEvents(model Model, history []Event) ([][]Event, []string) {
+	var partitions [][]Event
+	var labels []string
+	if model.PartitionEventLabeled == nil {
+		partitions = model.PartitionEvent(history)
+	} else {
+		labeled := model.PartitionEventLabeled(history)
+		for label := range labeled {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			partitions = append(partitions, labeled[label])
+		}
+	}
+	if err := checkEventPartitions(history, partitions, labels); err != nil {
+		panic(fmt.Sprintf("porcupine: model's event partition function is invalid: %v", err))
+	}
+	return partitions, labels
+}
+
+// checkEventPartitions checks that partitions of a history of events are
+// well-formed: every return is in the same partition as its call, after it,
+// and every call that returns in the history has its return in the same
+// partition. A partition function that splits an operation across
+// partitions would otherwise silently produce wrong results.
+func checkEventPartitions(history []Event, partitions [][]Event, labels []string) error {
+	returns := make(map[int]bool) // whether the operation returns in the history
+	for _, v := range history {
+		if v.Kind == ReturnEvent {
+			returns[v.Id] = true
+		}
+	}
+	for i, partition := range partitions {
+		name := fmt.Sprintf("partition %d", i)
+		if labels != nil {
+			name = fmt.Sprintf("partition %q", labels[i])
+		}
+		called := make(map[int]bool) // whether the call has returned
+		for _, v := range partition {
+			switch v.Kind {
+			case CallEvent:
+				if _, ok := called[v.Id]; ok {
+					return fmt.Errorf("%s: duplicate call for operation %d", name, v.Id)
+				}
+				called[v.Id] = false
+			case ReturnEvent:
+				returned, ok := called[v.Id]
+				if !ok {
+					return fmt.Errorf("%s: return for operation %d has no preceding call", name, v.Id)
+				}
+				if returned {
+					return fmt.Errorf("%s: duplicate return for operation %d", name, v.Id)
+				}
+				called[v.Id] = true
+			}
+		}
+		for _, v := range partition {
+			if v.Kind == CallEvent && !called[v.Id] && returns[v.Id] {
+				return fmt.Errorf("%s: call for operation %d has its return in a different partition", name, v.Id)
+			}
+		}
+	}
+	return nil
+}
+
+func checkOperations(model Model, history []Operation, opts CheckOptions) (CheckResult, LinearizationInfo) {
 	model = fillDefault(model)
-	partitions := model.Partition(history)
-	l := make([][]entry, len(partitions))
-	for i, subhistory := range partitions {
-		l[i] = makeEntries(subhistory)
+	history, order, nemeses := removeNemeses(history, opts.Order)
+	var res CheckResult
+	var info LinearizationInfo
+	if opts.Order != nil {
+		// ordering constraints can span partitions, so we can't partition
+		// the history; entry IDs are indices into the history
+		preds := orderPredecessors(order, len(history))
+		res, info = checkParallel(model, [][]entry{operationEntries(history, opts)}, opts, [][][]int{preds}, nil)
+	} else {
+		partitions, labels := partitionOperations(model, history)
+		l := make([][]entry, len(partitions))
+		for i, subhistory := range partitions {
+			l[i] = operationEntries(subhistory, opts)
+		}
+		res, info = checkParallel(model, l, opts, nil, labels)
+	}
+	info.callOffset = opts.TimestampEpsilon / 2
+	info.returnOffset = opts.TimestampEpsilon - opts.TimestampEpsilon/2
+	if opts.Verbose && len(nemeses) != 0 {
+		info.AddAnnotations(nemeses)
+	}
+	return res, info
+}
+
+// removeNemeses removes the operations whose input is a [Nemesis] from a
+// history, returning the remaining operations, the ordering constraints
+// between them (renumbered to match, with constraints through nemeses
+// replaced by constraints between the operations on either side), and
+// annotations for the nemeses. If there are no nemeses, the history and
+// order are returned as is.
+func removeNemeses(history []Operation, order [][2]int) ([]Operation, [][2]int, []Annotation) {
+	ids := make([]int, len(history)) // new index of each operation, or -1
+	var filtered []Operation
+	var nemeses []Annotation
+	for i, op := range history {
+		nemesis, ok := op.Input.(Nemesis)
+		if !ok {
+			ids[i] = len(filtered)
+			filtered = append(filtered, op)
+			continue
+		}
+		ids[i] = -1
+		nemeses = append(nemeses, Annotation{
+			Tag:         "nemesis",
+			Start:       op.Call,
+			End:         op.Return,
+			Description: nemesis.Description,
+			Details:     nemesis.Details,
+		})
+	}
+	if len(nemeses) == 0 {
+		return history, order, nil
+	}
+	valid := func(edge [2]int) bool {
+		return edge[0] >= 0 && edge[0] < len(ids) && edge[1] >= 0 && edge[1] < len(ids)
+	}
+	succs := make(map[int][]int)
+	for _, edge := range order {
+		if valid(edge) {
+			succs[edge[0]] = append(succs[edge[0]], edge[1])
+		}
+	}
+	var filteredOrder [][2]int
+	for _, edge := range order {
+		a, b := edge[0], edge[1]
+		if !valid(edge) || ids[a] == -1 {
+			continue
+		}
+		if ids[b] != -1 {
+			filteredOrder = append(filteredOrder, [2]int{ids[a], ids[b]})
+			continue
+		}
+		// the constraints pass through nemeses (and chains of them), so
+		// a must still precede the operations that follow them
+		visited := map[int]bool{b: true}
+		stack := []int{b}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, c := range succs[n] {
+				if visited[c] {
+					continue
+				}
+				visited[c] = true
+				if ids[c] == -1 {
+					stack = append(stack, c)
+				} else {
+					filteredOrder = append(filteredOrder, [2]int{ids[a], ids[c]})
+				}
+			}
+		}
+	}
+	return filtered, filteredOrder, nemeses
+}
+
+// setLabels sets the labels of the partitions, if the info was computed.
+func (li *LinearizationInfo) setLabels(labels []string) {
+	if labels == nil || li.partitions == nil {
+		return
+	}
+	li.labeled = true
+	for i := range li.partitions {
+		li.partitions[i].Label = labels[i]
+	}
+}
+
+// partitionModel returns the model to use for the given partition, whose
+// initial state is given by the model's InitPartition function if the
+// partitions are labeled.
+func (li *LinearizationInfo) partitionModel(model Model, partition int) Model {
+	if !li.labeled || partition >= len(li.partitions) {
+		return model
 	}
-	return checkParallel(model, l, verbose, timeout)
+	return model.partitionModel(li.partitions[partition].Label)
 }
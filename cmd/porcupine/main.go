@@ -0,0 +1,247 @@
+// Command porcupine checks a history for linearizability with respect to one
+// of the models provided by the porcupine package.
+//
+// Usage:
+//
+//	porcupine -model <model> [flags] [history]
+//
+// The history is read from the given file, or from standard input if no file
+// is given, in the format written by [porcupine.HistoryWriter], with inputs
+// and outputs encoded as the JSON encoding of the input and output types of
+// the model (e.g., [porcupine.LogInput] and [porcupine.LogOutput] for the
+// "log" model). The supported models are:
+//
+//	log        porcupine.LogModel
+//	scan       porcupine.ScanModel
+//	set        porcupine.SetModel
+//	buffer     porcupine.BoundedBufferModel, with the capacity given by -capacity
+//	writeonce  porcupine.WriteOnceRegisterModel
+//	lease      porcupine.LeaseModel
+//	gset       porcupine.GrowOnlySetModel
+//	lww        porcupine.LWWRegisterModel
+//
+// The command prints the result of the check and exits with status 0 if the
+// history is linearizable, 1 if it is not, 2 if there was an error, and 3 if
+// the check timed out.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/anishathalye/porcupine"
+)
+
+const (
+	exitOk      = 0
+	exitIllegal = 1
+	exitError   = 2
+	exitUnknown = 3
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type modelSpec struct {
+	model        porcupine.Model
+	decodeInput  func(data []byte) (interface{}, error)
+	decodeOutput func(data []byte) (interface{}, error)
+}
+
+func lookupModel(name string, capacity int) (modelSpec, error) {
+	switch name {
+	case "log":
+		return modelSpec{porcupine.LogModel(), decodeLogInput, decodeLogOutput}, nil
+	case "scan":
+		return modelSpec{porcupine.ScanModel(), decodeScanInput, decodeScanOutput}, nil
+	case "set":
+		return modelSpec{porcupine.SetModel(), decodeSetInput, decodeSetOutput}, nil
+	case "buffer":
+		if capacity <= 0 {
+			return modelSpec{}, fmt.Errorf("buffer capacity must be positive")
+		}
+		return modelSpec{porcupine.BoundedBufferModel(capacity), decodeBufferInput, decodeBufferOutput}, nil
+	case "writeonce":
+		return modelSpec{porcupine.WriteOnceRegisterModel(), decodeWriteOnceInput, decodeWriteOnceOutput}, nil
+	case "lease":
+		return modelSpec{porcupine.LeaseModel(), decodeLeaseInput, decodeLeaseOutput}, nil
+	case "gset":
+		return modelSpec{porcupine.GrowOnlySetModel(), decodeSetInput, decodeSetOutput}, nil
+	case "lww":
+		model := porcupine.LWWRegisterModel()
+		return modelSpec{model.ToModel(), decodeLWWInput, decodeLWWOutput}, nil
+	case "":
+		return modelSpec{}, fmt.Errorf("no model specified")
+	default:
+		return modelSpec{}, fmt.Errorf("unknown model %q", name)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("porcupine", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	modelName := flags.String("model", "", "model to check the history against: log, scan, set, buffer, writeonce, lease, gset, or lww")
+	capacity := flags.Int("capacity", 0, "capacity of the buffer, for the buffer model")
+	timeout := flags.Duration("timeout", 0, "time limit for the check (0 means no limit)")
+	visualize := flags.String("visualize", "", "write a visualization of the history to the given HTML file")
+	jsonOutput := flags.Bool("json", false, "print a machine-readable summary of the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() > 1 {
+		fmt.Fprintln(stderr, "porcupine: too many arguments")
+		return exitError
+	}
+	spec, err := lookupModel(*modelName, *capacity)
+	if err != nil {
+		fmt.Fprintf(stderr, "porcupine: %v\n", err)
+		return exitError
+	}
+
+	r := stdin
+	if flags.NArg() == 1 {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(stderr, "porcupine: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		r = f
+	}
+	events, err := porcupine.NewHistoryReader(r, spec.decodeInput, spec.decodeOutput).ReadEvents()
+	if err != nil {
+		fmt.Fprintf(stderr, "porcupine: reading history: %v\n", err)
+		return exitError
+	}
+
+	var result porcupine.CheckResult
+	var info porcupine.LinearizationInfo
+	if *visualize != "" || *jsonOutput {
+		result, info = porcupine.CheckEventsVerbose(spec.model, events, *timeout)
+	} else {
+		result = porcupine.CheckEventsTimeout(spec.model, events, *timeout)
+	}
+
+	if *visualize != "" {
+		if err := porcupine.VisualizePath(spec.model, info, *visualize); err != nil {
+			fmt.Fprintf(stderr, "porcupine: writing visualization: %v\n", err)
+			return exitError
+		}
+	}
+	if *jsonOutput {
+		if err := porcupine.WriteResultJSON(stdout, result, info); err != nil {
+			fmt.Fprintf(stderr, "porcupine: %v\n", err)
+			return exitError
+		}
+	} else {
+		fmt.Fprintln(stdout, describeResult(result))
+	}
+
+	switch result {
+	case porcupine.Ok:
+		return exitOk
+	case porcupine.Illegal:
+		return exitIllegal
+	default:
+		return exitUnknown
+	}
+}
+
+func describeResult(result porcupine.CheckResult) string {
+	switch result {
+	case porcupine.Ok:
+		return "linearizable"
+	case porcupine.Illegal:
+		return "not linearizable"
+	default:
+		return "unknown (timed out)"
+	}
+}
+
+func decodeLogInput(data []byte) (interface{}, error) {
+	var v porcupine.LogInput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeLogOutput(data []byte) (interface{}, error) {
+	var v porcupine.LogOutput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeScanInput(data []byte) (interface{}, error) {
+	var v porcupine.ScanInput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeScanOutput(data []byte) (interface{}, error) {
+	var v porcupine.ScanOutput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeSetInput(data []byte) (interface{}, error) {
+	var v porcupine.SetInput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeSetOutput(data []byte) (interface{}, error) {
+	var v porcupine.SetOutput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeBufferInput(data []byte) (interface{}, error) {
+	var v porcupine.BufferInput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeBufferOutput(data []byte) (interface{}, error) {
+	var v porcupine.BufferOutput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeWriteOnceInput(data []byte) (interface{}, error) {
+	var v porcupine.WriteOnceInput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeWriteOnceOutput(data []byte) (interface{}, error) {
+	var v porcupine.WriteOnceOutput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeLeaseInput(data []byte) (interface{}, error) {
+	var v porcupine.LeaseInput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeLeaseOutput(data []byte) (interface{}, error) {
+	var v porcupine.LeaseOutput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeLWWInput(data []byte) (interface{}, error) {
+	var v porcupine.LWWInput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeLWWOutput(data []byte) (interface{}, error) {
+	var v porcupine.LWWOutput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+)
+
+func writeSetHistory(t *testing.T, read []interface{}) []byte {
+	var buf bytes.Buffer
+	w := porcupine.NewHistoryWriter(&buf)
+	id1, _ := w.Call(0, porcupine.SetInput{Op: porcupine.SetAdd, Value: "x"})
+	id2, _ := w.Call(1, porcupine.SetInput{Op: porcupine.SetAdd, Value: "y"})
+	if err := w.Return(id1, porcupine.SetOutput{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Return(id2, porcupine.SetOutput{}); err != nil {
+		t.Fatal(err)
+	}
+	id3, _ := w.Call(0, porcupine.SetInput{Op: porcupine.SetRead})
+	if err := w.Return(id3, porcupine.SetOutput{Values: read}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	history := writeSetHistory(t, []interface{}{"y", "x"})
+	code := run([]string{"-model", "set"}, bytes.NewReader(history), &stdout, &stderr)
+	if code != exitOk {
+		t.Fatalf("expected exit code %d, got %d (stderr: %s)", exitOk, code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != "linearizable" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+
+	stdout.Reset()
+	history = writeSetHistory(t, []interface{}{"x"})
+	code = run([]string{"-model", "set"}, bytes.NewReader(history), &stdout, &stderr)
+	if code != exitIllegal {
+		t.Fatalf("expected exit code %d, got %d", exitIllegal, code)
+	}
+	if strings.TrimSpace(stdout.String()) != "not linearizable" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestRunFileOutputs(t *testing.T) {
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "history.jsonl")
+	if err := os.WriteFile(historyPath, writeSetHistory(t, []interface{}{"x"}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	visualizationPath := filepath.Join(dir, "history.html")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-model", "set", "-json", "-visualize", visualizationPath, historyPath}, nil, &stdout, &stderr)
+	if code != exitIllegal {
+		t.Fatalf("expected exit code %d, got %d (stderr: %s)", exitIllegal, code, stderr.String())
+	}
+	var summary struct {
+		Result     porcupine.CheckResult
+		Operations int
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Result != porcupine.Illegal || summary.Operations != 3 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if info, err := os.Stat(visualizationPath); err != nil || info.Size() == 0 {
+		t.Fatalf("expected visualization to be written: %v", err)
+	}
+}
+
+func TestRunTruncated(t *testing.T) {
+	// the log ends before the add of "y" returns, so the add is pending
+	var buf bytes.Buffer
+	w := porcupine.NewHistoryWriter(&buf)
+	id1, _ := w.Call(0, porcupine.SetInput{Op: porcupine.SetAdd, Value: "x"})
+	if err := w.Return(id1, porcupine.SetOutput{}); err != nil {
+		t.Fatal(err)
+	}
+	w.Call(1, porcupine.SetInput{Op: porcupine.SetAdd, Value: "y"})
+	id3, _ := w.Call(0, porcupine.SetInput{Op: porcupine.SetRead})
+	if err := w.Return(id3, porcupine.SetOutput{Values: []interface{}{"x", "y"}}); err != nil {
+		t.Fatal(err)
+	}
+	w.Call(0, porcupine.SetInput{Op: porcupine.SetRead})
+
+	visualizationPath := filepath.Join(t.TempDir(), "history.html")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-model", "set", "-visualize", visualizationPath}, bytes.NewReader(buf.Bytes()), &stdout, &stderr)
+	if code != exitOk {
+		t.Fatalf("expected exit code %d, got %d (stderr: %s)", exitOk, code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != "linearizable" {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"-model", "nonexistent"},
+		{"-model", "buffer"},
+		{"-model", "set", "a", "b"},
+		{"-model", "set", "nonexistent.jsonl"},
+	}
+	for _, args := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(args, strings.NewReader(""), &stdout, &stderr)
+		if code != exitError {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitError, code)
+		}
+		if stderr.Len() == 0 {
+			t.Errorf("%v: expected an error message", args)
+		}
+	}
+}
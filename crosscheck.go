@@ -0,0 +1,86 @@
+package porcupine
+
+import "fmt"
+
+// crossCheckMaxOperations is the largest partition, in number of
+// operations, that is cross-checked when [CheckOptions.CrossCheck] is set.
+const crossCheckMaxOperations = 8
+
+// bruteForceLinearizable checks whether a history is linearizable by trying
+// every order of its operations that respects the order of the entries and
+// the given predecessors, without any caching. It is much slower than
+// checkSingle, but simple enough to be obviously correct, so it can be used
+// to cross-check the result of checkSingle.
+func bruteForceLinearizable(model Model, history []entry, preds [][]int) bool {
+	n := len(history) / 2
+	callPos := make([]int, n)
+	returnPos := make([]int, n)
+	calls := make([]interface{}, n)
+	returns := make([]interface{}, n)
+	for pos, e := range history {
+		if e.kind == callEntry {
+			callPos[e.id] = pos
+			calls[e.id] = e.value
+		} else {
+			returnPos[e.id] = pos
+			returns[e.id] = e.value
+		}
+	}
+	linearized := make([]bool, n)
+	var search func(state interface{}, count int) bool
+	search = func(state interface{}, count int) bool {
+		if count == n {
+			return true
+		}
+		for id := 0; id < n; id++ {
+			if linearized[id] || !canLinearizeNext(id, linearized, callPos, returnPos, preds) {
+				continue
+			}
+			ok, newState := model.Step(state, calls[id], returns[id])
+			if !ok {
+				continue
+			}
+			linearized[id] = true
+			found := search(newState, count+1)
+			linearized[id] = false
+			if found {
+				return true
+			}
+		}
+		return false
+	}
+	return search(model.Init(), 0)
+}
+
+// canLinearizeNext returns whether the operation with the given id can be
+// linearized next, i.e., no other operation that hasn't been linearized
+// returns before it is called, and all of its predecessors have been
+// linearized.
+func canLinearizeNext(id int, linearized []bool, callPos, returnPos []int, preds [][]int) bool {
+	for other := range linearized {
+		if other != id && !linearized[other] && returnPos[other] < callPos[id] {
+			return false
+		}
+	}
+	if preds != nil {
+		for _, p := range preds[id] {
+			if !linearized[p] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// crossCheck returns an error if the result of checkSingle for a history
+// differs from the result of a brute-force search (see
+// [CheckOptions.CrossCheck]).
+func crossCheck(model Model, history []entry, preds [][]int, ok bool) error {
+	if len(history)/2 > crossCheckMaxOperations {
+		return nil
+	}
+	if bruteForce := bruteForceLinearizable(model, history, preds); bruteForce != ok {
+		return fmt.Errorf("porcupine: cross-check failed: search found linearizable = %v, but brute-force search found linearizable = %v", ok, bruteForce)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package porcupine
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// randomRegisterHistory generates a small history of concurrent writes and
+// reads on a register, where reads return one of the written values (or the
+// initial value), so it may or may not be linearizable
+func randomRegisterHistory(rng *rand.Rand, n int) []Operation {
+	ops := make([]Operation, n)
+	for i := range ops {
+		call := int64(rng.Intn(20))
+		ret := call + int64(rng.Intn(10))
+		if rng.Intn(2) == 0 {
+			ops[i] = Operation{i, registerInput{false, rng.Intn(3)}, call, 0, ret}
+		} else {
+			ops[i] = Operation{i, registerInput{true, 0}, call, rng.Intn(3), ret}
+		}
+	}
+	return ops
+}
+
+func TestCrossCheck(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	optionSets := []CheckOptions{
+		{CrossCheck: true},
+		{CrossCheck: true, Verbose: true},
+		{CrossCheck: true, StrictTimestamps: true},
+		{CrossCheck: true, BreakTies: true},
+		{CrossCheck: true, TimestampEpsilon: 4},
+	}
+	counts := make(map[CheckResult]int)
+	for i := 0; i < 200; i++ {
+		ops := randomRegisterHistory(rng, 1+rng.Intn(crossCheckMaxOperations))
+		for _, opts := range optionSets {
+			res, _ := CheckOperationsOptions(registerModel, ops, opts)
+			counts[res]++
+		}
+		res, _ := CheckOperationsOptions(registerModel, ops, CheckOptions{CrossCheck: true, Order: [][2]int{{len(ops) - 1, 0}}})
+		counts[res]++
+	}
+	if counts[Ok] == 0 || counts[Illegal] == 0 {
+		t.Fatalf("expected both linearizable and non-linearizable histories, got %v", counts)
+	}
+}
+
+func TestCrossCheckInconsistentEqual(t *testing.T) {
+	// an Equal function that considers all states equal makes the checker
+	// skip states that it hasn't explored
+	model := registerModel
+	model.Equal = func(state1, state2 interface{}) bool {
+		return true
+	}
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 0, 0, 10},
+		{2, registerInput{true, 0}, 20, 1, 30},
+	}
+	res, info := CheckOperationsOptions(model, ops, CheckOptions{CrossCheck: true, Verbose: true})
+	if res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	if err := info.Err(); err == nil || !strings.Contains(err.Error(), "cross-check failed") {
+		t.Fatalf("expected cross-check error, got %v", err)
+	}
+	if err := info.PartitionResults()[0].Err; err != info.Err() {
+		t.Fatalf("expected partition error %v, got %v", info.Err(), err)
+	}
+	var buf bytes.Buffer
+	if err := WriteResultJSON(&buf, res, info); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "cross-check failed") {
+		t.Fatalf("expected the error in the JSON summary, got %s", buf.String())
+	}
+}
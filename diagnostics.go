@@ -0,0 +1,170 @@
+package porcupine
+
+import (
+	"fmt"
+	"sort"
+)
+
+type interval struct {
+	start int64
+	end   int64
+}
+
+// ClockSkewWarnings returns warnings about suspicious timestamps in the
+// checked history that suggest that the timestamps were recorded using clocks
+// that are not synchronized, e.g., the clocks of different machines.
+//
+// Porcupine relies on timestamps to determine the real-time order of
+// operations, so clock skew can cause a correct system to be reported as
+// Illegal. This function reports operations that return before they are
+// called and operations that are called by a client before its previous
+// operation returns (clients are expected to be sequential). It relies on
+// the ClientId of operations, so it is only meaningful if ClientIds are
+// assigned. Timestamps are compared as they are in the history, before
+// they are widened by [CheckOptions.TimestampEpsilon].
+func (li *LinearizationInfo) ClockSkewWarnings() []string {
+	var warnings []string
+	clients := make(map[int][]interval)
+	for _, partition := range li.history {
+		calls := make(map[int]entry)
+		for _, e := range partition {
+			if e.kind == callEntry {
+				calls[e.id] = e
+			}
+		}
+		for _, e := range partition {
+			if e.kind != returnEntry {
+				continue
+			}
+			call := calls[e.id]
+			start, end := call.time+li.callOffset, e.time-li.returnOffset
+			if end < start {
+				warnings = append(warnings, fmt.Sprintf("client %d: operation returns at %d before it is called at %d", call.clientId, end, start))
+			}
+			clients[call.clientId] = append(clients[call.clientId], interval{start, end})
+		}
+	}
+	ids := make([]int, 0, len(clients))
+	for id := range clients {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		ops := clients[id]
+		sort.Slice(ops, func(i, j int) bool {
+			return ops[i].start < ops[j].start
+		})
+		for i := 1; i < len(ops); i++ {
+			if ops[i].start < ops[i-1].end {
+				warnings = append(warnings, fmt.Sprintf("client %d: operation is called at %d before the previous operation (called at %d) returns at %d", id, ops[i].start, ops[i-1].start, ops[i-1].end))
+			}
+		}
+	}
+	return warnings
+}
+
+// CriticalOperations explains why a history is not linearizable by finding,
+// for each partition that is not linearizable, a minimal set of operations
+// whose real-time ordering and values are incompatible, given as a sorted
+// list of operation IDs (the same IDs as in
+// [LinearizationInfo.PartialLinearizations]). For linearizable partitions,
+// the result is nil.
+//
+// The set is minimal in the sense that removing any single operation from it
+// makes the remaining operations linearizable, so each operation is part of
+// the conflict. Finding the set requires checking the linearizability of up
+// to one subset of the partition per operation, using the given model, so
+// this can be slow for large partitions. Additional ordering constraints
+// (see [CheckOptions]) are not taken into account.
+func (li *LinearizationInfo) CriticalOperations(model Model) [][]int {
+	model = fillDefault(model)
+	result := make([][]int, len(li.history))
+	for p, partition := range li.history {
+		if p < len(li.partitions) && li.partitions[p].Linearizable {
+			continue
+		}
+		model := li.partitionModel(model, p)
+		included := make(map[int]bool)
+		for _, e := range partition {
+			included[e.id] = true
+		}
+		if linearizableSubset(model, partition, included) {
+			// e.g., the check timed out
+			continue
+		}
+		for _, e := range partition {
+			if e.kind != callEntry {
+				continue
+			}
+			included[e.id] = false
+			if linearizableSubset(model, partition, included) {
+				included[e.id] = true
+			}
+		}
+		var ids []int
+		for id, ok := range included {
+			if ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Ints(ids)
+		result[p] = ids
+	}
+	return result
+}
+
+// linearizableSubset checks whether the operations of a partition that are
+// included are linearizable.
+func linearizableSubset(model Model, partition []entry, included map[int]bool) bool {
+	var subset []entry
+	ids := make(map[int]int) // renumbering, because the checker needs dense IDs
+	for _, e := range partition {
+		if !included[e.id] {
+			continue
+		}
+		id, ok := ids[e.id]
+		if !ok {
+			id = len(ids)
+			ids[e.id] = id
+		}
+		e.id = id
+		subset = append(subset, e)
+	}
+	kill := int32(0)
+	ok, _, _, _ := checkSingle(model, subset, false, &kill, nil, nil, nil, 0, nil)
+	return ok
+}
+
+// UnlinearizableOperations returns, for each partition, the operations that
+// don't appear in any of the partial linearizations found by the check, as a
+// sorted list of operation IDs (the same IDs as in
+// [LinearizationInfo.PartialLinearizations]). For linearizable partitions,
+// the result is empty.
+//
+// An operation doesn't appear in any partial linearization if there is no
+// linearizable prefix of the history that includes it, e.g., because it
+// returned a value that is impossible under any ordering, or because it
+// follows such an operation in real time. For an Illegal result, the
+// earliest of these operations are usually the culprits. If the check timed
+// out, the partial linearizations may be incomplete, so the result may
+// include operations that can be linearized.
+func (li *LinearizationInfo) UnlinearizableOperations() [][]int {
+	result := make([][]int, len(li.history))
+	for p, partition := range li.history {
+		linearized := make(map[int]struct{})
+		for _, partial := range li.partialLinearizations[p] {
+			for _, id := range partial {
+				linearized[id] = struct{}{}
+			}
+		}
+		ids := []int{}
+		for _, e := range partition {
+			if _, ok := linearized[e.id]; e.kind == callEntry && !ok {
+				ids = append(ids, e.id)
+			}
+		}
+		sort.Ints(ids)
+		result[p] = ids
+	}
+	return result
+}
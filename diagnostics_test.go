@@ -0,0 +1,106 @@
+package porcupine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClockSkewWarnings(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 100},
+		{1, registerInput{true, 0}, 25, 100, 75},
+		{2, registerInput{true, 0}, 30, 0, 60},
+	}
+	_, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if warnings := info.ClockSkewWarnings(); len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %v", warnings)
+	}
+
+	ops = []Operation{
+		{0, registerInput{false, 100}, 0, 0, 10},
+		{0, registerInput{true, 0}, 5, 100, 20},
+		{1, registerInput{true, 0}, 30, 100, 25},
+	}
+	_, info = CheckOperationsVerbose(registerModel, ops, 0)
+	expected := []string{
+		"client 1: operation returns at 25 before it is called at 30",
+		"client 0: operation is called at 5 before the previous operation (called at 0) returns at 10",
+	}
+	if warnings := info.ClockSkewWarnings(); !reflect.DeepEqual(warnings, expected) {
+		t.Fatalf("expected warnings %v, got %v", expected, warnings)
+	}
+
+	// widening by TimestampEpsilon doesn't make sequential operations
+	// overlap, or hide operations that return before they are called
+	ops = []Operation{
+		{0, registerInput{false, 100}, 0, 0, 10},
+		{0, registerInput{true, 0}, 12, 100, 20},
+		{1, registerInput{true, 0}, 30, 100, 29},
+	}
+	_, info = CheckOperationsOptions(registerModel, ops, CheckOptions{TimestampEpsilon: 5, Verbose: true})
+	expected = []string{
+		"client 1: operation returns at 29 before it is called at 30",
+	}
+	if warnings := info.ClockSkewWarnings(); !reflect.DeepEqual(warnings, expected) {
+		t.Fatalf("expected warnings %v, got %v", expected, warnings)
+	}
+}
+
+func TestCriticalOperations(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{true, 0}, 0, 0, 5},
+		{0, registerInput{false, 1}, 10, 0, 20},
+		{1, registerInput{true, 0}, 25, 1, 30},
+		{0, registerInput{false, 2}, 40, 0, 50},
+		{1, registerInput{true, 0}, 60, 0, 70},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	// the read of 0 conflicts with the preceding write of 2
+	expected := [][]int{{3, 4}}
+	if critical := info.CriticalOperations(registerModel); !reflect.DeepEqual(critical, expected) {
+		t.Fatalf("expected critical operations %v, got %v", expected, critical)
+	}
+
+	// linearizable partitions have no critical operations
+	ops = []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "y"}, 0, kvOutput{"b"}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"a"}, 30},
+	}
+	_, info = CheckOperationsVerbose(kvModel, ops, 0)
+	expected = [][]int{nil, {0}}
+	if critical := info.CriticalOperations(kvModel); !reflect.DeepEqual(critical, expected) {
+		t.Fatalf("expected critical operations %v, got %v", expected, critical)
+	}
+}
+
+func TestUnlinearizableOperations(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 5, 0, 15},
+		{0, registerInput{false, 2}, 20, 0, 30},
+		{1, registerInput{true, 0}, 25, 3, 35}, // never written
+		{0, registerInput{true, 0}, 40, 2, 50},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	expected := [][]int{{3, 4}}
+	if ids := info.UnlinearizableOperations(); !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected unlinearizable operations %v, got %v", expected, ids)
+	}
+
+	ops[3].Output = 2
+	res, info = CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	expected = [][]int{{}}
+	if ids := info.UnlinearizableOperations(); !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected unlinearizable operations %v, got %v", expected, ids)
+	}
+}
@@ -0,0 +1,121 @@
+package porcupine
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// VisualizeDiff produces a visualization that compares two histories of the
+// same workload, e.g., from before and after a change to a system, as an HTML
+// file that can be viewed in a web browser.
+//
+// The two histories are shown one above the other, with a shared time axis.
+// Operations are matched up by client and by their order within the client,
+// and operations that differ between the two histories (as described by the
+// model's DescribeOperation) are highlighted. Otherwise, the visualization
+// is the same as one produced by [Visualize].
+func VisualizeDiff(model Model, before, after LinearizationInfo, output io.Writer) error {
+	return writeVisualization(computeDiffVisualizationData(model, before, after), output)
+}
+
+type diffOperation struct {
+	elem  *HistoryElement
+	start int64
+}
+
+func numClients(data VisualizationData) int {
+	n := 0
+	for _, partition := range data.Partitions {
+		for _, elem := range partition.History {
+			if elem.ClientId >= n {
+				n = elem.ClientId + 1
+			}
+		}
+	}
+	for _, annot := range data.Annotations {
+		if annot.Tag == "" && annot.ClientId >= n {
+			n = annot.ClientId + 1
+		}
+	}
+	return n
+}
+
+func clientOperations(data VisualizationData) map[int][]diffOperation {
+	ops := make(map[int][]diffOperation)
+	for p := range data.Partitions {
+		history := data.Partitions[p].History
+		for i := range history {
+			ops[history[i].ClientId] = append(ops[history[i].ClientId], diffOperation{&history[i], history[i].Start})
+		}
+	}
+	for _, clientOps := range ops {
+		sort.SliceStable(clientOps, func(i, j int) bool {
+			return clientOps[i].start < clientOps[j].start
+		})
+	}
+	return ops
+}
+
+func computeDiffVisualizationData(model Model, before, after LinearizationInfo) VisualizationData {
+	b := computeVisualizationData(model, before, VisualizeOptions{})
+	a := computeVisualizationData(model, after, VisualizeOptions{})
+
+	// highlight operations that differ
+	beforeOps := clientOperations(b)
+	afterOps := clientOperations(a)
+	for client, bOps := range beforeOps {
+		aOps := afterOps[client]
+		for i, op := range bOps {
+			if i >= len(aOps) || aOps[i].elem.Description != op.elem.Description {
+				op.elem.differs = true
+			}
+		}
+	}
+	for client, aOps := range afterOps {
+		bOps := beforeOps[client]
+		for i, op := range aOps {
+			if i >= len(bOps) || bOps[i].elem.Description != op.elem.Description {
+				op.elem.differs = true
+			}
+		}
+	}
+
+	// put the clients of the second history after the clients of the first
+	nBefore := numClients(b)
+	nAfter := numClients(a)
+	for p := range a.Partitions {
+		for i := range a.Partitions[p].History {
+			a.Partitions[p].History[i].ClientId += nBefore
+		}
+	}
+	annotations := make([]VisualizationAnnotation, 0, len(b.Annotations)+len(a.Annotations))
+	for _, annot := range b.Annotations {
+		if annot.Tag != "" {
+			annot.Tag = "before: " + annot.Tag
+		}
+		annotations = append(annotations, annot)
+	}
+	for _, annot := range a.Annotations {
+		if annot.Tag != "" {
+			annot.Tag = "after: " + annot.Tag
+		} else {
+			annot.ClientId += nBefore
+		}
+		annotations = append(annotations, annot)
+	}
+	labels := make([]string, 0, nBefore+nAfter)
+	for i := 0; i < nBefore; i++ {
+		labels = append(labels, fmt.Sprintf("before %d", i))
+	}
+	for i := 0; i < nAfter; i++ {
+		labels = append(labels, fmt.Sprintf("after %d", i))
+	}
+
+	return VisualizationData{
+		Partitions:   append(b.Partitions, a.Partitions...),
+		Annotations:  annotations,
+		clientLabels: labels,
+		dividers:     []int{nBefore},
+	}
+}
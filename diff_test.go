@@ -0,0 +1,72 @@
+package porcupine
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVisualizeDiff(t *testing.T) {
+	before := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30},
+		{1, kvInput{op: 0, key: "x"}, 40, kvOutput{"y"}, 50},
+	}
+	after := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 15},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30},
+		{1, kvInput{op: 0, key: "x"}, 40, kvOutput{""}, 50},
+	}
+	res, beforeInfo := CheckOperationsVerbose(kvModel, before, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	res, afterInfo := CheckOperationsVerbose(kvModel, after, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	afterInfo.AddAnnotations([]Annotation{{Tag: "Server 1", Start: 35, Description: "restart"}})
+	data := computeDiffVisualizationData(kvModel, beforeInfo, afterInfo)
+	if len(data.Partitions) != 2 {
+		t.Fatalf("expected 2 partitions, got %d", len(data.Partitions))
+	}
+	expected := []HistoryElement{
+		{ClientId: 2, Start: 0, End: 15, Description: "put('x', 'y')"},
+		{ClientId: 3, Start: 20, End: 30, Description: "get('x') -> 'y'"},
+		{ClientId: 3, Start: 40, End: 50, Description: "get('x') -> ''", differs: true},
+	}
+	if !reflect.DeepEqual(expected, data.Partitions[1].History) {
+		t.Fatalf("expected history to be \n%v\n, was \n%v", expected, data.Partitions[1].History)
+	}
+	if !data.Partitions[0].History[2].differs || data.Partitions[0].History[1].differs {
+		t.Fatalf("unexpected differences in history %v", data.Partitions[0].History)
+	}
+	expectedLabels := []string{"before 0", "before 1", "after 0", "after 1"}
+	if !reflect.DeepEqual(expectedLabels, data.clientLabels) {
+		t.Fatalf("expected labels %v, got %v", expectedLabels, data.clientLabels)
+	}
+	if data.Annotations[0].Tag != "after: Server 1" {
+		t.Fatalf("unexpected annotation tag %q", data.Annotations[0].Tag)
+	}
+	// fields that are only used for rendering are passed to the renderer
+	b, err := marshalVisualization(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"Differs":true`, `"ClientLabels":["before 0"`, `"Dividers":[2]`, `"Annotation":true`} {
+		if !strings.Contains(string(b), s) {
+			t.Fatalf("expected visualization data to contain %q", s)
+		}
+	}
+
+	file, err := os.CreateTemp("", "*.html")
+	if err != nil {
+		t.Fatalf("failed to create temp file")
+	}
+	err = VisualizeDiff(kvModel, beforeInfo, afterInfo, file)
+	if err != nil {
+		t.Fatalf("visualization failed")
+	}
+	t.Logf("wrote visualization to %s", file.Name())
+}
@@ -0,0 +1,85 @@
+package porcupine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotLabel(model Model, op Operation) string {
+	return dotEscaper.Replace(fmt.Sprintf("C%d: %s", op.ClientId, model.DescribeOperation(op.Input, op.Output)))
+}
+
+// WriteDOT writes the real-time precedence graph of a history in the Graphviz
+// DOT format.
+//
+// There is a node for each operation, labeled using the model's
+// DescribeOperation, and there is an edge from operation a to operation b if a
+// returns before b is called. Edges implied by transitivity are omitted.
+func WriteDOT(model Model, history []Operation, w io.Writer) error {
+	model = fillDefault(model)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph history {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	for i, op := range history {
+		fmt.Fprintf(bw, "\top%d [label=\"%s\"];\n", i, dotLabel(model, op))
+	}
+	for j, b := range history {
+		// a precedes b directly if there's no c such that a precedes c
+		// and c precedes b, i.e., a returns after every predecessor of b
+		// is called
+		latestCall := int64(0)
+		found := false
+		for _, a := range history {
+			if a.Return < b.Call && (!found || a.Call > latestCall) {
+				latestCall = a.Call
+				found = true
+			}
+		}
+		for i, a := range history {
+			if a.Return < b.Call && a.Return >= latestCall {
+				fmt.Fprintf(bw, "\top%d -> op%d;\n", i, j)
+			}
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteLinearizationDOT writes the linearization found by the checker in the
+// Graphviz DOT format.
+//
+// Each partition is drawn as a cluster containing a path through its longest
+// (partial) linearization, with nodes labeled using the model's
+// DescribeOperation. To get the LinearizationInfo that this function
+// requires, you can use [CheckOperationsVerbose] / [CheckEventsVerbose].
+func WriteLinearizationDOT(model Model, info LinearizationInfo, w io.Writer) error {
+	model = fillDefault(model)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph linearization {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	for p, partials := range info.PartialLinearizationsOperations() {
+		var longest []Operation
+		for _, partial := range partials {
+			if len(partial) > len(longest) {
+				longest = partial
+			}
+		}
+		fmt.Fprintf(bw, "\tsubgraph cluster_%d {\n", p)
+		fmt.Fprintf(bw, "\t\tlabel=\"partition %d\";\n", p)
+		for i, op := range longest {
+			fmt.Fprintf(bw, "\t\tp%d_op%d [label=\"%s\"];\n", p, i, dotLabel(model, op))
+			if i > 0 {
+				fmt.Fprintf(bw, "\t\tp%d_op%d -> p%d_op%d;\n", p, i-1, p, i)
+			}
+		}
+		fmt.Fprintln(bw, "\t}")
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
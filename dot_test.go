@@ -0,0 +1,70 @@
+package porcupine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 100, 30},
+		{2, registerInput{true, 0}, 5, 0, 25},
+		{0, registerInput{false, 200}, 40, 0, 50},
+	}
+	var b strings.Builder
+	err := WriteDOT(registerModel, ops, &b)
+	if err != nil {
+		t.Fatalf("failed to write DOT: %v", err)
+	}
+	expected := `digraph history {
+	rankdir=LR;
+	node [shape=box];
+	op0 [label="C0: put('100')"];
+	op1 [label="C1: get() -> '100'"];
+	op2 [label="C2: get() -> '0'"];
+	op3 [label="C0: put('200')"];
+	op0 -> op1;
+	op1 -> op3;
+	op2 -> op3;
+}
+`
+	if b.String() != expected {
+		t.Fatalf("expected DOT output\n%s\ngot\n%s", expected, b.String())
+	}
+}
+
+func TestWriteLinearizationDOT(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30},
+		{2, kvInput{op: 1, key: "y", value: "\"z\""}, 0, kvOutput{}, 10},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	var b strings.Builder
+	err := WriteLinearizationDOT(kvModel, info, &b)
+	if err != nil {
+		t.Fatalf("failed to write DOT: %v", err)
+	}
+	expected := `digraph linearization {
+	rankdir=LR;
+	node [shape=box];
+	subgraph cluster_0 {
+		label="partition 0";
+		p0_op0 [label="C0: put('x', 'y')"];
+		p0_op1 [label="C1: get('x') -> 'y'"];
+		p0_op0 -> p0_op1;
+	}
+	subgraph cluster_1 {
+		label="partition 1";
+		p1_op0 [label="C2: put('y', '\"z\"')"];
+	}
+}
+`
+	if b.String() != expected {
+		t.Fatalf("expected DOT output\n%s\ngot\n%s", expected, b.String())
+	}
+}
@@ -0,0 +1,89 @@
+package porcupine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrUnknown is the error returned by [CheckOperationsError] and
+// [CheckEventsError] when it is unknown whether a history is linearizable,
+// e.g., because the check timed out.
+var ErrUnknown = errors.New("porcupine: linearizability unknown: check timed out")
+
+// maxErrorOperations is the number of operations that can't be linearized
+// that an error lists for each partition.
+const maxErrorOperations = 5
+
+// CheckOperationsError checks whether a history is linearizable, like
+// [CheckOperationsTimeout], but gives the result as an error, for use in
+// tests: it returns nil if the history is linearizable, [ErrUnknown] if the
+// check timed out, the error that stopped the check if there was one (see
+// [LinearizationInfo.Err]), and otherwise an error that names the
+// partitions that are not linearizable and the earliest operations in each
+// of them that can't be linearized (see
+// [LinearizationInfo.UnlinearizableOperations]).
+//
+// A timeout of 0 is interpreted as an unlimited timeout. If the history is
+// not linearizable, it is checked again to find the operations to report, as
+// with [CheckOperationsLazyVerbose].
+func CheckOperationsError(model Model, history []Operation, timeout time.Duration) error {
+	res, info := CheckOperationsLazyVerbose(model, history, timeout)
+	return resultError(model, res, info)
+}
+
+// CheckEventsError is like [CheckOperationsError], but for a history of
+// [Event].
+func CheckEventsError(model Model, history []Event, timeout time.Duration) error {
+	res, info := CheckEventsLazyVerbose(model, history, timeout)
+	return resultError(model, res, info)
+}
+
+func resultError(model Model, res CheckResult, info LinearizationInfo) error {
+	switch res {
+	case Ok:
+		return nil
+	case Unknown:
+		if err := info.Err(); err != nil {
+			return err
+		}
+		return ErrUnknown
+	}
+	model = fillDefault(model)
+	unlinearizable := info.UnlinearizableOperations()
+	var b strings.Builder
+	b.WriteString("porcupine: history is not linearizable")
+	for p, partition := range info.history {
+		if p < len(info.partitions) {
+			result := info.partitions[p]
+			if result.Linearizable || result.TimedOut || result.Stopped || result.MaxPendingExceeded {
+				continue
+			}
+		}
+		if p < len(info.partitions) && info.partitions[p].Label != "" {
+			fmt.Fprintf(&b, "\npartition %q:", info.partitions[p].Label)
+		} else {
+			fmt.Fprintf(&b, "\npartition %d:", p)
+		}
+		calls := make(map[int]entry)
+		returns := make(map[int]entry)
+		for _, e := range partition {
+			if e.kind == callEntry {
+				calls[e.id] = e
+			} else {
+				returns[e.id] = e
+			}
+		}
+		ids := unlinearizable[p]
+		for i, id := range ids {
+			if i == maxErrorOperations {
+				fmt.Fprintf(&b, "\n  and %d more", len(ids)-i)
+				break
+			}
+			call := calls[id]
+			fmt.Fprintf(&b, "\n  can't linearize operation %d (client %d): %s", id, call.clientId, model.DescribeOperation(call.value, returns[id].value))
+		}
+	}
+	return errors.New(b.String())
+}
@@ -0,0 +1,55 @@
+package porcupine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckOperationsError(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"z"}, 30},
+		{1, kvInput{op: 0, key: "y"}, 40, kvOutput{""}, 50},
+	}
+	err := CheckOperationsError(kvModel, ops, 0)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	expected := "porcupine: history is not linearizable\npartition 0:\n  can't linearize operation 1 (client 1): get('x') -> 'z'"
+	if err.Error() != expected {
+		t.Fatalf("expected error %q, got %q", expected, err.Error())
+	}
+	if err := CheckOperationsError(kvModel, ops[2:], 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// an intractable history (see TestBreakTies)
+	var hard []Operation
+	for i := 0; i < 30; i++ {
+		hard = append(hard, Operation{0, registerInput{false, i}, 5, 0, 5})
+		hard = append(hard, Operation{1, registerInput{true, 0}, 5, i, 5})
+	}
+	hard = append(hard, Operation{1, registerInput{true, 0}, 5, -1, 5})
+	if err := CheckOperationsError(registerModel, hard, 10*time.Millisecond); err != ErrUnknown {
+		t.Fatalf("expected %v, got %v", ErrUnknown, err)
+	}
+}
+
+func TestCheckEventsErrorLimit(t *testing.T) {
+	// every read follows a write of a different value
+	var events []Event
+	for i := 0; i < 8; i++ {
+		events = append(events, Event{0, CallEvent, registerInput{false, 1}, 2 * i})
+		events = append(events, Event{0, ReturnEvent, 0, 2 * i})
+		events = append(events, Event{1, CallEvent, registerInput{true, 0}, 2*i + 1})
+		events = append(events, Event{1, ReturnEvent, 2, 2*i + 1})
+	}
+	err := CheckEventsError(registerModel, events, 0)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 2+maxErrorOperations+1 || lines[len(lines)-1] != "  and 10 more" {
+		t.Fatalf("unexpected error %q", err.Error())
+	}
+}
@@ -0,0 +1,113 @@
+package porcupine
+
+import (
+	"fmt"
+	"strings"
+)
+
+type eventualState struct {
+	current interface{}
+	past    []interface{} // all states reached so far, including current
+}
+
+// CheckEventualConsistency checks whether a history is eventually
+// consistent, a weaker property than linearizability that is useful for
+// Dynamo-style systems that are not linearizable by design.
+//
+// Operations that change the state, such as writes, must be linearizable
+// with respect to each other. Operations that don't change the state, such
+// as reads, may observe any state that was reached at some point before them
+// in the linearization, not necessarily the latest one. Which operations are
+// reads is determined using the model: an operation is treated as a read if
+// the model's Step function accepts it in some previously reached state
+// without changing that state (according to the model's Equal function).
+func CheckEventualConsistency(model Model, history []Operation) bool {
+	res, _ := checkOperations(eventualModel(model), history, CheckOptions{})
+	return res == Ok
+}
+
+// eventualModel relaxes a model so that operations that don't change the
+// state may be applied to any state reached so far.
+func eventualModel(model Model) Model {
+	model = fillDefault(model)
+	contains := func(states []interface{}, state interface{}) bool {
+		for _, s := range states {
+			if model.Equal(s, state) {
+				return true
+			}
+		}
+		return false
+	}
+	var initPartition func(label string) interface{}
+	if model.InitPartition != nil {
+		initPartition = func(label string) interface{} {
+			init := model.InitPartition(label)
+			return eventualState{current: init, past: []interface{}{init}}
+		}
+	}
+	var describeTransition func(before, after, input, output interface{}) string
+	if model.DescribeTransition != nil {
+		describeTransition = func(before, after, input, output interface{}) string {
+			return model.DescribeTransition(before.(eventualState).current, after.(eventualState).current, input, output)
+		}
+	}
+	return Model{
+		Partition:             model.Partition,
+		PartitionEvent:        model.PartitionEvent,
+		PartitionLabeled:      model.PartitionLabeled,
+		PartitionEventLabeled: model.PartitionEventLabeled,
+		Init: func() interface{} {
+			init := model.Init()
+			return eventualState{current: init, past: []interface{}{init}}
+		},
+		InitPartition: initPartition,
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			st := state.(eventualState)
+			if ok, next := model.Step(st.current, input, output); ok {
+				if model.Equal(next, st.current) {
+					return true, state
+				}
+				past := st.past
+				if !contains(past, next) {
+					past = make([]interface{}, len(st.past), len(st.past)+1)
+					copy(past, st.past)
+					past = append(past, next)
+				}
+				return true, eventualState{current: next, past: past}
+			}
+			// a stale read
+			for _, s := range st.past {
+				if ok, next := model.Step(s, input, output); ok && model.Equal(next, s) {
+					return true, state
+				}
+			}
+			return false, state
+		},
+		// past states are deduplicated, so we don't need to check
+		// inclusion in both directions
+		Equal: func(state1, state2 interface{}) bool {
+			st1 := state1.(eventualState)
+			st2 := state2.(eventualState)
+			if !model.Equal(st1.current, st2.current) || len(st1.past) != len(st2.past) {
+				return false
+			}
+			for _, s := range st1.past {
+				if !contains(st2.past, s) {
+					return false
+				}
+			}
+			return true
+		},
+		EqualOutput:       model.EqualOutput,
+		DescribeOperation: model.DescribeOperation,
+		DescribeState: func(state interface{}) string {
+			st := state.(eventualState)
+			var descriptions []string
+			for _, s := range st.past {
+				descriptions = append(descriptions, model.DescribeState(s))
+			}
+			return fmt.Sprintf("%s (past: {%s})", model.DescribeState(st.current), strings.Join(descriptions, ", "))
+		},
+		DescribeTransition: describeTransition,
+	}
+}
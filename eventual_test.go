@@ -0,0 +1,52 @@
+package porcupine
+
+import "testing"
+
+func TestCheckEventualConsistency(t *testing.T) {
+	// the read observes a stale value
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{0, registerInput{false, 2}, 20, 0, 30},
+		{1, registerInput{true, 0}, 40, 1, 50},
+		{1, registerInput{true, 0}, 60, 0, 70},
+	}
+	if CheckOperations(registerModel, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	if !CheckEventualConsistency(registerModel, ops) {
+		t.Fatal("expected operations to be eventually consistent")
+	}
+
+	// the read observes a value that was never written
+	ops = []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 3, 30},
+	}
+	if CheckEventualConsistency(registerModel, ops) {
+		t.Fatal("expected operations not to be eventually consistent")
+	}
+
+	// the read observes a value before it was written
+	ops = []Operation{
+		{1, registerInput{true, 0}, 0, 1, 10},
+		{0, registerInput{false, 1}, 20, 0, 30},
+	}
+	if CheckEventualConsistency(registerModel, ops) {
+		t.Fatal("expected operations not to be eventually consistent")
+	}
+
+	// writes must still be linearizable: the append of "b" returns before
+	// the append of "c" is called
+	kvOps := []Operation{
+		{0, kvInput{op: 2, key: "x", value: "b"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 2, key: "x", value: "c"}, 20, kvOutput{}, 30},
+		{2, kvInput{op: 0, key: "x"}, 40, kvOutput{"cb"}, 50},
+	}
+	if CheckEventualConsistency(kvModel, kvOps) {
+		t.Fatal("expected operations not to be eventually consistent")
+	}
+	kvOps[2].Output = kvOutput{"b"}
+	if !CheckEventualConsistency(kvModel, kvOps) {
+		t.Fatal("expected operations to be eventually consistent")
+	}
+}
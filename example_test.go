@@ -0,0 +1,109 @@
+package porcupine_test
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// An appendInput is the input of an operation on a key-value store that
+// supports gets and appends. Clients retry appends that time out, so a
+// retried append may be applied more than once; every attempt of the same
+// logical append has the same request id.
+type appendInput struct {
+	Append    bool // false => get, true => append
+	Key       string
+	Value     string
+	RequestId int // identifies a logical append across retries; ignored for gets
+}
+
+type appendOutput struct {
+	Value string // value that was read, for gets
+}
+
+type appendState struct {
+	value   string
+	applied map[int]bool // request ids of appends that have taken effect
+}
+
+// appendModel is a model of a single key, partitioned by key, that treats an
+// append whose request id has already been applied as a no-op, so a retry
+// that the system applies again is not reported as a violation.
+var appendModel = porcupine.Model{
+	Partition: func(history []porcupine.Operation) [][]porcupine.Operation {
+		m := make(map[string][]porcupine.Operation)
+		for _, v := range history {
+			key := v.Input.(appendInput).Key
+			m[key] = append(m[key], v)
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		ret := make([][]porcupine.Operation, 0, len(keys))
+		for _, k := range keys {
+			ret = append(ret, m[k])
+		}
+		return ret
+	},
+	Init: func() interface{} {
+		return appendState{applied: make(map[int]bool)}
+	},
+	Step: func(state, input, output interface{}) (bool, interface{}) {
+		inp := input.(appendInput)
+		st := state.(appendState)
+		if !inp.Append {
+			return output.(appendOutput).Value == st.value, state
+		}
+		if st.applied[inp.RequestId] {
+			// a retry of an append that has already taken effect
+			return true, state
+		}
+		applied := make(map[int]bool, len(st.applied)+1)
+		for id := range st.applied {
+			applied[id] = true
+		}
+		applied[inp.RequestId] = true
+		return true, appendState{value: st.value + inp.Value, applied: applied}
+	},
+	Equal: func(state1, state2 interface{}) bool {
+		return reflect.DeepEqual(state1, state2)
+	},
+	DescribeOperation: func(input, output interface{}) string {
+		inp := input.(appendInput)
+		if !inp.Append {
+			return fmt.Sprintf("get('%s') -> '%s'", inp.Key, output.(appendOutput).Value)
+		}
+		return fmt.Sprintf("append('%s', '%s') #%d", inp.Key, inp.Value, inp.RequestId)
+	},
+	DescribeState: func(state interface{}) string {
+		return fmt.Sprintf("'%s'", state.(appendState).value)
+	},
+}
+
+// This example shows a model with idempotent writes, for a system whose
+// clients retry writes, which may then be applied more than once.
+func Example_idempotentWrites() {
+	// client 0's append timed out and was retried, and both attempts
+	// were applied, but they are the same logical append
+	retried := []porcupine.Operation{
+		{ClientId: 0, Input: appendInput{Append: true, Key: "x", Value: "a", RequestId: 1}, Call: 0, Output: appendOutput{}, Return: 10},
+		{ClientId: 0, Input: appendInput{Append: true, Key: "x", Value: "a", RequestId: 1}, Call: 20, Output: appendOutput{}, Return: 30},
+		{ClientId: 1, Input: appendInput{Key: "x"}, Call: 40, Output: appendOutput{Value: "a"}, Return: 50},
+	}
+	fmt.Println(porcupine.CheckOperations(appendModel, retried))
+
+	// distinct appends of the same value both take effect
+	distinct := []porcupine.Operation{
+		{ClientId: 0, Input: appendInput{Append: true, Key: "x", Value: "a", RequestId: 1}, Call: 0, Output: appendOutput{}, Return: 10},
+		{ClientId: 0, Input: appendInput{Append: true, Key: "x", Value: "a", RequestId: 2}, Call: 20, Output: appendOutput{}, Return: 30},
+		{ClientId: 1, Input: appendInput{Key: "x"}, Call: 40, Output: appendOutput{Value: "a"}, Return: 50},
+	}
+	fmt.Println(porcupine.CheckOperations(appendModel, distinct))
+	// Output:
+	// true
+	// false
+}
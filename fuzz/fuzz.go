@@ -0,0 +1,88 @@
+// Package fuzz uses porcupine models as oracles for Go's native fuzzer.
+//
+// It is separate from package porcupine so that programs that import
+// porcupine don't link the testing package.
+package fuzz
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// A Target describes a system under test for [Harness].
+type Target struct {
+	// Number of concurrent clients. If zero, a single client is used.
+	Clients int
+	// Decodes a single input from the beginning of data, returning the
+	// input and the remaining data. It should return false if data does
+	// not contain a complete input.
+	DecodeInput func(data []byte) (input interface{}, rest []byte, ok bool)
+	// Creates a fresh instance of the system under test, returning a
+	// function that executes an input on it and returns the output. The
+	// returned function is called concurrently by multiple clients.
+	New func() func(clientId int, input interface{}) interface{}
+	// Timeout for checking each history. A timeout of 0 is interpreted as
+	// an unlimited timeout.
+	Timeout time.Duration
+}
+
+// Harness returns a function that can be passed to [testing.F.Fuzz] to use a
+// model as an oracle for Go's native fuzzer.
+//
+// For each fuzz input, the function decodes a sequence of operations: the
+// first byte of each operation selects the client that executes it, and the
+// rest is decoded using the target's DecodeInput function. It creates a new
+// instance of the system using the target's New function, executes each
+// client's operations concurrently (the operations of each client are
+// executed sequentially, in order), records the resulting history with a
+// [porcupine.Recorder], and fails the test if the history is not
+// linearizable with respect to the model, with an error that describes the
+// operations that can't be linearized (see [porcupine.CheckOperationsError]).
+func Harness(model porcupine.Model, target Target) func(t *testing.T, data []byte) {
+	return func(t *testing.T, data []byte) {
+		t.Helper()
+		history := record(target, data)
+		if err := porcupine.CheckOperationsError(model, history, target.Timeout); err != nil && err != porcupine.ErrUnknown {
+			t.Fatal(err)
+		}
+	}
+}
+
+// record decodes operations from data, executes them using the target, and
+// returns the resulting history.
+func record(target Target, data []byte) []porcupine.Operation {
+	clients := target.Clients
+	if clients <= 0 {
+		clients = 1
+	}
+	inputs := make([][]interface{}, clients)
+	for len(data) > 0 {
+		client := int(data[0]) % clients
+		input, rest, ok := target.DecodeInput(data[1:])
+		if !ok {
+			break
+		}
+		inputs[client] = append(inputs[client], input)
+		data = rest
+	}
+	execute := target.New()
+	recorder := porcupine.NewRecorder()
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for _, input := range inputs[c] {
+				input := input
+				recorder.Record(c, input, func() interface{} {
+					return execute(c, input)
+				})
+			}
+		}(c)
+	}
+	wg.Wait()
+	return recorder.History()
+}
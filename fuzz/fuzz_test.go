@@ -0,0 +1,86 @@
+package fuzz
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+)
+
+type registerInput struct {
+	op    bool // false = put, true = get
+	value int
+}
+
+var registerModel = porcupine.Model{
+	Init: func() interface{} {
+		return 0
+	},
+	Step: func(state, input, output interface{}) (bool, interface{}) {
+		regInput := input.(registerInput)
+		if !regInput.op {
+			return true, regInput.value
+		}
+		return output == state, state
+	},
+}
+
+// decodes a register operation from a single byte: the low bit selects put
+// or get, and the rest is the value to put
+func decodeRegisterInput(data []byte) (interface{}, []byte, bool) {
+	if len(data) == 0 {
+		return nil, data, false
+	}
+	return registerInput{op: data[0]&1 == 1, value: int(data[0] >> 1)}, data[1:], true
+}
+
+func registerTarget(buggy bool) Target {
+	return Target{
+		Clients:     3,
+		DecodeInput: decodeRegisterInput,
+		New: func() func(clientId int, input interface{}) interface{} {
+			var mu sync.Mutex
+			value := 0
+			first := -1
+			return func(clientId int, input interface{}) interface{} {
+				mu.Lock()
+				defer mu.Unlock()
+				inp := input.(registerInput)
+				if !inp.op {
+					value = inp.value
+					if first < 0 {
+						first = inp.value
+					}
+					return 0
+				}
+				if buggy && first >= 0 {
+					// returns the first value ever written
+					return first
+				}
+				return value
+			}
+		},
+	}
+}
+
+func TestHarness(t *testing.T) {
+	inputs := [][]byte{
+		{},
+		{0, 10, 1, 1, 2, 1, 0, 7, 1, 1, 2, 20, 0, 1},
+		{0, 200, 1, 201, 2, 100, 0, 1, 1, 1, 2, 1},
+	}
+	harness := Harness(registerModel, registerTarget(false))
+	for _, data := range inputs {
+		harness(t, data)
+	}
+
+	// client 0 writes 5 and then 7, and then reads
+	data := []byte{0, 10, 0, 14, 0, 1}
+	history := record(registerTarget(false), data)
+	if len(history) != 3 || history[2].Output != 7 {
+		t.Fatalf("unexpected history %v", history)
+	}
+	if history := record(registerTarget(true), data); porcupine.CheckOperations(registerModel, history) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
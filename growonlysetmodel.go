@@ -0,0 +1,95 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// GrowOnlySetModel returns a model of a grow-only set (G-Set), a conflict-free
+// replicated data type that supports adding and reading elements.
+//
+// Like a [SetModel] without removal, adding an element puts it in the set,
+// but a read may return any subset of the elements that were added before
+// it, not necessarily all of them, because a replica may not have received
+// every add yet. This is weaker than linearizability: a read can miss an
+// element whose add returned before the read was called. A read still can't
+// observe an element that was never added, or one whose add was called
+// after the read returned. A read that failed, e.g., because of a timeout,
+// can set Unknown in its output, in which case it is consistent with any
+// state. Elements must be comparable using ==.
+//
+// Inputs must be of type [SetInput], with Op SetAdd or SetRead, and outputs
+// must be of type [SetOutput].
+func GrowOnlySetModel() Model {
+	return Model{
+		Init: func() interface{} {
+			return map[interface{}]struct{}{}
+		},
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			st := state.(map[interface{}]struct{})
+			inp := input.(SetInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(SetOutput)
+			switch inp.Op {
+			case SetAdd:
+				if _, ok := st[inp.Value]; ok {
+					return true, state
+				}
+				newSt := make(map[interface{}]struct{}, len(st)+1)
+				for v := range st {
+					newSt[v] = struct{}{}
+				}
+				newSt[inp.Value] = struct{}{}
+				return true, newSt
+			case SetRead:
+				if pending || out.Unknown {
+					return true, state
+				}
+				seen := make(map[interface{}]struct{}, len(out.Values))
+				for _, v := range out.Values {
+					if _, ok := st[v]; !ok {
+						return false, state
+					}
+					if _, ok := seen[v]; ok {
+						return false, state
+					}
+					seen[v] = struct{}{}
+				}
+				return true, state
+			}
+			return false, state
+		},
+		Equal: func(state1, state2 interface{}) bool {
+			return reflect.DeepEqual(state1, state2)
+		},
+		DescribeOperation: func(input, output interface{}) string {
+			inp := input.(SetInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(SetOutput)
+			switch inp.Op {
+			case SetAdd:
+				if pending {
+					return fmt.Sprintf("add(%v) -> pending", inp.Value)
+				}
+				return fmt.Sprintf("add(%v)", inp.Value)
+			case SetRead:
+				if pending {
+					return "read() -> pending"
+				}
+				if out.Unknown {
+					return "read() -> unknown"
+				}
+				return fmt.Sprintf("read() -> %s", describeSet(out.Values))
+			}
+			return "<invalid>"
+		},
+		DescribeState: func(state interface{}) string {
+			st := state.(map[interface{}]struct{})
+			values := make([]interface{}, 0, len(st))
+			for v := range st {
+				values = append(values, v)
+			}
+			return describeSet(values)
+		},
+	}
+}
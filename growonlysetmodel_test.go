@@ -0,0 +1,56 @@
+package porcupine
+
+import "testing"
+
+func TestGrowOnlySetModel(t *testing.T) {
+	model := GrowOnlySetModel()
+	// the second read misses 2, even though its add returned before the
+	// read was called, as if the read went to a replica that hadn't
+	// received the add yet
+	ops := []Operation{
+		{0, SetInput{SetAdd, 1}, 0, SetOutput{}, 10},
+		{1, SetInput{SetAdd, 2}, 20, SetOutput{}, 30},
+		{2, SetInput{SetRead, nil}, 40, SetOutput{Values: []interface{}{2, 1}}, 50},
+		{3, SetInput{SetRead, nil}, 60, SetOutput{Values: []interface{}{1}}, 70},
+		{3, SetInput{SetRead, nil}, 80, SetOutput{Unknown: true}, 90},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+	// but it's not linearizable
+	if CheckOperations(SetModel(), ops) {
+		t.Fatal("expected operations not to be linearizable as a set")
+	}
+
+	// a read can't observe an element that was added after it returned
+	ops = []Operation{
+		{0, SetInput{SetRead, nil}, 0, SetOutput{Values: []interface{}{1}}, 10},
+		{1, SetInput{SetAdd, 1}, 20, SetOutput{}, 30},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be valid")
+	}
+
+	// or an element that was never added, or an element twice
+	ops = []Operation{
+		{0, SetInput{SetAdd, 1}, 0, SetOutput{}, 10},
+		{1, SetInput{SetRead, nil}, 20, SetOutput{Values: []interface{}{2}}, 30},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be valid")
+	}
+	ops[1].Output = SetOutput{Values: []interface{}{1, 1}}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be valid")
+	}
+
+	// removal isn't supported
+	ops = []Operation{
+		{0, SetInput{SetRemove, 1}, 0, SetOutput{}, 10},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected removal to be rejected")
+	}
+}
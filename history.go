@@ -0,0 +1,175 @@
+package porcupine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// InterleaveEvents derives a global order for a history where only the order
+// of events within each client is known, e.g., because clients record their
+// histories independently without synchronized clocks.
+//
+// The i-th element of clients is the sequence of events of a single client,
+// which must alternate between a call and the matching return, optionally
+// ending with a call that has no return (see [PendingOutput]). The result can
+// be checked with functions like [CheckEvents].
+//
+// Because the relative order of events from different clients is unknown,
+// any global order introduces real-time constraints between operations of
+// different clients that might not hold in reality, so a history that is
+// actually linearizable could be reported as Illegal. To minimize this, the
+// derived order makes operations overlap as much as possible: each call is
+// placed as early as possible, and each return is placed as late as
+// possible, with clients taking turns returning.
+func InterleaveEvents(clients [][]Event) ([]Event, error) {
+	ids := make(map[int]int) // id -> client
+	total := 0
+	for c, events := range clients {
+		for i, e := range events {
+			if i%2 == 0 {
+				if e.Kind != CallEvent {
+					return nil, fmt.Errorf("client %d: event %d: expected a call event", c, i)
+				}
+				if other, ok := ids[e.Id]; ok {
+					return nil, fmt.Errorf("client %d: event %d: id %d is already used by client %d", c, i, e.Id, other)
+				}
+				ids[e.Id] = c
+			} else {
+				if e.Kind != ReturnEvent {
+					return nil, fmt.Errorf("client %d: event %d: expected a return event", c, i)
+				}
+				if e.Id != events[i-1].Id {
+					return nil, fmt.Errorf("client %d: event %d: return id %d does not match call id %d", c, i, e.Id, events[i-1].Id)
+				}
+			}
+		}
+		total += len(events)
+	}
+	next := make([]int, len(clients))
+	result := make([]Event, 0, total)
+	turn := 0
+	for len(result) < total {
+		// place all calls that can be placed
+		for c, events := range clients {
+			if next[c] < len(events) && events[next[c]].Kind == CallEvent {
+				result = append(result, events[next[c]])
+				next[c]++
+			}
+		}
+		// place a single return, from the next client (in turn) that
+		// has one
+		for i := 0; i < len(clients); i++ {
+			c := (turn + i) % len(clients)
+			if next[c] < len(clients[c]) && clients[c][next[c]].Kind == ReturnEvent {
+				result = append(result, clients[c][next[c]])
+				next[c]++
+				turn = c + 1
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// NormalizeClientIds remaps the ClientIds in a history, which may be sparse
+// or arbitrary identifiers such as process or goroutine ids, to contiguous
+// zero-indexed ClientIds, so that the visualization, which draws one lane per
+// client, is compact.
+//
+// ClientIds are assigned in increasing order of the original identifiers. It
+// returns a copy of the history with the new ClientIds, along with the
+// mapping from original identifiers to new ClientIds; the given history is
+// not modified.
+func NormalizeClientIds(history []Operation) ([]Operation, map[int]int) {
+	ids := make([]int, len(history))
+	for i, op := range history {
+		ids[i] = op.ClientId
+	}
+	mapping := normalizeIds(ids)
+	result := make([]Operation, len(history))
+	for i, op := range history {
+		op.ClientId = mapping[op.ClientId]
+		result[i] = op
+	}
+	return result, mapping
+}
+
+// NormalizeTimestamps rebases and scales the Call and Return times in a
+// history so that the earliest time is 0 and times are as small as possible,
+// e.g., for histories timestamped with [time.Time.UnixNano], whose huge
+// timestamps are unwieldy for tools such as the visualization.
+//
+// Times are shifted so that the earliest is 0 and then divided by the
+// greatest common divisor of all of the times, so the order of times and the
+// ratios of the gaps between them are preserved exactly, and the result of a
+// linearizability check is unchanged. It returns a copy of the history with
+// the new times, along with the divisor; the given history is not modified.
+func NormalizeTimestamps(history []Operation) ([]Operation, int64) {
+	if len(history) == 0 {
+		return []Operation{}, 1
+	}
+	earliest := history[0].Call
+	for _, op := range history {
+		if op.Call < earliest {
+			earliest = op.Call
+		}
+		if op.Return < earliest {
+			earliest = op.Return
+		}
+	}
+	var divisor int64
+	for _, op := range history {
+		divisor = gcd(divisor, op.Call-earliest)
+		divisor = gcd(divisor, op.Return-earliest)
+	}
+	if divisor == 0 {
+		// all times are the same
+		divisor = 1
+	}
+	result := make([]Operation, len(history))
+	for i, op := range history {
+		op.Call = (op.Call - earliest) / divisor
+		op.Return = (op.Return - earliest) / divisor
+		result[i] = op
+	}
+	return result, divisor
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// NormalizeClientIdsEvents is like [NormalizeClientIds], but for a history
+// of [Event].
+func NormalizeClientIdsEvents(history []Event) ([]Event, map[int]int) {
+	ids := make([]int, len(history))
+	for i, e := range history {
+		ids[i] = e.ClientId
+	}
+	mapping := normalizeIds(ids)
+	result := make([]Event, len(history))
+	for i, e := range history {
+		e.ClientId = mapping[e.ClientId]
+		result[i] = e
+	}
+	return result, mapping
+}
+
+func normalizeIds(ids []int) map[int]int {
+	mapping := make(map[int]int)
+	var unique []int
+	for _, id := range ids {
+		if _, ok := mapping[id]; !ok {
+			mapping[id] = 0
+			unique = append(unique, id)
+		}
+	}
+	sort.Ints(unique)
+	for i, id := range unique {
+		mapping[id] = i
+	}
+	return mapping
+}
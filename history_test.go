@@ -0,0 +1,138 @@
+package porcupine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterleaveEvents(t *testing.T) {
+	clients := [][]Event{
+		{
+			{0, CallEvent, registerInput{false, 100}, 0},
+			{0, ReturnEvent, 0, 0},
+			{0, CallEvent, registerInput{true, 0}, 1},
+			{0, ReturnEvent, 100, 1},
+		},
+		{
+			{1, CallEvent, registerInput{true, 0}, 2},
+			{1, ReturnEvent, 0, 2},
+			{1, CallEvent, registerInput{true, 0}, 3},
+			{1, ReturnEvent, 100, 3},
+		},
+	}
+	events, err := InterleaveEvents(clients)
+	if err != nil {
+		t.Fatalf("failed to interleave events: %v", err)
+	}
+	expected := []Event{
+		clients[0][0],
+		clients[1][0],
+		clients[0][1],
+		clients[0][2],
+		clients[1][1],
+		clients[1][2],
+		clients[0][3],
+		clients[1][3],
+	}
+	if !reflect.DeepEqual(expected, events) {
+		t.Fatalf("expected events to be \n%v\n, was \n%v", expected, events)
+	}
+	if !CheckEvents(registerModel, events) {
+		t.Fatal("expected operations to be linearizable")
+	}
+
+	// a pending call at the end
+	clients[1] = clients[1][:3]
+	events, err = InterleaveEvents(clients)
+	if err != nil {
+		t.Fatalf("failed to interleave events: %v", err)
+	}
+	if len(events) != 7 {
+		t.Fatalf("expected 7 events, got %d", len(events))
+	}
+
+	// malformed histories
+	for _, clients := range [][][]Event{
+		{{{0, ReturnEvent, 0, 0}}},
+		{{{0, CallEvent, registerInput{true, 0}, 0}, {0, CallEvent, registerInput{true, 0}, 1}}},
+		{{{0, CallEvent, registerInput{true, 0}, 0}, {0, ReturnEvent, 0, 1}}},
+		{{{0, CallEvent, registerInput{true, 0}, 0}}, {{1, CallEvent, registerInput{true, 0}, 0}}},
+	} {
+		if _, err := InterleaveEvents(clients); err == nil {
+			t.Fatalf("expected error interleaving %v", clients)
+		}
+	}
+}
+
+func TestNormalizeClientIds(t *testing.T) {
+	ops := []Operation{
+		{4021, registerInput{false, 100}, 0, 0, 100},
+		{17, registerInput{true, 0}, 25, 100, 75},
+		{4021, registerInput{true, 0}, 110, 100, 120},
+		{-3, registerInput{true, 0}, 30, 0, 60},
+	}
+	normalized, mapping := NormalizeClientIds(ops)
+	expectedMapping := map[int]int{-3: 0, 17: 1, 4021: 2}
+	if !reflect.DeepEqual(mapping, expectedMapping) {
+		t.Fatalf("expected mapping %v, got %v", expectedMapping, mapping)
+	}
+	var clients []int
+	for _, op := range normalized {
+		clients = append(clients, op.ClientId)
+	}
+	if expected := []int{2, 1, 2, 0}; !reflect.DeepEqual(clients, expected) {
+		t.Fatalf("expected client ids %v, got %v", expected, clients)
+	}
+	if ops[0].ClientId != 4021 {
+		t.Fatal("history was modified")
+	}
+	if !CheckOperations(registerModel, normalized) {
+		t.Fatal("expected operations to be linearizable")
+	}
+
+	events := []Event{
+		{9, CallEvent, registerInput{false, 100}, 0},
+		{5, CallEvent, registerInput{true, 0}, 1},
+		{5, ReturnEvent, 100, 1},
+		{9, ReturnEvent, 0, 0},
+	}
+	normalizedEvents, mapping := NormalizeClientIdsEvents(events)
+	if expected := (map[int]int{5: 0, 9: 1}); !reflect.DeepEqual(mapping, expected) {
+		t.Fatalf("expected mapping %v, got %v", expected, mapping)
+	}
+	if normalizedEvents[0].ClientId != 1 || normalizedEvents[1].ClientId != 0 {
+		t.Fatalf("unexpected normalized events %v", normalizedEvents)
+	}
+}
+
+func TestNormalizeTimestamps(t *testing.T) {
+	base := int64(1700000000000000000)
+	ops := []Operation{
+		{0, registerInput{false, 100}, base, 0, base + 100000},
+		{1, registerInput{true, 0}, base + 25000, 100, base + 75000},
+		{2, registerInput{true, 0}, base + 30000, 0, base + 60000},
+	}
+	normalized, divisor := NormalizeTimestamps(ops)
+	if divisor != 5000 {
+		t.Fatalf("expected divisor 5000, got %d", divisor)
+	}
+	var times []int64
+	for _, op := range normalized {
+		times = append(times, op.Call, op.Return)
+	}
+	if expected := []int64{0, 20, 5, 15, 6, 12}; !reflect.DeepEqual(times, expected) {
+		t.Fatalf("expected times %v, got %v", expected, times)
+	}
+	if ops[0].Call != base {
+		t.Fatal("history was modified")
+	}
+	if CheckOperations(registerModel, normalized) != CheckOperations(registerModel, ops) {
+		t.Fatal("expected normalization not to change the result")
+	}
+
+	// all times are the same
+	normalized, divisor = NormalizeTimestamps([]Operation{{0, registerInput{true, 0}, base, 0, base}})
+	if divisor != 1 || normalized[0].Call != 0 || normalized[0].Return != 0 {
+		t.Fatalf("unexpected normalized history %v with divisor %d", normalized, divisor)
+	}
+}
@@ -0,0 +1,180 @@
+package porcupine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+type historyLogEntry struct {
+	Kind     string // "call" or "return"
+	ClientId int
+	Id       int
+	Value    json.RawMessage
+}
+
+// A HistoryWriter records a history of [Event] as it happens, e.g., while a
+// test is running, writing it to a log that can be read back using a
+// [HistoryReader].
+//
+// This is useful for long tests, where keeping the entire history in memory
+// is infeasible. Every event is written to the underlying writer as a single
+// line of JSON, using a single call to Write, so if the test crashes, the
+// log contains every event that was recorded before the crash (and possibly
+// a truncated last line). Inputs and outputs must be encodable using
+// [encoding/json].
+//
+// A HistoryWriter is safe for concurrent use by multiple goroutines.
+type HistoryWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	nextId  int
+	clients map[int]int // id -> client, for pending calls
+}
+
+// NewHistoryWriter returns a [HistoryWriter] that writes to w.
+func NewHistoryWriter(w io.Writer) *HistoryWriter {
+	return &HistoryWriter{w: w, clients: make(map[int]int)}
+}
+
+// Call records a call event for the given client with the given input,
+// returning the id to pass to [HistoryWriter.Return] when the operation
+// returns.
+func (hw *HistoryWriter) Call(clientId int, input interface{}) (int, error) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	id := hw.nextId
+	if err := hw.write("call", clientId, id, input); err != nil {
+		return 0, err
+	}
+	hw.nextId++
+	hw.clients[id] = clientId
+	return id, nil
+}
+
+// Return records the return event, with the given output, for the call with
+// the given id.
+func (hw *HistoryWriter) Return(id int, output interface{}) error {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	clientId, ok := hw.clients[id]
+	if !ok {
+		return fmt.Errorf("no pending call with id %d", id)
+	}
+	if err := hw.write("return", clientId, id, output); err != nil {
+		return err
+	}
+	delete(hw.clients, id)
+	return nil
+}
+
+func (hw *HistoryWriter) write(kind string, clientId, id int, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(historyLogEntry{Kind: kind, ClientId: clientId, Id: id, Value: data})
+	if err != nil {
+		return err
+	}
+	_, err = hw.w.Write(append(line, '\n'))
+	return err
+}
+
+// A HistoryReader reads a history that was recorded using a
+// [HistoryWriter].
+type HistoryReader struct {
+	r io.Reader
+	// Functions to decode the JSON encoding of inputs and outputs. If left
+	// nil, values are decoded using [json.Unmarshal] into an interface{}.
+	DecodeInput  func(data []byte) (interface{}, error)
+	DecodeOutput func(data []byte) (interface{}, error)
+}
+
+// NewHistoryReader returns a [HistoryReader] that reads from r, using the
+// given functions to decode inputs and outputs (see the fields of
+// [HistoryReader]).
+func NewHistoryReader(r io.Reader, decodeInput, decodeOutput func(data []byte) (interface{}, error)) *HistoryReader {
+	return &HistoryReader{r: r, DecodeInput: decodeInput, DecodeOutput: decodeOutput}
+}
+
+// ReadEvents reads the entire history.
+//
+// If the log was truncated, e.g., because the test crashed while writing
+// it, a malformed last line is ignored. Operations that were called but
+// never returned are left without a return event, so the checker treats
+// them as pending (see [PendingOutput]). It returns an error if the log
+// reuses the id of an earlier call, even one that has returned.
+func (hr *HistoryReader) ReadEvents() ([]Event, error) {
+	decodeInput := hr.DecodeInput
+	if decodeInput == nil {
+		decodeInput = decodeJSONValue
+	}
+	decodeOutput := hr.DecodeOutput
+	if decodeOutput == nil {
+		decodeOutput = decodeJSONValue
+	}
+	var events []Event
+	called := make(map[int]struct{}) // ids of all calls, so ids can't be reused
+	pending := make(map[int]struct{})
+	br := bufio.NewReader(hr.r)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		eof := err == io.EOF
+		if len(bytes.TrimSpace(line)) == 0 {
+			if eof {
+				break
+			}
+			continue
+		}
+		var entry historyLogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if eof {
+				// truncated last line
+				break
+			}
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		switch entry.Kind {
+		case "call":
+			if _, ok := called[entry.Id]; ok {
+				return nil, fmt.Errorf("line %d: duplicate call with id %d", lineNumber, entry.Id)
+			}
+			value, err := decodeInput(entry.Value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			called[entry.Id] = struct{}{}
+			pending[entry.Id] = struct{}{}
+			events = append(events, Event{ClientId: entry.ClientId, Kind: CallEvent, Value: value, Id: entry.Id})
+		case "return":
+			if _, ok := pending[entry.Id]; !ok {
+				return nil, fmt.Errorf("line %d: no pending call with id %d", lineNumber, entry.Id)
+			}
+			value, err := decodeOutput(entry.Value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			delete(pending, entry.Id)
+			events = append(events, Event{ClientId: entry.ClientId, Kind: ReturnEvent, Value: value, Id: entry.Id})
+		default:
+			return nil, fmt.Errorf("line %d: unknown event kind %q", lineNumber, entry.Kind)
+		}
+		if eof {
+			break
+		}
+	}
+	return events, nil
+}
+
+func decodeJSONValue(data []byte) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
@@ -0,0 +1,92 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decodeLogInput(data []byte) (interface{}, error) {
+	var inp LogInput
+	err := json.Unmarshal(data, &inp)
+	return inp, err
+}
+
+func decodeLogOutput(data []byte) (interface{}, error) {
+	var out LogOutput
+	err := json.Unmarshal(data, &out)
+	return out, err
+}
+
+func TestHistoryWriterReader(t *testing.T) {
+	var b bytes.Buffer
+	hw := NewHistoryWriter(&b)
+	a, _ := hw.Call(0, LogInput{Op: LogAppend, Topic: "a", Value: "x"})
+	r, _ := hw.Call(1, LogInput{Op: LogReadLatest, Topic: "a"})
+	if err := hw.Return(a, LogOutput{Offset: 0}); err != nil {
+		t.Fatalf("failed to record return: %v", err)
+	}
+	if err := hw.Return(r, LogOutput{Value: "x", Exists: true}); err != nil {
+		t.Fatalf("failed to record return: %v", err)
+	}
+	if err := hw.Return(r, LogOutput{}); err == nil {
+		t.Fatal("expected error recording a return twice")
+	}
+
+	events, err := NewHistoryReader(bytes.NewReader(b.Bytes()), decodeLogInput, decodeLogOutput).ReadEvents()
+	if err != nil {
+		t.Fatalf("failed to read history: %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	if events[3].ClientId != 1 || events[3].Value != (LogOutput{Value: "x", Exists: true}) {
+		t.Fatalf("unexpected event %v", events[3])
+	}
+	if !CheckEvents(LogModel(), events) {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
+
+func TestHistoryReaderTruncated(t *testing.T) {
+	var b bytes.Buffer
+	hw := NewHistoryWriter(&b)
+	a, _ := hw.Call(0, LogInput{Op: LogAppend, Topic: "a", Value: "x"})
+	hw.Return(a, LogOutput{Offset: 0})
+	hw.Call(0, LogInput{Op: LogAppend, Topic: "a", Value: "y"})
+	r, _ := hw.Call(1, LogInput{Op: LogReadLatest, Topic: "a"})
+	hw.Return(r, LogOutput{Value: "y", Exists: true})
+	// crash while writing the return of the read
+	log := b.Bytes()[:b.Len()-10]
+
+	events, err := NewHistoryReader(bytes.NewReader(log), decodeLogInput, decodeLogOutput).ReadEvents()
+	if err != nil {
+		t.Fatalf("failed to read history: %v", err)
+	}
+	// the second append and the read are pending
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	if events[2].Kind != CallEvent || events[3].Kind != CallEvent {
+		t.Fatalf("expected pending calls, got %v", events[2:])
+	}
+
+	// malformed lines that are not the last line are an error
+	log = append([]byte("{\n"), b.Bytes()...)
+	if _, err := NewHistoryReader(bytes.NewReader(log), nil, nil).ReadEvents(); err == nil {
+		t.Fatal("expected error reading malformed history")
+	}
+}
+
+func TestHistoryReaderReusedId(t *testing.T) {
+	log := `{"Kind":"call","ClientId":0,"Id":0,"Value":1}
+{"Kind":"return","ClientId":0,"Id":0,"Value":null}
+{"Kind":"call","ClientId":1,"Id":0,"Value":2}
+{"Kind":"return","ClientId":1,"Id":0,"Value":null}
+`
+	_, err := NewHistoryReader(strings.NewReader(log), nil, nil).ReadEvents()
+	if err == nil || err.Error() != "line 3: duplicate call with id 0" {
+		t.Fatalf("expected error for reused id, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package porcupine
+
+import "fmt"
+
+// A LeaseOp is the kind of an operation on a lease.
+type LeaseOp int
+
+const (
+	LeaseAcquire LeaseOp = iota // acquire the lease for a new term
+	LeaseRenew                  // extend the lease, by its current owner
+	LeaseRelease                // give up the lease, by its current owner
+	LeaseRead                   // read the current owner and term
+)
+
+// A LeaseInput is the input of an operation for the model returned by
+// [LeaseModel].
+//
+// Time is the time at which the operation takes effect, in the same units
+// as Duration, e.g., the time at which the server decided the operation,
+// according to its clock. If the system doesn't report this, the Return time
+// of the operation is a lenient choice for LeaseAcquire (a lease can expire
+// at any point before the acquire returns) and the Call time is a lenient
+// choice for LeaseRenew (a lease must not expire before the renewal starts).
+type LeaseInput struct {
+	Op       LeaseOp
+	Owner    string // owner that acquires, renews, or releases the lease
+	Term     int    // term of the lease, for LeaseAcquire, LeaseRenew, and LeaseRelease
+	Time     int64  // time at which the operation takes effect, for LeaseAcquire, LeaseRenew, and LeaseRead
+	Duration int64  // how long the lease lasts from Time, for LeaseAcquire and LeaseRenew
+}
+
+// A LeaseOutput is the output of an operation for the model returned by
+// [LeaseModel].
+type LeaseOutput struct {
+	Ok    bool   // whether the operation succeeded, for LeaseAcquire, LeaseRenew, and LeaseRelease
+	Owner string // current owner, or "" if there is none, for LeaseRead
+	Term  int    // current term, for LeaseRead
+}
+
+type leaseState struct {
+	owner  string // "" if the lease was released or never acquired
+	term   int
+	expiry int64
+}
+
+// held returns whether the lease is held by some owner at the given time.
+func (s leaseState) held(time int64) bool {
+	return s.owner != "" && time < s.expiry
+}
+
+// LeaseModel returns a model of a lease, e.g., for lease-based leader
+// election, which tracks the current owner of the lease, its term, and when
+// it expires.
+//
+// Acquiring the lease succeeds if and only if the term is greater than the
+// current term and the lease is not held, i.e., it was never acquired, it
+// was released, or it has expired; the lease then belongs to the given owner
+// for the given term, until Time + Duration. Renewing the lease succeeds if
+// and only if it is held by the given owner for the given term, extending it
+// until Time + Duration. Releasing the lease succeeds if and only if the
+// given owner has the current term, even if the lease has expired. An
+// operation that does not succeed (i.e., its output's Ok is false) must have
+// observed a state in which it can't succeed, and it doesn't change the
+// lease. Reading returns the owner and term of the lease, with an owner of
+// "" if the lease is not held at the given time.
+//
+// Inputs must be of type [LeaseInput] and outputs must be of type
+// [LeaseOutput].
+func LeaseModel() Model {
+	return Model{
+		Init: func() interface{} {
+			return leaseState{}
+		},
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			st := state.(leaseState)
+			inp := input.(LeaseInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(LeaseOutput)
+			switch inp.Op {
+			case LeaseAcquire:
+				possible := inp.Term > st.term && !st.held(inp.Time)
+				if pending {
+					// succeeds if and only if it's possible
+					out.Ok = possible
+				}
+				if !out.Ok || !possible {
+					return !out.Ok && !possible, state
+				}
+				return true, leaseState{owner: inp.Owner, term: inp.Term, expiry: inp.Time + inp.Duration}
+			case LeaseRenew:
+				possible := st.owner == inp.Owner && st.term == inp.Term && st.held(inp.Time)
+				if pending {
+					out.Ok = possible
+				}
+				if !out.Ok || !possible {
+					return !out.Ok && !possible, state
+				}
+				return true, leaseState{owner: st.owner, term: st.term, expiry: inp.Time + inp.Duration}
+			case LeaseRelease:
+				possible := st.owner != "" && st.owner == inp.Owner && st.term == inp.Term
+				if pending {
+					out.Ok = possible
+				}
+				if !out.Ok || !possible {
+					return !out.Ok && !possible, state
+				}
+				return true, leaseState{term: st.term}
+			case LeaseRead:
+				if pending {
+					return true, state
+				}
+				owner := ""
+				if st.held(inp.Time) {
+					owner = st.owner
+				}
+				return out.Owner == owner && out.Term == st.term, state
+			}
+			return false, state
+		},
+		DescribeOperation: func(input, output interface{}) string {
+			inp := input.(LeaseInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(LeaseOutput)
+			result := "ok"
+			if pending {
+				result = "pending"
+			} else if !out.Ok {
+				result = "failed"
+			}
+			switch inp.Op {
+			case LeaseAcquire:
+				return fmt.Sprintf("acquire(%q, term %d, until %d) -> %s", inp.Owner, inp.Term, inp.Time+inp.Duration, result)
+			case LeaseRenew:
+				return fmt.Sprintf("renew(%q, term %d, until %d) -> %s", inp.Owner, inp.Term, inp.Time+inp.Duration, result)
+			case LeaseRelease:
+				return fmt.Sprintf("release(%q, term %d) -> %s", inp.Owner, inp.Term, result)
+			case LeaseRead:
+				if pending {
+					return fmt.Sprintf("read(at %d) -> pending", inp.Time)
+				}
+				return fmt.Sprintf("read(at %d) -> %q, term %d", inp.Time, out.Owner, out.Term)
+			}
+			return "<invalid>"
+		},
+		DescribeState: func(state interface{}) string {
+			st := state.(leaseState)
+			if st.owner == "" {
+				return fmt.Sprintf("no owner, term %d", st.term)
+			}
+			return fmt.Sprintf("%q, term %d, until %d", st.owner, st.term, st.expiry)
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package porcupine
+
+import "testing"
+
+func leaseAcquire(owner string, term int, time int64, ok bool) (LeaseInput, LeaseOutput) {
+	return LeaseInput{Op: LeaseAcquire, Owner: owner, Term: term, Time: time, Duration: 100}, LeaseOutput{Ok: ok}
+}
+
+func TestLeaseModelOverlappingAcquires(t *testing.T) {
+	model := LeaseModel()
+	in1, out1 := leaseAcquire("a", 1, 10, true)
+	in2, out2 := leaseAcquire("b", 2, 50, false) // a's lease hasn't expired
+	in3, out3 := leaseAcquire("c", 2, 150, true) // a's lease has expired
+	ops := []Operation{
+		{0, in1, 0, out1, 20},
+		{1, in2, 40, out2, 60},
+		{2, in3, 140, out3, 160},
+		{1, LeaseInput{Op: LeaseRead, Time: 170}, 165, LeaseOutput{Owner: "c", Term: 2}, 175},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// two overlapping acquires both succeed while the lease is held
+	in1, out1 = leaseAcquire("a", 1, 10, true)
+	in2, out2 = leaseAcquire("b", 2, 20, true)
+	ops = []Operation{
+		{0, in1, 0, out1, 30},
+		{1, in2, 5, out2, 35},
+	}
+	res, info = CheckOperationsVerbose(model, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// two overlapping acquires for the same term both succeed, after the
+	// lease has expired
+	in1, out1 = leaseAcquire("a", 1, 10, true)
+	in2, out2 = leaseAcquire("b", 2, 200, true)
+	in3, out3 = leaseAcquire("c", 2, 200, true)
+	ops = []Operation{
+		{0, in1, 0, out1, 20},
+		{1, in2, 190, out2, 210},
+		{2, in3, 190, out3, 210},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	// but one of them can fail
+	ops[2].Output = LeaseOutput{Ok: false}
+	if !CheckOperations(model, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
+
+func TestLeaseModelRenewRelease(t *testing.T) {
+	model := LeaseModel()
+	in1, out1 := leaseAcquire("a", 1, 10, true)
+	in2, out2 := leaseAcquire("b", 2, 150, false) // a renewed its lease
+	in3, out3 := leaseAcquire("b", 2, 250, true)  // a released its lease
+	ops := []Operation{
+		{0, in1, 0, out1, 20},
+		{0, LeaseInput{Op: LeaseRenew, Owner: "a", Term: 1, Time: 100, Duration: 100}, 95, LeaseOutput{Ok: true}, 105},
+		{1, in2, 140, out2, 160},
+		{0, LeaseInput{Op: LeaseRelease, Owner: "a", Term: 1}, 230, LeaseOutput{Ok: true}, 240},
+		{1, in3, 245, out3, 255},
+		{0, LeaseInput{Op: LeaseRelease, Owner: "a", Term: 1}, 260, LeaseOutput{Ok: false}, 270},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// renewing an expired lease
+	ops = []Operation{
+		{0, in1, 0, out1, 20},
+		{0, LeaseInput{Op: LeaseRenew, Owner: "a", Term: 1, Time: 120, Duration: 100}, 115, LeaseOutput{Ok: true}, 125},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+
+	// releasing a lease that belongs to someone else
+	ops = []Operation{
+		{0, in1, 0, out1, 20},
+		{1, LeaseInput{Op: LeaseRelease, Owner: "b", Term: 1}, 30, LeaseOutput{Ok: true}, 40},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
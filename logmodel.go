@@ -0,0 +1,162 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A LogOp is the kind of an operation on an append-only log.
+type LogOp int
+
+const (
+	LogAppend     LogOp = iota // append a value, returning its offset
+	LogRead                    // read the value at an offset
+	LogReadLatest              // read the last value
+)
+
+// A LogInput is the input of an operation for the model returned by
+// [LogModel].
+type LogInput struct {
+	Op     LogOp
+	Topic  string
+	Value  interface{} // value to append, for LogAppend
+	Offset int         // offset to read, for LogRead
+}
+
+// A LogOutput is the output of an operation for the model returned by
+// [LogModel].
+type LogOutput struct {
+	Offset int         // offset of the appended value, for LogAppend
+	Value  interface{} // value that was read, for LogRead and LogReadLatest
+	Exists bool        // whether there was a value to read, for LogRead and LogReadLatest
+}
+
+// LogModel returns a model of a set of append-only logs, such as a Kafka-like
+// message queue, partitioned by topic.
+//
+// Appending a value to a log returns the offset at which it was appended,
+// which must be the length of the log before the append. Reading an offset
+// returns the value at that offset, if it exists, and reading the latest
+// value returns the last value in the log, if the log is not empty. Values
+// are compared using [reflect.DeepEqual].
+//
+// Inputs must be of type [LogInput] and outputs must be of type [LogOutput].
+func LogModel() Model {
+	return Model{
+		Partition: func(history []Operation) [][]Operation {
+			m := make(map[string][]Operation)
+			for _, v := range history {
+				topic := v.Input.(LogInput).Topic
+				m[topic] = append(m[topic], v)
+			}
+			topics := make([]string, 0, len(m))
+			for topic := range m {
+				topics = append(topics, topic)
+			}
+			sort.Strings(topics)
+			ret := make([][]Operation, 0, len(topics))
+			for _, topic := range topics {
+				ret = append(ret, m[topic])
+			}
+			return ret
+		},
+		PartitionEvent: func(history []Event) [][]Event {
+			m := make(map[string][]Event)
+			match := make(map[int]string) // id -> topic
+			var topics []string
+			for _, v := range history {
+				if v.Kind == CallEvent {
+					topic := v.Value.(LogInput).Topic
+					if _, ok := m[topic]; !ok {
+						topics = append(topics, topic)
+					}
+					m[topic] = append(m[topic], v)
+					match[v.Id] = topic
+				} else {
+					topic := match[v.Id]
+					m[topic] = append(m[topic], v)
+				}
+			}
+			sort.Strings(topics)
+			ret := make([][]Event, 0, len(topics))
+			for _, topic := range topics {
+				ret = append(ret, m[topic])
+			}
+			return ret
+		},
+		Init: func() interface{} {
+			// note: we are modeling a single topic's log here; we're
+			// partitioning by topic, so this is okay
+			return []interface{}{}
+		},
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			log := state.([]interface{})
+			inp := input.(LogInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(LogOutput)
+			switch inp.Op {
+			case LogAppend:
+				if !pending && out.Offset != len(log) {
+					return false, state
+				}
+				newLog := make([]interface{}, len(log)+1)
+				copy(newLog, log)
+				newLog[len(log)] = inp.Value
+				return true, newLog
+			case LogRead:
+				if pending {
+					return true, state
+				}
+				if inp.Offset < 0 || inp.Offset >= len(log) {
+					return !out.Exists, state
+				}
+				return out.Exists && reflect.DeepEqual(out.Value, log[inp.Offset]), state
+			case LogReadLatest:
+				if pending {
+					return true, state
+				}
+				if len(log) == 0 {
+					return !out.Exists, state
+				}
+				return out.Exists && reflect.DeepEqual(out.Value, log[len(log)-1]), state
+			}
+			return false, state
+		},
+		Equal: func(state1, state2 interface{}) bool {
+			return reflect.DeepEqual(state1, state2)
+		},
+		DescribeOperation: func(input, output interface{}) string {
+			inp := input.(LogInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(LogOutput)
+			read := "none"
+			if pending {
+				read = "pending"
+			} else if out.Exists {
+				read = fmt.Sprintf("%v", out.Value)
+			}
+			switch inp.Op {
+			case LogAppend:
+				if pending {
+					return fmt.Sprintf("append('%s', %v) -> pending", inp.Topic, inp.Value)
+				}
+				return fmt.Sprintf("append('%s', %v) -> %d", inp.Topic, inp.Value, out.Offset)
+			case LogRead:
+				return fmt.Sprintf("read('%s', %d) -> %s", inp.Topic, inp.Offset, read)
+			case LogReadLatest:
+				return fmt.Sprintf("read-latest('%s') -> %s", inp.Topic, read)
+			}
+			return "<invalid>"
+		},
+		DescribeState: func(state interface{}) string {
+			log := state.([]interface{})
+			values := make([]string, len(log))
+			for i, v := range log {
+				values[i] = fmt.Sprintf("%v", v)
+			}
+			return fmt.Sprintf("[%s]", strings.Join(values, ", "))
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package porcupine
+
+import "testing"
+
+func TestLogModelConcurrentAppends(t *testing.T) {
+	model := LogModel()
+	// two appends race for the next offset
+	ops := []Operation{
+		{0, LogInput{Op: LogAppend, Topic: "a", Value: "x"}, 0, LogOutput{Offset: 1}, 10},
+		{1, LogInput{Op: LogAppend, Topic: "a", Value: "y"}, 0, LogOutput{Offset: 0}, 10},
+		{2, LogInput{Op: LogRead, Topic: "a", Offset: 0}, 20, LogOutput{Value: "y", Exists: true}, 30},
+		{2, LogInput{Op: LogReadLatest, Topic: "a"}, 40, LogOutput{Value: "x", Exists: true}, 50},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// both appends can't get the same offset
+	ops = []Operation{
+		{0, LogInput{Op: LogAppend, Topic: "a", Value: "x"}, 0, LogOutput{Offset: 0}, 10},
+		{1, LogInput{Op: LogAppend, Topic: "a", Value: "y"}, 0, LogOutput{Offset: 0}, 10},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+
+	// the read must observe the value appended at offset 0
+	ops = []Operation{
+		{0, LogInput{Op: LogAppend, Topic: "a", Value: "x"}, 0, LogOutput{Offset: 1}, 10},
+		{1, LogInput{Op: LogAppend, Topic: "a", Value: "y"}, 0, LogOutput{Offset: 0}, 10},
+		{2, LogInput{Op: LogRead, Topic: "a", Offset: 0}, 5, LogOutput{Value: "x", Exists: true}, 30},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
+
+func TestLogModelTopics(t *testing.T) {
+	model := LogModel()
+	events := []Event{
+		{0, CallEvent, LogInput{Op: LogAppend, Topic: "a", Value: 1}, 0},
+		{1, CallEvent, LogInput{Op: LogAppend, Topic: "b", Value: 2}, 1},
+		{2, CallEvent, LogInput{Op: LogRead, Topic: "b", Offset: 1}, 2},
+		{2, ReturnEvent, LogOutput{}, 2},
+		{1, ReturnEvent, LogOutput{Offset: 0}, 1},
+		{0, ReturnEvent, LogOutput{Offset: 0}, 0},
+		{2, CallEvent, LogInput{Op: LogReadLatest, Topic: "a"}, 3},
+		{2, ReturnEvent, LogOutput{Value: 1, Exists: true}, 3},
+	}
+	if !CheckEvents(model, events) {
+		t.Fatal("expected operations to be linearizable")
+	}
+
+	// topic "b" is empty
+	events = []Event{
+		{0, CallEvent, LogInput{Op: LogAppend, Topic: "a", Value: 1}, 0},
+		{0, ReturnEvent, LogOutput{Offset: 0}, 0},
+		{1, CallEvent, LogInput{Op: LogReadLatest, Topic: "b"}, 1},
+		{1, ReturnEvent, LogOutput{Value: 1, Exists: true}, 1},
+	}
+	if CheckEvents(model, events) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
@@ -0,0 +1,115 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// An LWWOp is the kind of an operation on a last-write-wins register.
+type LWWOp int
+
+const (
+	LWWWrite LWWOp = iota // write a value with a timestamp
+	LWWRead               // read the value and its timestamp
+)
+
+// An LWWInput is the input of an operation for the model returned by
+// [LWWRegisterModel].
+type LWWInput struct {
+	Op        LWWOp
+	Value     interface{} // value to write, for LWWWrite
+	Timestamp int64       // timestamp of the write, for LWWWrite
+}
+
+// An LWWOutput is the output of an operation for the model returned by
+// [LWWRegisterModel].
+type LWWOutput struct {
+	Value     interface{} // value that was read, for LWWRead
+	Timestamp int64       // timestamp of the write that was read, for LWWRead
+}
+
+type lwwState struct {
+	written   bool
+	value     interface{}
+	timestamp int64
+}
+
+// LWWRegisterModel returns a model of a last-write-wins register, a
+// conflict-free replicated data type where every write carries a timestamp,
+// e.g., from the clock of the replica that accepted it, and the write with
+// the highest timestamp wins.
+//
+// The value of the register is that of the write with the highest timestamp
+// among those that took effect, regardless of the order in which they took
+// effect, and a read returns that value along with its timestamp. A read of
+// a register that was never written returns the zero LWWOutput. This is
+// weaker than linearizability: a write that returns after another write
+// doesn't overwrite it if it has a lower timestamp, e.g., because of clock
+// skew between replicas. Replicas break ties between writes with the same
+// timestamp in a way that the model doesn't know, so such writes make the
+// model nondeterministic: either write may win. Values are compared using
+// [reflect.DeepEqual].
+//
+// Inputs must be of type [LWWInput] and outputs must be of type
+// [LWWOutput].
+func LWWRegisterModel() NondeterministicModel {
+	return NondeterministicModel{
+		Init: func() []interface{} {
+			return []interface{}{lwwState{}}
+		},
+		Step: func(state, input, output interface{}) []interface{} {
+			st := state.(lwwState)
+			inp := input.(LWWInput)
+			switch inp.Op {
+			case LWWWrite:
+				written := lwwState{true, inp.Value, inp.Timestamp}
+				switch {
+				case !st.written || inp.Timestamp > st.timestamp:
+					return []interface{}{written}
+				case inp.Timestamp < st.timestamp || reflect.DeepEqual(inp.Value, st.value):
+					return []interface{}{st}
+				default:
+					// a tie, which could be broken either way
+					return []interface{}{st, written}
+				}
+			case LWWRead:
+				if _, pending := output.(PendingOutput); pending {
+					return []interface{}{st}
+				}
+				out := output.(LWWOutput)
+				if reflect.DeepEqual(out.Value, st.value) && out.Timestamp == st.timestamp {
+					return []interface{}{st}
+				}
+			}
+			return nil
+		},
+		Equal: func(state1, state2 interface{}) bool {
+			return reflect.DeepEqual(state1, state2)
+		},
+		DescribeOperation: func(input, output interface{}) string {
+			inp := input.(LWWInput)
+			_, pending := output.(PendingOutput)
+			switch inp.Op {
+			case LWWWrite:
+				if pending {
+					return fmt.Sprintf("write(%v @ %d) -> pending", inp.Value, inp.Timestamp)
+				}
+				return fmt.Sprintf("write(%v @ %d)", inp.Value, inp.Timestamp)
+			case LWWRead:
+				if pending {
+					return "read() -> pending"
+				}
+				out := output.(LWWOutput)
+				return fmt.Sprintf("read() -> %v @ %d", out.Value, out.Timestamp)
+			}
+			return "<invalid>"
+		},
+		DescribeState: func(state interface{}) string {
+			st := state.(lwwState)
+			if !st.written {
+				return "<unwritten>"
+			}
+			return fmt.Sprintf("%v @ %d", st.value, st.timestamp)
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package porcupine
+
+import "testing"
+
+func TestLWWRegisterModel(t *testing.T) {
+	lww := LWWRegisterModel()
+	model := lww.ToModel()
+	// the write of "b" returns after the write of "a", but it has a lower
+	// timestamp, so "a" wins
+	ops := []Operation{
+		{0, LWWInput{Op: LWWRead}, 0, LWWOutput{}, 5},
+		{0, LWWInput{LWWWrite, "a", 5}, 10, LWWOutput{}, 20},
+		{1, LWWInput{LWWWrite, "b", 3}, 30, LWWOutput{}, 40},
+		{2, LWWInput{Op: LWWRead}, 50, LWWOutput{"a", 5}, 60},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+	// but it's not linearizable as an ordinary register, where "a" is 1
+	// and "b" is 2
+	registerOps := []Operation{
+		{0, registerInput{false, 1}, 10, 0, 20},
+		{1, registerInput{false, 2}, 30, 0, 40},
+		{2, registerInput{true, 0}, 50, 1, 60},
+	}
+	if CheckOperations(registerModel, registerOps) {
+		t.Fatal("expected operations not to be linearizable as a register")
+	}
+
+	// reading the losing write isn't valid
+	ops[3].Output = LWWOutput{"b", 3}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be valid")
+	}
+
+	// writes with the same timestamp can be resolved either way, but
+	// consistently
+	ops = []Operation{
+		{0, LWWInput{LWWWrite, "a", 5}, 0, LWWOutput{}, 10},
+		{1, LWWInput{LWWWrite, "b", 5}, 0, LWWOutput{}, 10},
+		{2, LWWInput{Op: LWWRead}, 20, LWWOutput{"b", 5}, 30},
+		{3, LWWInput{Op: LWWRead}, 40, LWWOutput{"b", 5}, 50},
+	}
+	if !CheckOperations(model, ops) {
+		t.Fatal("expected operations to be valid")
+	}
+	ops[3].Output = LWWOutput{"a", 5}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be valid")
+	}
+}
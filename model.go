@@ -2,7 +2,11 @@ package porcupine
 
 import (
 	"fmt"
+	"io"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // An Operation is an element of a history.
@@ -37,6 +41,10 @@ const (
 //
 // The Id field is used to match a function call event with its corresponding
 // return event.
+//
+// A history of events must be ordered consistently with real time. If only
+// the order of events within each client is known, [InterleaveEvents] can
+// derive a global order.
 type Event struct {
 	ClientId int // optional, unless you want a visualization; zero-indexed
 	Kind     EventKind
@@ -44,6 +52,45 @@ type Event struct {
 	Id       int
 }
 
+// A PendingOutput is the output of an operation that was still pending at the
+// end of a history, i.e., an operation with a call [Event] but no matching
+// return event.
+//
+// Such an operation may or may not have taken effect, and if it did, its
+// output is unknown. The checker treats the operation as if it returned at
+// the end of the history with this output, so the operation may be
+// linearized at any point after its call. A model's Step function must
+// accept a PendingOutput in any state in which the operation could have
+// executed, producing the state that results from executing it, and its
+// DescribeOperation function (if any) must be able to describe it. Histories
+// represented as a sequence of [Operation] can use this as well, by setting
+// an operation's Output to PendingOutput{} and its Return to a time after all
+// other operations. The models provided by this package all accept a
+// PendingOutput.
+type PendingOutput struct{}
+
+// A Nemesis is the input of an [Operation] that is not a client operation,
+// but a fault that the test injected, such as a network partition or a
+// process crash, like the nemesis of a Jepsen test. Such operations are
+// recorded in the same history as client operations, which keeps them in
+// context, but they are not part of the system's sequential specification:
+// checks of histories of Operation ignore them, so models (including their
+// partition functions) never see them. Verbose checks show them in
+// visualizations as annotations with the tag "nemesis" (see [Annotation]),
+// spanning the operation's Call and Return, with the given Description and
+// Details. The Output of such an operation is ignored.
+//
+// Because nemeses are removed from the history before it is checked,
+// operation ids in the results of a check, such as those in
+// [LinearizationInfo.PartialLinearizations], and in [CheckOptions.Hints]
+// skip them: they count only the other operations. [CheckOptions.Order] is
+// the exception, whose indices are into the history as given, including
+// nemeses.
+type Nemesis struct {
+	Description string
+	Details     string
+}
+
 // A Model is a sequential specification of a system.
 //
 // Note: models in this package are expected to be purely functional. That is,
@@ -62,6 +109,13 @@ type Event struct {
 // Implementing DescribeOperation and DescribeState will produce nicer
 // visualizations.
 //
+// If clients retry operations, as with at-least-once delivery in Raft- or
+// Paxos-based systems, a write may be applied more than once. To avoid
+// reporting spurious violations, include an idempotency key (such as a
+// client request id) in the input, track the keys of applied writes in the
+// state, and have Step treat a write whose key has already been applied as a
+// no-op, as in the idempotent writes example.
+//
 // It may be helpful to look at this package's [test code] for examples of how
 // to write models, including models that include partition functions.
 //
@@ -69,27 +123,82 @@ type Event struct {
 type Model struct {
 	// Partition functions, such that a history is linearizable if and only
 	// if each partition is linearizable. If left nil, this package will
-	// skip partitioning.
+	// skip partitioning. PartitionEvent must keep each call in the same
+	// partition as its return; the checker panics if it doesn't.
 	Partition      func(history []Operation) [][]Operation
 	PartitionEvent func(history []Event) [][]Event
+	// Alternative partition functions that also give each partition a
+	// label, e.g., "key=x", which is used to identify the partition in
+	// results and visualizations. Partitions are ordered by label. If
+	// specified, these are used rather than Partition and PartitionEvent.
+	PartitionLabeled      func(history []Operation) map[string][]Operation
+	PartitionEventLabeled func(history []Event) map[string][]Event
 	// Initial state of the system.
 	Init func() interface{}
+	// Optional initial state of the partition with the given label, for
+	// models with labeled partitions (PartitionLabeled or
+	// PartitionEventLabeled). If specified, it is used rather than Init
+	// for labeled partitions, so that the initial state of a partition
+	// can cover only the part of the system that the partition's
+	// operations access, e.g., a single key. Init is still required, and
+	// it is used when the history is not partitioned.
+	InitPartition func(label string) interface{}
 	// Step function for the system. Returns whether or not the system
 	// could take this step with the given inputs and outputs and also
 	// returns the new state. This function must be a pure function: it
 	// cannot mutate the given state.
 	Step func(state interface{}, input interface{}, output interface{}) (bool, interface{})
+	// Optional step function that additionally explains why a step was
+	// rejected, e.g., "expected value 5, got 7". Visualizations show the
+	// reason when hovering over an illegal linearization point. If Step is
+	// left nil, this package will use StepVerbose, ignoring the reason.
+	StepVerbose func(state interface{}, input interface{}, output interface{}) (bool, interface{}, string)
 	// Equality on states. If left nil, this package will use == as a
 	// fallback ([ShallowEqual]).
 	Equal func(state1, state2 interface{}) bool
+	// Observational equivalence on states, which may be coarser than
+	// Equal: two states are observationally equal if no sequence of
+	// future operations can distinguish them, even if they differ in
+	// internal bookkeeping. If specified, the checker uses this rather
+	// than Equal to avoid exploring equivalent states more than once,
+	// which can shrink the search. This must be a sound equivalence
+	// relation; otherwise, the checker may return incorrect results. If
+	// left nil, this package will use Equal.
+	ObservationallyEqual func(state1, state2 interface{}) bool
+	// Optional hash function on states. If specified, the checker uses it,
+	// in addition to the set of linearized operations, to look up states
+	// that it has already explored, which can make the search much faster
+	// when many different states are reachable with the same set of
+	// linearized operations (e.g., with large map states). States that are
+	// equal (according to ObservationallyEqual, or Equal if that is nil)
+	// must have the same hash. If left nil, states are not hashed.
+	HashState func(state interface{}) uint64
+	// Equivalence on outputs, for outputs that can be semantically equal
+	// without being ==, e.g., maps, slices, or structs with irrelevant
+	// fields. The checker never compares outputs itself, because Step
+	// decides whether an output is possible, so Step must still accept
+	// every output that is equivalent to one it expects; declaring the
+	// equivalence once, as a function that both Step and EqualOutput use,
+	// keeps them consistent. This package uses EqualOutput wherever it
+	// compares outputs, e.g., to highlight operations whose output differs
+	// from the expected one in visualizations (see
+	// [VisualizeOptions.ExpectedOutput]). If left nil, this package will
+	// use [reflect.DeepEqual].
+	EqualOutput func(output1, output2 interface{}) bool
 	// For visualization, describe an operation as a string. For example,
 	// "Get('x') -> 'y'". Can be omitted if you're not producing
 	// visualizations.
 	DescribeOperation func(input interface{}, output interface{}) string
 	// For visualization purposes, describe a state as a string. For
 	// example, "{'x' -> 'y', 'z' -> 'w'}". Can be omitted if you're not
-	// producing visualizations.
+	// producing visualizations; if omitted, states are rendered using the
+	// "%v" format specifier.
 	DescribeState func(state interface{}) string
+	// Optional function that, for visualization, describes what an
+	// operation changed, given the states before and after it. For
+	// example, "set x: y -> z". Visualizations show this for each step of
+	// a linearization, which can be easier to follow than the full states.
+	DescribeTransition func(before, after interface{}, input, output interface{}) string
 }
 
 // A NondeterministicModel is a nondeterministic sequential specification of a
@@ -107,26 +216,43 @@ type Model struct {
 type NondeterministicModel struct {
 	// Partition functions, such that a history is linearizable if and only
 	// if each partition is linearizable. If left nil, this package will
-	// skip partitioning.
+	// skip partitioning. PartitionEvent must keep each call in the same
+	// partition as its return; the checker panics if it doesn't.
 	Partition      func(history []Operation) [][]Operation
 	PartitionEvent func(history []Event) [][]Event
+	// Alternative partition functions that also give each partition a
+	// label, e.g., "key=x", which is used to identify the partition in
+	// results and visualizations. Partitions are ordered by label. If
+	// specified, these are used rather than Partition and PartitionEvent.
+	PartitionLabeled      func(history []Operation) map[string][]Operation
+	PartitionEventLabeled func(history []Event) map[string][]Event
 	// Initial states of the system.
 	Init func() []interface{}
+	// Optional initial states of the partition with the given label; see
+	// [Model]. With this, the set of states that ToModel tracks for each
+	// partition contains only the initial states of that partition, rather
+	// than all of the initial states of the system, which can be much
+	// larger, e.g., when each key has several possible initial values.
+	InitPartition func(label string) []interface{}
 	// Step function for the system. Returns all possible next states for
 	// the given state, input, and output. If the system cannot step with
 	// the given state/input to produce the given output, this function
 	// should return an empty slice.
 	Step func(state interface{}, input interface{}, output interface{}) []interface{}
-	// Equality on states. If left nil, this package will use == as a
-	// fallback ([ShallowEqual]).
+	// Equality on states, which is used to merge states. If left nil, this
+	// package will use == as a fallback ([ShallowEqual]). For states with
+	// floating-point numbers, consider [ApproximatelyEqual].
 	Equal func(state1, state2 interface{}) bool
+	// Equivalence on outputs; see [Model].
+	EqualOutput func(output1, output2 interface{}) bool
 	// For visualization, describe an operation as a string. For example,
 	// "Get('x') -> 'y'". Can be omitted if you're not producing
 	// visualizations.
 	DescribeOperation func(input interface{}, output interface{}) string
 	// For visualization purposes, describe a state as a string. For
 	// example, "{'x' -> 'y', 'z' -> 'w'}". Can be omitted if you're not
-	// producing visualizations.
+	// producing visualizations; if omitted, states are rendered using the
+	// "%v" format specifier.
 	DescribeState func(state interface{}) string
 }
 
@@ -169,13 +295,22 @@ func (nm *NondeterministicModel) ToModel() Model {
 	if describeState == nil {
 		describeState = defaultDescribeState
 	}
+	var initPartition func(label string) interface{}
+	if nm.InitPartition != nil {
+		initPartition = func(label string) interface{} {
+			return merge(nm.InitPartition(label), equal)
+		}
+	}
 	return Model{
-		Partition:      nm.Partition,
-		PartitionEvent: nm.PartitionEvent,
+		Partition:             nm.Partition,
+		PartitionEvent:        nm.PartitionEvent,
+		PartitionLabeled:      nm.PartitionLabeled,
+		PartitionEventLabeled: nm.PartitionEventLabeled,
 		// we need this wrapper to convert a []interface{} to an interface{}
 		Init: func() interface{} {
-			return merge(nm.Init(), nm.Equal)
+			return merge(nm.Init(), equal)
 		},
+		InitPartition: initPartition,
 		Step: func(state, input, output interface{}) (bool, interface{}) {
 			states := state.([]interface{})
 			var allNextStates []interface{}
@@ -207,6 +342,7 @@ func (nm *NondeterministicModel) ToModel() Model {
 			}
 			return true
 		},
+		EqualOutput:       nm.EqualOutput,
 		DescribeOperation: describeOperation,
 		DescribeState: func(state interface{}) string {
 			states := state.([]interface{})
@@ -219,6 +355,298 @@ func (nm *NondeterministicModel) ToModel() Model {
 	}
 }
 
+// Traced returns a model that behaves like this model, but that writes a line
+// to w describing every call to the Step function, using the model's
+// DescribeOperation and DescribeState functions.
+//
+// This can help with understanding why a history is not linearizable, or
+// with debugging a model. Because the checker explores many possible
+// linearizations, with backtracking, the trace can be very long. Partitions
+// are checked in parallel, so lines from different partitions may be
+// interleaved.
+func (m Model) Traced(w io.Writer) Model {
+	filled := fillDefault(m)
+	var mu sync.Mutex
+	trace := func(state, input, output interface{}, ok bool, newState interface{}) {
+		result := "rejected"
+		if ok {
+			result = filled.DescribeState(newState)
+		}
+		mu.Lock()
+		fmt.Fprintf(w, "Step(%s, %s) -> %s\n", filled.DescribeState(state), filled.DescribeOperation(input, output), result)
+		mu.Unlock()
+	}
+	step := filled.Step
+	m.Step = func(state, input, output interface{}) (bool, interface{}) {
+		ok, newState := step(state, input, output)
+		trace(state, input, output, ok, newState)
+		return ok, newState
+	}
+	if stepVerbose := m.StepVerbose; stepVerbose != nil {
+		m.StepVerbose = func(state, input, output interface{}) (bool, interface{}, string) {
+			ok, newState, reason := stepVerbose(state, input, output)
+			trace(state, input, output, ok, newState)
+			return ok, newState, reason
+		}
+	}
+	return m
+}
+
+// PurityChecked returns a model that behaves like this model, but that
+// verifies that the Step function does not mutate the given state, input, or
+// output, panicking with a description of the offending step if it does.
+//
+// Because the checker shares states between branches of its search, a Step
+// function that mutates its arguments (e.g., by modifying a map in place)
+// silently produces incorrect results. This is meant for debugging models:
+// it makes every step much slower, because it takes a deep snapshot of the
+// state, input, and output before and after each call to Step.
+func (m Model) PurityChecked() Model {
+	filled := fillDefault(m)
+	check := func(state, input, output interface{}, step func()) {
+		before := snapshot(state, input, output)
+		description := fmt.Sprintf("Step(%s, %s)", filled.DescribeState(state), filled.DescribeOperation(input, output))
+		step()
+		if snapshot(state, input, output) != before {
+			panic(fmt.Sprintf("porcupine: model Step function mutated its arguments: %s", description))
+		}
+	}
+	step := filled.Step
+	m.Step = func(state, input, output interface{}) (ok bool, newState interface{}) {
+		check(state, input, output, func() {
+			ok, newState = step(state, input, output)
+		})
+		return ok, newState
+	}
+	if stepVerbose := m.StepVerbose; stepVerbose != nil {
+		m.StepVerbose = func(state, input, output interface{}) (ok bool, newState interface{}, reason string) {
+			check(state, input, output, func() {
+				ok, newState, reason = stepVerbose(state, input, output)
+			})
+			return ok, newState, reason
+		}
+	}
+	return m
+}
+
+// partitionModel returns the model to use for the partition with the given
+// label, whose initial state is given by InitPartition, if specified.
+func (m Model) partitionModel(label string) Model {
+	if m.InitPartition == nil {
+		return m
+	}
+	initPartition := m.InitPartition
+	m.Init = func() interface{} {
+		return initPartition(label)
+	}
+	return m
+}
+
+// recovering returns a model that behaves like this model, which must have
+// defaults filled in, but that recovers from panics in the functions that the
+// checker calls, panicking again with a description of the call that
+// panicked (see [CheckOptions.RecoverPanics]).
+func (m Model) recovering() Model {
+	// describe a value without trusting the model's functions, which may
+	// be what panicked
+	describe := func(f func() string, v interface{}) (s string) {
+		defer func() {
+			if recover() != nil {
+				s = fmt.Sprintf("%v", v)
+			}
+		}()
+		return f()
+	}
+	rethrow := func(call func() string) {
+		if r := recover(); r != nil {
+			panic(fmt.Sprintf("%s panicked: %v", call(), r))
+		}
+	}
+	init, step, equal, hash := m.Init, m.Step, m.ObservationallyEqual, m.HashState
+	m.Init = func() interface{} {
+		defer rethrow(func() string { return "Init()" })
+		return init()
+	}
+	m.Step = func(state, input, output interface{}) (bool, interface{}) {
+		defer rethrow(func() string {
+			return fmt.Sprintf("Step(%s, %s)", describe(func() string { return m.DescribeState(state) }, state), describe(func() string { return m.DescribeOperation(input, output) }, [2]interface{}{input, output}))
+		})
+		return step(state, input, output)
+	}
+	m.ObservationallyEqual = func(state1, state2 interface{}) bool {
+		defer rethrow(func() string {
+			return fmt.Sprintf("Equal(%s, %s)", describe(func() string { return m.DescribeState(state1) }, state1), describe(func() string { return m.DescribeState(state2) }, state2))
+		})
+		return equal(state1, state2)
+	}
+	if hash != nil {
+		m.HashState = func(state interface{}) uint64 {
+			defer rethrow(func() string {
+				return fmt.Sprintf("HashState(%s)", describe(func() string { return m.DescribeState(state) }, state))
+			})
+			return hash(state)
+		}
+	}
+	return m
+}
+
+// ValidateSequence checks that the model accepts the given sequence of
+// operations, each given as an input and output, when they are executed one
+// after another starting from the initial state. It returns true and -1 if
+// every step succeeds, or false and the index of the first operation that the
+// Step function rejects.
+//
+// This is meant for testing a model on its own, before using it to check
+// concurrent histories.
+func (m Model) ValidateSequence(pairs []struct{ In, Out interface{} }) (bool, int) {
+	filled := fillDefault(m)
+	state := filled.Init()
+	for i, pair := range pairs {
+		var ok bool
+		ok, state = filled.Step(state, pair.In, pair.Out)
+		if !ok {
+			return false, i
+		}
+	}
+	return true, -1
+}
+
+// snapshot returns a string that describes the given values, following
+// pointers and including unexported fields, so that any mutation of the
+// values changes the snapshot.
+func snapshot(values ...interface{}) string {
+	var b strings.Builder
+	onPath := make(map[uintptr]bool)
+	for _, v := range values {
+		writeSnapshot(&b, reflect.ValueOf(v), onPath)
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+func writeSnapshot(b *strings.Builder, v reflect.Value, onPath map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		b.WriteString("nil")
+	case reflect.Bool:
+		fmt.Fprintf(b, "%t", v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fmt.Fprintf(b, "%d", v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		fmt.Fprintf(b, "%d", v.Uint())
+	case reflect.Float32, reflect.Float64:
+		fmt.Fprintf(b, "%v", v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		fmt.Fprintf(b, "%v", v.Complex())
+	case reflect.String:
+		fmt.Fprintf(b, "%q", v.String())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			p := v.Pointer()
+			if onPath[p] {
+				b.WriteString("<cycle>")
+				return
+			}
+			onPath[p] = true
+			defer delete(onPath, p)
+		}
+		b.WriteByte('*')
+		writeSnapshot(b, v.Elem(), onPath)
+	case reflect.Slice, reflect.Array:
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			writeSnapshot(b, v.Index(i), onPath)
+			b.WriteByte(',')
+		}
+		b.WriteByte(']')
+	case reflect.Map:
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var e strings.Builder
+			writeSnapshot(&e, iter.Key(), onPath)
+			e.WriteByte(':')
+			writeSnapshot(&e, iter.Value(), onPath)
+			entries = append(entries, e.String())
+		}
+		sort.Strings(entries)
+		fmt.Fprintf(b, "map[%s]", strings.Join(entries, ","))
+	case reflect.Struct:
+		b.WriteByte('{')
+		for i := 0; i < v.NumField(); i++ {
+			writeSnapshot(b, v.Field(i), onPath)
+			b.WriteByte(',')
+		}
+		b.WriteByte('}')
+	default:
+		// functions, channels, and unsafe pointers are compared by
+		// identity
+		fmt.Fprintf(b, "%v#%x", v.Kind(), v.Pointer())
+	}
+}
+
+// ReachableStates enumerates the states of a model that are reachable from
+// its initial state by applying up to depth operations.
+//
+// This can help with debugging a model itself. Each step applies every one
+// of the given operations (only their Input and Output are used), and states
+// are deduplicated using the model's Equal function. States are returned in
+// breadth-first order, starting with the initial state.
+func ReachableStates(model Model, operations []Operation, depth int) []interface{} {
+	model = fillDefault(model)
+	step := func(state interface{}, op Operation) []interface{} {
+		if ok, next := model.Step(state, op.Input, op.Output); ok {
+			return []interface{}{next}
+		}
+		return nil
+	}
+	return reachableStates([]interface{}{model.Init()}, step, model.Equal, operations, depth)
+}
+
+// ReachableStatesNondeterministic is like [ReachableStates], but for a
+// [NondeterministicModel]: each step considers all possible next states.
+func ReachableStatesNondeterministic(model NondeterministicModel, operations []Operation, depth int) []interface{} {
+	equal := model.Equal
+	if equal == nil {
+		equal = shallowEqual
+	}
+	step := func(state interface{}, op Operation) []interface{} {
+		return model.Step(state, op.Input, op.Output)
+	}
+	return reachableStates(merge(model.Init(), equal), step, equal, operations, depth)
+}
+
+func reachableStates(init []interface{}, step func(state interface{}, op Operation) []interface{}, equal func(state1, state2 interface{}) bool, operations []Operation, depth int) []interface{} {
+	seen := init
+	frontier := init
+	for d := 0; d < depth && len(frontier) > 0; d++ {
+		var next []interface{}
+		for _, state := range frontier {
+			for _, op := range operations {
+				for _, s := range step(state, op) {
+					found := false
+					for _, other := range seen {
+						if equal(s, other) {
+							found = true
+							break
+						}
+					}
+					if !found {
+						seen = append(seen, s)
+						next = append(next, s)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+	return seen
+}
+
 // noPartition is a fallback partition function that partitions the history
 // into a single partition containing all of the operations.
 func noPartition(history []Operation) [][]Operation {
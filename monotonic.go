@@ -0,0 +1,41 @@
+package porcupine
+
+import "sort"
+
+// CheckMonotonicReads checks whether, for every client, the versions
+// observed by the client's operations never decrease, a session guarantee
+// that is much weaker than linearizability but can be checked without a
+// search.
+//
+// The versionOf function gives the version observed by an operation, given
+// its output, e.g., the version number returned by a read from a versioned
+// store. It should return a negative number for operations that don't
+// observe a version, such as writes, which are ignored. Operations with a
+// [PendingOutput] are ignored as well. A client's operations are considered
+// in program order, i.e., in order of their Call times.
+func CheckMonotonicReads(history []Operation, versionOf func(output interface{}) int) bool {
+	byClient := make(map[int][]Operation)
+	for _, op := range history {
+		if _, ok := op.Output.(PendingOutput); ok {
+			continue
+		}
+		byClient[op.ClientId] = append(byClient[op.ClientId], op)
+	}
+	for _, ops := range byClient {
+		sort.SliceStable(ops, func(i, j int) bool {
+			return ops[i].Call < ops[j].Call
+		})
+		latest := -1
+		for _, op := range ops {
+			version := versionOf(op.Output)
+			if version < 0 {
+				continue
+			}
+			if version < latest {
+				return false
+			}
+			latest = version
+		}
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package porcupine
+
+import "testing"
+
+func TestCheckMonotonicReads(t *testing.T) {
+	// the outputs of reads are the values read, which we use as versions,
+	// and writes have output -1, so they are ignored
+	versionOf := func(output interface{}) int {
+		return output.(int)
+	}
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, -1, 10},
+		{0, registerInput{true, 0}, 20, 1, 30},
+		{1, registerInput{true, 0}, 25, 0, 35},
+		{0, registerInput{false, 2}, 40, -1, 50},
+		{0, registerInput{true, 0}, 80, 2, 90},
+		{1, registerInput{true, 0}, 60, 1, 70},
+		{1, registerInput{true, 0}, 100, PendingOutput{}, 110},
+	}
+	if !CheckMonotonicReads(ops, versionOf) {
+		t.Fatal("expected reads to be monotonic")
+	}
+	// client 1 reads 1 and then 0
+	ops = append(ops, Operation{1, registerInput{true, 0}, 75, 0, 95})
+	if CheckMonotonicReads(ops, versionOf) {
+		t.Fatal("expected reads not to be monotonic")
+	}
+	// a different client reading an older version is fine
+	ops[len(ops)-1].ClientId = 2
+	if !CheckMonotonicReads(ops, versionOf) {
+		t.Fatal("expected reads to be monotonic")
+	}
+}
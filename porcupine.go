@@ -1,10 +1,17 @@
 package porcupine
 
-import "time"
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
 
 // CheckOperations checks whether a history is linearizable.
 func CheckOperations(model Model, history []Operation) bool {
-	res, _ := checkOperations(model, history, false, 0)
+	res, _ := checkOperations(model, history, CheckOptions{})
 	return res == Ok
 }
 
@@ -13,7 +20,7 @@ func CheckOperations(model Model, history []Operation) bool {
 //
 // A timeout of 0 is interpreted as an unlimited timeout.
 func CheckOperationsTimeout(model Model, history []Operation, timeout time.Duration) CheckResult {
-	res, _ := checkOperations(model, history, false, timeout)
+	res, _ := checkOperations(model, history, CheckOptions{Timeout: timeout})
 	return res
 }
 
@@ -22,12 +29,182 @@ func CheckOperationsTimeout(model Model, history []Operation, timeout time.Durat
 //
 // The returned LinearizationInfo can be used with [Visualize].
 func CheckOperationsVerbose(model Model, history []Operation, timeout time.Duration) (CheckResult, LinearizationInfo) {
-	return checkOperations(model, history, true, timeout)
+	return checkOperations(model, history, CheckOptions{Verbose: true, Timeout: timeout})
+}
+
+// CheckOperationsLazyVerbose checks whether a history is linearizable, like
+// [CheckOperationsVerbose], but it only computes data for visualization if
+// the history is not linearizable.
+//
+// It first checks the history without computing this data, which is faster,
+// and only if the result is Illegal, it checks the history again while
+// computing the data. If the result is Ok or Unknown, the returned
+// LinearizationInfo is empty. The timeout applies to each check separately; a
+// timeout of 0 is interpreted as an unlimited timeout.
+func CheckOperationsLazyVerbose(model Model, history []Operation, timeout time.Duration) (CheckResult, LinearizationInfo) {
+	res, info := checkOperations(model, history, CheckOptions{Timeout: timeout})
+	if res != Illegal {
+		return res, info
+	}
+	return checkOperations(model, history, CheckOptions{Verbose: true, Timeout: timeout})
+}
+
+// CheckOperationsWithOrder checks whether a history is linearizable, subject to
+// additional ordering constraints between operations.
+//
+// Each pair [a, b] in order means that history[a] must be linearized before
+// history[b]. See [CheckOptions] for details.
+func CheckOperationsWithOrder(model Model, history []Operation, order [][2]int) bool {
+	res, _ := checkOperations(model, history, CheckOptions{Order: order})
+	return res == Ok
+}
+
+// CheckByClient checks whether a history, given as the operations of each
+// client in program order, is sequentially consistent: whether there is a
+// total order of all of the operations that is consistent with the model and
+// with the order of each client's operations.
+//
+// Unlike the other checks, this doesn't use the Call and Return times or
+// the ClientId of operations: each client's operations are ordered by their
+// position in its slice, and operations of different clients are
+// concurrent, with no real-time constraints between them. The history is
+// converted to events with ordering constraints (see [CheckOptions]), so the
+// model's partition functions are not used; sequential consistency, unlike
+// linearizability, can't be checked one partition at a time.
+func CheckByClient(model Model, history map[int][]Operation) bool {
+	clients := make([]int, 0, len(history))
+	for client := range history {
+		clients = append(clients, client)
+	}
+	sort.Ints(clients)
+	// all calls come before all returns, so every pair of operations is
+	// concurrent, except as constrained by the order
+	var calls, returns []Event
+	var order [][2]int
+	id := 0
+	for _, client := range clients {
+		for i, op := range history[client] {
+			calls = append(calls, Event{client, CallEvent, op.Input, id})
+			returns = append(returns, Event{client, ReturnEvent, op.Output, id})
+			if i > 0 {
+				order = append(order, [2]int{id - 1, id})
+			}
+			id++
+		}
+	}
+	res, _ := checkEvents(model, append(calls, returns...), CheckOptions{Order: order})
+	return res == Ok
+}
+
+// Equivalent checks whether two histories of the same workload, e.g., from
+// running the workload against two implementations of a system, are
+// linearizable and have outcomes that are consistent with each other: each
+// history's outputs would also have been linearizable with the other
+// history's timing. This is useful for differential testing of a new
+// implementation against a reference one.
+//
+// The histories must have the same operations in the same order: the i-th
+// operation of each history must have the same ClientId and Input (compared
+// with [reflect.DeepEqual]); otherwise, this function panics. Only the
+// Output, Call, and Return of corresponding operations may differ.
+func Equivalent(model Model, historyA []Operation, historyB []Operation) bool {
+	if len(historyA) != len(historyB) {
+		panic(fmt.Sprintf("porcupine: histories have different lengths: %d and %d", len(historyA), len(historyB)))
+	}
+	for i := range historyA {
+		a, b := historyA[i], historyB[i]
+		if a.ClientId != b.ClientId || !reflect.DeepEqual(a.Input, b.Input) {
+			panic(fmt.Sprintf("porcupine: operation %d differs between histories", i))
+		}
+	}
+	// swap returns the history with the outputs of other
+	swap := func(history, other []Operation) []Operation {
+		swapped := make([]Operation, len(history))
+		for i := range history {
+			swapped[i] = history[i]
+			swapped[i].Output = other[i].Output
+		}
+		return swapped
+	}
+	for _, history := range [][]Operation{historyA, historyB, swap(historyA, historyB), swap(historyB, historyA)} {
+		if res, _ := checkOperations(model, history, CheckOptions{}); res != Ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWithPartitions checks whether a history is linearizable, trying each
+// of the given partition functions in turn in place of the model's own, for
+// when it isn't known a priori how the system partitions its state, e.g., by
+// key or by key prefix. It returns Ok as soon as the history is linearizable
+// under one of the partition functions, which proves that it is linearizable
+// provided that partition function is valid for the model (see [Model]). If
+// it is linearizable under none of them, it returns Illegal if the check
+// with any partition function returned Illegal, and Unknown otherwise.
+//
+// It also returns the index of the partition function that gave the result,
+// or -1 if the result is Unknown. The timeout applies to each check
+// separately; a timeout of 0 is interpreted as an unlimited timeout.
+func CheckWithPartitions(model Model, history []Operation, timeout time.Duration, partitioners ...func(history []Operation) [][]Operation) (CheckResult, int) {
+	result, index := Unknown, -1
+	for i, partition := range partitioners {
+		m := model
+		m.Partition = partition
+		m.PartitionLabeled = nil
+		res, _ := checkOperations(m, history, CheckOptions{Timeout: timeout})
+		if res == Ok {
+			return Ok, i
+		}
+		if res == Illegal && result == Unknown {
+			result, index = Illegal, i
+		}
+	}
+	return result, index
+}
+
+// CheckOperationsFrom checks whether a history is linearizable, starting from
+// the given initial state rather than the state returned by the model's Init
+// function. This is useful for checking a suffix of a longer execution, e.g.,
+// a history captured after a warmup phase, or a rolling window of a
+// long-running system, whose state at the start is known.
+//
+// The initial state must have the same type as the states of the model, as
+// returned by Init; otherwise, this function panics. Like every other state,
+// it must not be modified by the model's Step function.
+func CheckOperationsFrom(model Model, history []Operation, initialState interface{}) bool {
+	res, _ := checkOperations(withInitialState(model, initialState), history, CheckOptions{})
+	return res == Ok
+}
+
+// CheckEventsFrom is like [CheckOperationsFrom], but for a history of
+// [Event].
+func CheckEventsFrom(model Model, history []Event, initialState interface{}) bool {
+	res, _ := checkEvents(withInitialState(model, initialState), history, CheckOptions{})
+	return res == Ok
+}
+
+// withInitialState returns a model like the given one, but with an Init
+// function that returns the given state, which must have the same type as
+// the states of the model.
+func withInitialState(model Model, initialState interface{}) Model {
+	if model.Init == nil {
+		panic("porcupine: model has no Init function")
+	}
+	if expected, actual := reflect.TypeOf(model.Init()), reflect.TypeOf(initialState); expected != actual {
+		panic(fmt.Sprintf("porcupine: initial state has type %v, but the model's states have type %v", actual, expected))
+	}
+	model.Init = func() interface{} {
+		return initialState
+	}
+	// the given state is the initial state of every partition
+	model.InitPartition = nil
+	return model
 }
 
 // CheckEvents checks whether a history is linearizable.
 func CheckEvents(model Model, history []Event) bool {
-	res, _ := checkEvents(model, history, false, 0)
+	res, _ := checkEvents(model, history, CheckOptions{})
 	return res == Ok
 }
 
@@ -35,7 +212,7 @@ func CheckEvents(model Model, history []Event) bool {
 //
 // A timeout of 0 is interpreted as an unlimited timeout.
 func CheckEventsTimeout(model Model, history []Event, timeout time.Duration) CheckResult {
-	res, _ := checkEvents(model, history, false, timeout)
+	res, _ := checkEvents(model, history, CheckOptions{Timeout: timeout})
 	return res
 }
 
@@ -44,5 +221,400 @@ func CheckEventsTimeout(model Model, history []Event, timeout time.Duration) Che
 //
 // The returned LinearizationInfo can be used with [Visualize].
 func CheckEventsVerbose(model Model, history []Event, timeout time.Duration) (CheckResult, LinearizationInfo) {
-	return checkEvents(model, history, true, timeout)
+	return checkEvents(model, history, CheckOptions{Verbose: true, Timeout: timeout})
+}
+
+// CheckEventsLazyVerbose is like [CheckOperationsLazyVerbose], but for a
+// history of [Event].
+func CheckEventsLazyVerbose(model Model, history []Event, timeout time.Duration) (CheckResult, LinearizationInfo) {
+	res, info := checkEvents(model, history, CheckOptions{Timeout: timeout})
+	if res != Illegal {
+		return res, info
+	}
+	return checkEvents(model, history, CheckOptions{Verbose: true, Timeout: timeout})
+}
+
+// CheckMany checks whether each of the given histories is linearizable, with a
+// timeout that applies to each history individually.
+//
+// Histories are checked in parallel by a pool of GOMAXPROCS workers, which
+// can be faster than calling [CheckEventsTimeout] on each history in turn
+// when there are many small histories. The i-th result corresponds to the
+// i-th history. A timeout of 0 is interpreted as an unlimited timeout.
+func CheckMany(model Model, histories [][]Event, timeout time.Duration) []CheckResult {
+	results := make([]CheckResult, len(histories))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(histories) {
+		workers = len(histories)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i], _ = checkEvents(model, histories[i], CheckOptions{Timeout: timeout})
+			}
+		}()
+	}
+	for i := range histories {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return results
+}
+
+// LongestLinearizablePrefix finds the longest prefix of a history, in
+// real-time order, that is linearizable. This can be useful for determining
+// when a system started misbehaving.
+//
+// A prefix consists of the first n operations in order of invocation (with
+// ties broken by position in the given history); this function returns n,
+// which is len(history) if the entire history is linearizable. The search is
+// done by binary search over prefixes, checking each one with
+// [CheckOperations], so it assumes that once a prefix is not linearizable,
+// no longer prefix is linearizable either. This is usually but not always the
+// case: operations that are still pending at the end of a prefix can make it
+// look illegal.
+func LongestLinearizablePrefix(model Model, history []Operation) int {
+	sorted := make([]Operation, len(history))
+	copy(sorted, history)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Call < sorted[j].Call
+	})
+	// invariant: prefix of length lo is linearizable, prefix of length hi+1
+	// is not (or doesn't exist)
+	lo, hi := 0, len(sorted)
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		if CheckOperations(model, sorted[:mid]) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
+
+// CanLinearize checks whether the operation at the given index in the history
+// can be linearized at some point, i.e., whether there is a partial
+// linearization that includes it, even if the history as a whole is not
+// linearizable. This can be useful for debugging, e.g., to tell whether an
+// operation is illegal in itself or only conflicts with the rest of the
+// history.
+//
+// Only the partition that contains the operation is checked. Like the
+// partial linearizations in [LinearizationInfo], a partial linearization
+// includes every operation that returned before the last of its operations
+// was called, so an operation can't be linearized if an earlier operation
+// can't be. A [Nemesis] is never linearized.
+func CanLinearize(model Model, history []Operation, id int) bool {
+	return CanLinearizeOptions(model, history, id, CheckOptions{})
+}
+
+// CanLinearizeOptions is like [CanLinearize], but it checks the history with
+// the given options, so, e.g., TimestampEpsilon and StrictTimestamps affect
+// the answer just as they affect [CheckOperationsOptions]. If the options
+// include ordering constraints, the history isn't partitioned, so the whole
+// history is checked.
+func CanLinearizeOptions(model Model, history []Operation, id int, opts CheckOptions) bool {
+	if id < 0 || id >= len(history) {
+		return false
+	}
+	if _, ok := history[id].Input.(Nemesis); ok {
+		return false
+	}
+	model = fillDefault(model)
+	opts.Verbose = true
+	// the index of the operation once nemeses are removed
+	for _, op := range history[:id] {
+		if _, ok := op.Input.(Nemesis); ok {
+			id--
+		}
+	}
+	history, order, _ := removeNemeses(history, opts.Order)
+	var subhistory []Operation
+	var preds [][][]int
+	var label []string
+	if opts.Order != nil {
+		subhistory = history
+		preds = [][][]int{orderPredecessors(order, len(history))}
+	} else {
+		partitions, labels := partitionOperations(model, history)
+		p, j := locateOperation(history, id, partitions)
+		if p < 0 {
+			return false
+		}
+		subhistory, id = partitions[p], j
+		if labels != nil {
+			label = labels[p : p+1]
+		}
+	}
+	_, info := checkParallel(model, [][]entry{operationEntries(subhistory, opts)}, opts, preds, label)
+	for _, partial := range info.partialLinearizations[0] {
+		for _, k := range partial {
+			if k == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// locateOperation returns the partition that contains the operation at the
+// given index in the history, and its index in that partition, or -1 if no
+// partition contains it. Partitions only contain copies of operations, so an
+// operation is located by counting the identical operations before it,
+// assuming that partitions keep operations in the order of the history.
+func locateOperation(history []Operation, id int, partitions [][]Operation) (int, int) {
+	op := history[id]
+	k := 0
+	for _, other := range history[:id] {
+		if reflect.DeepEqual(other, op) {
+			k++
+		}
+	}
+	for p, partition := range partitions {
+		for j, other := range partition {
+			if !reflect.DeepEqual(other, op) {
+				continue
+			}
+			if k == 0 {
+				return p, j
+			}
+			k--
+		}
+	}
+	return -1, -1
+}
+
+// A PartitionSize describes a partition of a history, as computed by
+// [PartitionSummary] or [PartitionSummaryEvents].
+type PartitionSize struct {
+	// Label of the partition, if the model's partition function gives
+	// partitions labels (see [Model]).
+	Label string
+	// Number of operations in the partition.
+	Operations int
+}
+
+// PartitionSummary partitions a history using the model's partition
+// function, like [CheckOperations], and returns the number of operations in
+// each partition, without checking the history. The partitions are in the
+// same order as in [LinearizationInfo.PartitionResults].
+//
+// Because the time to check a partition can grow exponentially with its
+// size, this can help with deciding whether a check is feasible, or with
+// detecting a partition function that doesn't split up the history well,
+// e.g., one that puts most operations in a single partition.
+func PartitionSummary(model Model, history []Operation) []PartitionSize {
+	model = fillDefault(model)
+	partitions, labels := partitionOperations(model, history)
+	summary := make([]PartitionSize, len(partitions))
+	for i, partition := range partitions {
+		summary[i].Operations = len(partition)
+		if labels != nil {
+			summary[i].Label = labels[i]
+		}
+	}
+	return summary
+}
+
+// PartitionSummaryEvents is like [PartitionSummary], but for a history of
+// [Event]. Operations that are still pending (that have a call but no return)
+// are counted.
+func PartitionSummaryEvents(model Model, history []Event) []PartitionSize {
+	model = fillDefault(model)
+	partitions, labels := partitionEvents(model, history)
+	summary := make([]PartitionSize, len(partitions))
+	for i, partition := range partitions {
+		for _, event := range partition {
+			if event.Kind == CallEvent {
+				summary[i].Operations++
+			}
+		}
+		if labels != nil {
+			summary[i].Label = labels[i]
+		}
+	}
+	return summary
+}
+
+// EstimateRequired estimates the timeout needed to check whether a history
+// is linearizable, which can help with choosing timeouts, e.g., for CI.
+//
+// It checks the history with increasing timeouts, starting at 1ms and
+// doubling each time, up to maxTimeout, until the result is definitive (Ok
+// or Illegal). It returns the result along with the smallest timeout that
+// produced it. If the result is still Unknown with a timeout of maxTimeout,
+// it returns Unknown and maxTimeout. The timeout required can vary between
+// runs, e.g., depending on the load of the machine, so it should be used
+// with some headroom.
+func EstimateRequired(model Model, history []Operation, maxTimeout time.Duration) (CheckResult, time.Duration) {
+	timeout := time.Millisecond
+	for {
+		if timeout > maxTimeout {
+			timeout = maxTimeout
+		}
+		res, _ := checkOperations(model, history, CheckOptions{Timeout: timeout})
+		if res != Unknown || timeout >= maxTimeout {
+			return res, timeout
+		}
+		timeout *= 2
+	}
+}
+
+// CheckOptions configures a linearizability check performed by
+// [CheckOperationsOptions] or [CheckEventsOptions].
+//
+// The zero value corresponds to the behavior of [CheckOperations] /
+// [CheckEvents].
+type CheckOptions struct {
+	// Whether to compute data that can be used to visualize the history
+	// and linearization, like [CheckOperationsVerbose] /
+	// [CheckEventsVerbose]. If false, the returned LinearizationInfo is
+	// empty.
+	Verbose bool
+	// Timeout for the check. A timeout of 0 is interpreted as an unlimited
+	// timeout.
+	Timeout time.Duration
+	// Timeout for checking each partition, which is separate from Timeout,
+	// so that one slow partition can't use up the time of the others.
+	// Partitions that exceed their timeout are reported in the
+	// [LinearizationInfo.PartitionResults], and if the other partitions are
+	// linearizable, the result is Unknown. A timeout of 0 is interpreted as
+	// an unlimited timeout.
+	PartitionTimeout time.Duration
+	// Approximate limit, in bytes, on the total memory used by the caches
+	// of all partitions, which are checked in parallel. When the limit is
+	// reached, partitions discard cached search states, which does not
+	// affect the result but may make the check slower. Memory referenced
+	// by model states is not counted. A limit of 0 means no limit.
+	MemoryLimit int64
+	// Hints for the linearizations of each partition, as sequences of
+	// operation IDs, e.g., from the [LinearizationInfo.PartialLinearizations]
+	// of a previous check of a similar history. The checker first tries to
+	// extend each hint as far as it is valid; if that fails, it falls back to
+	// a full search, so hints never affect the result, only how long the
+	// check takes. Like the results of a check, hints don't count
+	// operations whose input is a [Nemesis].
+	Hints [][]int
+	// Additional real-time ordering constraints, beyond those implied by
+	// the history, e.g., from vector clocks. Each pair [a, b] means that
+	// operation a must be linearized before operation b. For histories of
+	// [Operation], a and b are indices into the history, which count
+	// operations whose input is a [Nemesis]; for histories of
+	// [Event], they are event Ids. When this is set, the model's partition
+	// functions are not used, because constraints can span partitions.
+	Order [][2]int
+	// Precision of the timestamps of operations, for histories of
+	// [Operation]. Operations whose intervals are within this amount of
+	// each other are treated as concurrent, even if they are strictly
+	// ordered, which avoids spurious violations due to imprecise clocks.
+	// This is done by widening the interval of every operation by half of
+	// this amount on each side, so the LinearizationInfo (e.g., in
+	// visualizations) reflects the widened intervals. This has no effect on
+	// histories of [Event], which are not timestamped.
+	TimestampEpsilon int64
+	// Whether to treat the timestamps of operations as exact, for
+	// histories of [Operation]. By default, intervals are closed, so
+	// operations whose intervals touch, e.g., one that returns at the same
+	// time that another is called, are concurrent; in particular, a
+	// zero-duration operation is concurrent with every operation that is
+	// called or returns at that time. If this is set, an operation that
+	// returns at time t precedes operations that are called at time t, and
+	// zero-duration operations are atomic points in time: a zero-duration
+	// operation at time t follows operations that return at t and precedes
+	// operations that are called at t, and zero-duration operations at the
+	// same time are ordered by their position in the history. If
+	// TimestampEpsilon is set, this applies to the widened intervals, so
+	// an operation that returns exactly TimestampEpsilon before another is
+	// called still precedes it, but no operation has zero duration
+	// anymore, so none of them are atomic points in time. This has no
+	// effect on histories of [Event].
+	StrictTimestamps bool
+	// Policy for ordering calls and returns with the same timestamp, for
+	// histories of [Operation]. It reports whether the event (call or
+	// return) of kind aKind of operation a should be ordered before the
+	// event of kind bKind of operation b, given that they have the same
+	// timestamp. The call of a zero-duration operation is always ordered
+	// before its own return. If left nil, calls are ordered before
+	// returns, so operations whose intervals touch are concurrent (or the
+	// policy described for StrictTimestamps is used, if that is set).
+	TieBreak func(a Operation, aKind EventKind, b Operation, bKind EventKind) bool
+	// Whether to break ties between timestamps using the position of
+	// operations in the history, for histories of [Operation]. This is a
+	// heuristic for histories recorded with a coarse clock, where many
+	// operations have the same Call and Return timestamps, so they are all
+	// concurrent, which can make the check intractable. If this is set,
+	// it is as if every timestamp of each operation were increased by a
+	// tiny amount that is larger for operations later in the history
+	// (within their partition): operations with different timestamps are
+	// ordered as before, but events with the same timestamp are ordered
+	// by position, so, e.g., operations with the same Call and Return
+	// timestamps are ordered sequentially in the order that they appear
+	// in the history. This is only sound if that order is consistent with
+	// the real-time order of the operations, e.g., if the history was
+	// recorded in order; otherwise, the checker may report spurious
+	// violations. This takes precedence over StrictTimestamps, and it has
+	// no effect if TieBreak is set or on histories of [Event].
+	BreakTies bool
+	// Whether to cross-check the result of the checker against a naive
+	// brute-force search, which tries every order of the operations, for
+	// partitions of at most 8 operations; larger partitions are not
+	// cross-checked. If the results differ, which indicates a bug in the
+	// checker or a model whose Equal (or ObservationallyEqual, or
+	// HashState) function is inconsistent with its Step function, the
+	// check stops, its result is Unknown, and the disagreement is reported
+	// by [LinearizationInfo.Err]. This is a debugging aid, e.g., for
+	// testing a new model on small histories; it makes checks much slower.
+	CrossCheck bool
+	// Whether to stop checking the remaining partitions as soon as one
+	// partition is found to be not linearizable, in a verbose check. A
+	// check that is not verbose always does this, because the result is
+	// known, but by default, a verbose check finishes checking every
+	// partition, to compute its partial linearizations. If this is set,
+	// the partitions that were stopped are marked as Stopped in the
+	// [LinearizationInfo.PartitionResults], and their partial
+	// linearizations are not returned, so the LinearizationInfo only
+	// describes the partitions whose checks finished, including the one
+	// that failed.
+	StopOnFirstFailure bool
+	// Limit on the number of pending operations, for each partition. The
+	// operations that are pending at a point in the search are those that
+	// have been called but not yet linearized, before the earliest return
+	// of an operation that has not been linearized; they are the
+	// candidates to be linearized next, so the search can blow up
+	// exponentially in their number. If the search of a partition reaches
+	// a point with more pending operations than this, the partition is
+	// abandoned, which is reported by
+	// [PartitionResult.MaxPendingExceeded], and if the other partitions
+	// are linearizable, the result is Unknown. This bounds the worst-case
+	// time and memory of a check more predictably than a timeout. A limit
+	// of 0 means no limit.
+	MaxPending int
+	// Whether to recover from panics in the model's functions, such as a
+	// failed type assertion in Step. By default, a panic in a model
+	// function crashes the program, because partitions are checked in
+	// separate goroutines. If this is set, a panic stops the check
+	// instead, its result is Unknown, and [LinearizationInfo.Err] returns
+	// an error that identifies the partition, the function, and the
+	// operation that it was called with, along with the recovered value.
+	// This is a debugging aid for new models; it makes checks slightly
+	// slower.
+	RecoverPanics bool
+}
+
+// CheckOperationsOptions checks whether a history is linearizable, with the
+// given options.
+func CheckOperationsOptions(model Model, history []Operation, opts CheckOptions) (CheckResult, LinearizationInfo) {
+	return checkOperations(model, history, opts)
+}
+
+// CheckEventsOptions checks whether a history is linearizable, with the given
+// options.
+func CheckEventsOptions(model Model, history []Event, opts CheckOptions) (CheckResult, LinearizationInfo) {
+	return checkEvents(model, history, opts)
 }
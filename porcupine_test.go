@@ -3,13 +3,17 @@ package porcupine
 import (
 	"bufio"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"math/rand"
 	"os"
 	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type registerInput struct {
@@ -124,6 +128,1175 @@ func TestZeroDuration(t *testing.T) {
 	}
 }
 
+func TestStrictTimestamps(t *testing.T) {
+	strict := CheckOptions{StrictTimestamps: true}
+	for _, test := range []struct {
+		name   string
+		ops    []Operation
+		strict CheckResult
+	}{
+		{
+			// the read touches the end of the write
+			"instant after return",
+			[]Operation{
+				{0, registerInput{false, 1}, 0, 0, 10},
+				{1, registerInput{true, 0}, 10, 0, 10},
+			},
+			Illegal,
+		},
+		{
+			// the read touches the start of the write
+			"instant before call",
+			[]Operation{
+				{0, registerInput{false, 1}, 10, 0, 20},
+				{1, registerInput{true, 0}, 10, 1, 10},
+			},
+			Illegal,
+		},
+		{
+			"instants in history order",
+			[]Operation{
+				{0, registerInput{false, 1}, 5, 0, 5},
+				{1, registerInput{true, 0}, 5, 0, 5},
+			},
+			Illegal,
+		},
+		{
+			"instants in reverse history order",
+			[]Operation{
+				{1, registerInput{true, 0}, 5, 0, 5},
+				{0, registerInput{false, 1}, 5, 0, 5},
+			},
+			Ok,
+		},
+		{
+			"touching intervals",
+			[]Operation{
+				{0, registerInput{false, 1}, 0, 0, 10},
+				{1, registerInput{true, 0}, 10, 0, 20},
+			},
+			Illegal,
+		},
+		{
+			"instant within an interval",
+			[]Operation{
+				{0, registerInput{false, 1}, 0, 0, 10},
+				{1, registerInput{true, 0}, 5, 0, 5},
+			},
+			Ok,
+		},
+	} {
+		// by default, intervals are closed, so all of these are
+		// linearizable
+		if res, _ := CheckOperationsOptions(registerModel, test.ops, CheckOptions{}); res != Ok {
+			t.Fatalf("%s: expected output %v, got output %v", test.name, Ok, res)
+		}
+		if res, _ := CheckOperationsOptions(registerModel, test.ops, strict); res != test.strict {
+			t.Fatalf("%s: expected output %v with strict timestamps, got output %v", test.name, test.strict, res)
+		}
+	}
+}
+
+func TestStrictTimestampsEpsilon(t *testing.T) {
+	opts := CheckOptions{StrictTimestamps: true, TimestampEpsilon: 10}
+	// the widened intervals touch, so the write precedes the read
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 0, 30},
+	}
+	if res, _ := CheckOperationsOptions(registerModel, ops, CheckOptions{TimestampEpsilon: 10}); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if res, _ := CheckOperationsOptions(registerModel, ops, opts); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	// widened zero-duration operations are concurrent, rather than ordered
+	// by their position in the history
+	ops = []Operation{
+		{0, registerInput{false, 1}, 5, 0, 5},
+		{1, registerInput{true, 0}, 5, 0, 5},
+	}
+	if res, _ := CheckOperationsOptions(registerModel, ops, CheckOptions{StrictTimestamps: true}); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if res, _ := CheckOperationsOptions(registerModel, ops, opts); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
+
+func TestTieBreak(t *testing.T) {
+	// the write returns at the same tick that the read is called
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 10, 0, 20},
+	}
+	// default: calls before returns, so the operations are concurrent
+	res, _ := CheckOperationsOptions(registerModel, ops, CheckOptions{})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	returnsFirst := func(a Operation, aKind EventKind, b Operation, bKind EventKind) bool {
+		return aKind == ReturnEvent && bKind == CallEvent
+	}
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{TieBreak: returnsFirst})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	// zero-duration operations still work
+	ops = append(ops, Operation{2, registerInput{true, 0}, 30, 1, 30})
+	ops[1].Output = 1
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{TieBreak: returnsFirst})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	// a policy that uses the client: client 1 is always ahead
+	clientOrder := func(a Operation, aKind EventKind, b Operation, bKind EventKind) bool {
+		return a.ClientId > b.ClientId
+	}
+	ops = []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 10, 0, 20},
+	}
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{TieBreak: clientOrder})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
+
+func TestBreakTies(t *testing.T) {
+	// a coarse clock: operations that happened one after another, in
+	// order, all have the same timestamps
+	var ops []Operation
+	for i := 0; i < 30; i++ {
+		ops = append(ops, Operation{0, registerInput{false, i}, 5, 0, 5})
+		ops = append(ops, Operation{1, registerInput{true, 0}, 5, i, 5})
+	}
+	res, _ := CheckOperationsOptions(registerModel, ops, CheckOptions{BreakTies: true})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	// a stale read, which would be concurrent with every write by default
+	ops = append(ops, Operation{1, registerInput{true, 0}, 5, 3, 5})
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{BreakTies: true})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	// a read of a value that was never written makes the default check
+	// intractable, but breaking ties makes it easy
+	ops[len(ops)-1].Output = -1
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{Timeout: 10 * time.Millisecond})
+	if res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{BreakTies: true, Timeout: time.Second})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	// timestamps that differ are ordered as usual
+	ops = []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 5, 0, 20},
+	}
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{BreakTies: true})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
+
+func TestEstimateRequired(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 100},
+		{1, registerInput{true, 0}, 25, 100, 75},
+	}
+	res, timeout := EstimateRequired(registerModel, ops, time.Second)
+	if res != Ok || timeout != time.Millisecond {
+		t.Fatalf("expected output %v with timeout %v, got output %v with timeout %v", Ok, time.Millisecond, res, timeout)
+	}
+
+	// many concurrent writes, and a read of a value that was never
+	// written, so the checker has to try every order of the writes
+	ops = nil
+	for i := 0; i < 30; i++ {
+		ops = append(ops, Operation{i, registerInput{false, i}, 0, 0, 100})
+	}
+	ops = append(ops, Operation{30, registerInput{true, 0}, 0, -1, 100})
+	res, timeout = EstimateRequired(registerModel, ops, 5*time.Millisecond)
+	if res != Unknown || timeout != 5*time.Millisecond {
+		t.Fatalf("expected output %v with timeout %v, got output %v with timeout %v", Unknown, 5*time.Millisecond, res, timeout)
+	}
+}
+
+func TestPartitionTimeout(t *testing.T) {
+	// partition "x" is intractable: many concurrent writes, and a read of
+	// a value that was never written
+	var ops []Operation
+	for i := 0; i < 30; i++ {
+		ops = append(ops, Operation{i, kvInput{op: 1, key: "x", value: strconv.Itoa(i)}, 0, kvOutput{}, 100})
+	}
+	ops = append(ops, Operation{30, kvInput{op: 0, key: "x"}, 0, kvOutput{"none"}, 100})
+	ops = append(ops,
+		Operation{31, kvInput{op: 1, key: "y", value: "a"}, 0, kvOutput{}, 10},
+		Operation{31, kvInput{op: 0, key: "y"}, 20, kvOutput{"a"}, 30},
+	)
+	opts := CheckOptions{Verbose: true, PartitionTimeout: 10 * time.Millisecond}
+	res, info := CheckOperationsOptions(kvModel, ops, opts)
+	if res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	results := info.PartitionResults()
+	if !results[0].TimedOut || results[0].Linearizable {
+		t.Fatalf("expected partition x to time out, got %+v", results[0])
+	}
+	if results[1].TimedOut || !results[1].Linearizable {
+		t.Fatalf("expected partition y to be linearizable, got %+v", results[1])
+	}
+
+	// a partition that is not linearizable still makes the result Illegal
+	ops[len(ops)-1].Output = kvOutput{"b"}
+	res, info = CheckOperationsOptions(kvModel, ops, opts)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if results := info.PartitionResults(); !results[0].TimedOut || results[1].TimedOut || results[1].Linearizable {
+		t.Fatalf("unexpected partition results %+v", results)
+	}
+	opts.Verbose = false
+	if res, _ := CheckOperationsOptions(kvModel, ops, opts); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
+
+func TestPartitionStopped(t *testing.T) {
+	// partition "x" is intractable, as in TestPartitionTimeout, and
+	// partition "y" is not linearizable
+	var ops []Operation
+	for i := 0; i < 30; i++ {
+		ops = append(ops, Operation{i, kvInput{op: 1, key: "x", value: strconv.Itoa(i)}, 0, kvOutput{}, 100})
+	}
+	ops = append(ops, Operation{30, kvInput{op: 0, key: "x"}, 0, kvOutput{"none"}, 100})
+	ops = append(ops,
+		Operation{31, kvInput{op: 1, key: "y", value: "a"}, 0, kvOutput{}, 10},
+		Operation{31, kvInput{op: 0, key: "y"}, 20, kvOutput{"b"}, 30},
+	)
+	res, info := CheckOperationsOptions(kvModel, ops, CheckOptions{})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	results := info.PartitionResults()
+	if len(results) != 2 {
+		t.Fatalf("expected 2 partition results, got %d", len(results))
+	}
+	if !results[0].Stopped {
+		t.Fatalf("expected partition x to be stopped, got %+v", results[0])
+	}
+	if results[1].Stopped || results[1].TimedOut || results[1].Linearizable {
+		t.Fatalf("expected partition y to be not linearizable, got %+v", results[1])
+	}
+
+	// with a verbose check, only the overall timeout stops partitions
+	ops[len(ops)-1].Output = kvOutput{"a"}
+	res, info = CheckOperationsOptions(kvModel, ops, CheckOptions{Verbose: true, Timeout: 10 * time.Millisecond})
+	if res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	results = info.PartitionResults()
+	if !results[0].Stopped || results[0].Linearizable {
+		t.Fatalf("expected partition x to be stopped, got %+v", results[0])
+	}
+	if results[1].Stopped || !results[1].Linearizable {
+		t.Fatalf("expected partition y to be linearizable, got %+v", results[1])
+	}
+
+	// unless the check stops on the first failure
+	ops[len(ops)-1].Output = kvOutput{"b"}
+	res, info = CheckOperationsOptions(kvModel, ops, CheckOptions{Verbose: true, StopOnFirstFailure: true})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	results = info.PartitionResults()
+	if !results[0].Stopped {
+		t.Fatalf("expected partition x to be stopped, got %+v", results[0])
+	}
+	if results[1].Stopped || results[1].Linearizable {
+		t.Fatalf("expected partition y to be not linearizable, got %+v", results[1])
+	}
+	partials := info.PartialLinearizations()
+	if len(partials[0]) != 0 || len(partials[1]) == 0 {
+		t.Fatalf("expected partial linearizations only for partition y, got %v", partials)
+	}
+	visualizeTempFile(t, kvModel, info)
+}
+
+func TestMaxPending(t *testing.T) {
+	// partition "x" is intractable, as in TestPartitionTimeout, and
+	// partition "y" is sequential
+	var ops []Operation
+	for i := 0; i < 30; i++ {
+		ops = append(ops, Operation{i, kvInput{op: 1, key: "x", value: strconv.Itoa(i)}, 0, kvOutput{}, 100})
+	}
+	ops = append(ops, Operation{30, kvInput{op: 0, key: "x"}, 0, kvOutput{"none"}, 100})
+	ops = append(ops,
+		Operation{31, kvInput{op: 1, key: "y", value: "a"}, 0, kvOutput{}, 10},
+		Operation{31, kvInput{op: 0, key: "y"}, 20, kvOutput{"a"}, 30},
+	)
+	res, info := CheckOperationsOptions(kvModel, ops, CheckOptions{Verbose: true, MaxPending: 10})
+	if res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	results := info.PartitionResults()
+	if !results[0].MaxPendingExceeded || results[0].Stopped || results[0].TimedOut {
+		t.Fatalf("expected partition x to exceed the limit, got %+v", results[0])
+	}
+	if results[1].MaxPendingExceeded || !results[1].Linearizable {
+		t.Fatalf("expected partition y to be linearizable, got %+v", results[1])
+	}
+
+	// the limit doesn't affect partitions that stay within it
+	res, _ = CheckOperationsOptions(kvModel, ops[31:], CheckOptions{MaxPending: 1})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	ops[len(ops)-1].Output = kvOutput{"b"}
+	res, _ = CheckOperationsOptions(kvModel, ops[31:], CheckOptions{MaxPending: 1})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
+
+func TestPartitionResults(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"b"}, 30},
+		{2, kvInput{op: 1, key: "y", value: "c"}, 0, kvOutput{}, 10},
+		{3, kvInput{op: 0, key: "y"}, 5, kvOutput{"c"}, 30},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	results := info.PartitionResults()
+	if len(results) != 2 {
+		t.Fatalf("expected 2 partitions, got %d", len(results))
+	}
+	if results[0].Linearizable || !results[1].Linearizable {
+		t.Fatalf("unexpected per-partition results %v", results)
+	}
+	// x: put('a') is the only step that can be taken
+	// y: put('c'), then get() -> 'c'
+	if results[0].States != 1 || results[1].States != 2 {
+		t.Fatalf("unexpected number of states %v", results)
+	}
+}
+
+func TestLongestLinearizablePrefix(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 100, 30},
+		{2, registerInput{false, 200}, 40, 0, 50},
+		{0, registerInput{true, 0}, 60, 200, 70},
+		{1, registerInput{true, 0}, 80, 100, 90}, // stale read
+		{2, registerInput{true, 0}, 100, 200, 110},
+	}
+	if n := LongestLinearizablePrefix(registerModel, ops); n != 4 {
+		t.Fatalf("expected longest linearizable prefix to have length 4, got %d", n)
+	}
+	// order of the given history shouldn't matter
+	reversed := make([]Operation, len(ops))
+	for i, op := range ops {
+		reversed[len(ops)-1-i] = op
+	}
+	if n := LongestLinearizablePrefix(registerModel, reversed); n != 4 {
+		t.Fatalf("expected longest linearizable prefix to have length 4, got %d", n)
+	}
+	if n := LongestLinearizablePrefix(registerModel, ops[:4]); n != 4 {
+		t.Fatalf("expected entire history to be linearizable, got prefix length %d", n)
+	}
+}
+
+func TestCanLinearize(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 100, 30},
+		{2, registerInput{false, 200}, 40, 0, 95},
+		{0, registerInput{true, 0}, 60, 300, 70}, // illegal read
+		{1, registerInput{true, 0}, 80, 200, 90},
+		{2, registerInput{true, 0}, 100, 200, 110},
+	}
+	expected := []bool{true, true, true, false, false, false}
+	for id, ok := range expected {
+		if CanLinearize(registerModel, ops, id) != ok {
+			t.Errorf("expected CanLinearize(%d) to be %v", id, ok)
+		}
+	}
+	if CanLinearize(registerModel, ops, len(ops)) {
+		t.Error("expected out-of-range operation not to be linearizable")
+	}
+
+	// only the partition containing the operation matters
+	kvOps := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"z"}, 30},
+		{2, kvInput{op: 1, key: "a", value: "b"}, 40, kvOutput{}, 50},
+		{0, kvInput{op: 0, key: "a"}, 60, kvOutput{"b"}, 70},
+	}
+	if CheckOperations(kvModel, kvOps) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	expected = []bool{true, false, true, true}
+	for id, ok := range expected {
+		if CanLinearize(kvModel, kvOps, id) != ok {
+			t.Errorf("expected CanLinearize(%d) to be %v for kv history", id, ok)
+		}
+	}
+
+	// indices refer to the history, including nemeses, which are never
+	// linearized
+	withNemesis := append([]Operation{kvOps[0], {-1, Nemesis{Description: "partition"}, 15, nil, 25}}, kvOps[1:]...)
+	expected = []bool{true, false, false, true, true}
+	for id, ok := range expected {
+		if CanLinearize(kvModel, withNemesis, id) != ok {
+			t.Errorf("expected CanLinearize(%d) to be %v for history with nemesis", id, ok)
+		}
+	}
+}
+
+func TestCanLinearizeOptions(t *testing.T) {
+	// the read is called 2 after the write returns
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 12, 0, 20},
+	}
+	if CanLinearize(registerModel, ops, 1) {
+		t.Error("expected read not to be linearizable")
+	}
+	if !CanLinearizeOptions(registerModel, ops, 1, CheckOptions{TimestampEpsilon: 4}) {
+		t.Error("expected read to be linearizable with widened intervals")
+	}
+
+	// the read is called when the write returns
+	ops[1].Call = 10
+	if !CanLinearize(registerModel, ops, 1) {
+		t.Error("expected read to be linearizable")
+	}
+	if CanLinearizeOptions(registerModel, ops, 1, CheckOptions{StrictTimestamps: true}) {
+		t.Error("expected read not to be linearizable with strict timestamps")
+	}
+
+	// ordering constraints refer to indices in the history
+	ops[1].Call = 5
+	if CanLinearizeOptions(registerModel, ops, 1, CheckOptions{Order: [][2]int{{0, 1}}}) {
+		t.Error("expected read not to be linearizable after the write")
+	}
+
+	// identical operations are located by index
+	ops = []Operation{
+		{0, registerInput{true, 0}, 0, 0, 10},
+		{0, registerInput{false, 1}, 20, 0, 30},
+		{0, registerInput{true, 0}, 0, 0, 10},
+		{1, registerInput{true, 0}, 40, 2, 50},
+	}
+	expected := []bool{true, true, true, false}
+	for id, ok := range expected {
+		if CanLinearize(registerModel, ops, id) != ok {
+			t.Errorf("expected CanLinearize(%d) to be %v", id, ok)
+		}
+	}
+}
+
+func TestCheckOperationsFrom(t *testing.T) {
+	// a suffix of a history, after the register was set to 100
+	ops := []Operation{
+		{0, registerInput{true, 0}, 0, 100, 10},
+		{1, registerInput{false, 200}, 20, 0, 30},
+		{0, registerInput{true, 0}, 25, 100, 35},
+	}
+	if CheckOperations(registerModel, ops) {
+		t.Fatal("expected operations not to be linearizable from the default initial state")
+	}
+	if !CheckOperationsFrom(registerModel, ops, 100) {
+		t.Fatal("expected operations to be linearizable from the given initial state")
+	}
+	if CheckOperationsFrom(registerModel, ops, 200) {
+		t.Fatal("expected operations not to be linearizable from the given initial state")
+	}
+
+	events := []Event{
+		{0, CallEvent, kvInput{op: 0, key: "x"}, 0},
+		{0, ReturnEvent, kvOutput{"y"}, 0},
+	}
+	if !CheckEventsFrom(kvModel, events, "y") {
+		t.Fatal("expected events to be linearizable from the given initial state")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected initial state of the wrong type to panic")
+		}
+	}()
+	CheckOperationsFrom(registerModel, ops, "100")
+}
+
+func TestObservationallyEqual(t *testing.T) {
+	// a register that also keeps track of its previous value, which doesn't
+	// affect the result of any future operation
+	type historyRegister struct {
+		value    int
+		previous int
+	}
+	model := Model{
+		Init: func() interface{} {
+			return historyRegister{}
+		},
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			st := state.(historyRegister)
+			inp := input.(registerInput)
+			if !inp.op {
+				return true, historyRegister{inp.value, st.value}
+			}
+			return output == st.value, st
+		},
+	}
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{1, registerInput{false, 2}, 0, 0, 100},
+		{2, registerInput{false, 1}, 0, 0, 100},
+		{3, registerInput{true, 0}, 0, 1, 100},
+		{4, registerInput{true, 0}, 0, 3, 100},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	exact := info.PartitionResults()[0].States
+
+	model.ObservationallyEqual = func(state1, state2 interface{}) bool {
+		return state1.(historyRegister).value == state2.(historyRegister).value
+	}
+	res, info = CheckOperationsVerbose(model, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	merged := info.PartitionResults()[0].States
+	if merged >= exact {
+		t.Fatalf("expected fewer states with observational equality, got %d (vs %d)", merged, exact)
+	}
+
+	ops[4] = Operation{4, registerInput{true, 0}, 0, 2, 100}
+	if !CheckOperations(model, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
+
+func TestPendingOperations(t *testing.T) {
+	model := registerModel
+	model.Step = func(state, input, output interface{}) (bool, interface{}) {
+		if output == (PendingOutput{}) {
+			regInput := input.(registerInput)
+			if regInput.op == false {
+				return true, regInput.value
+			}
+			return true, state
+		}
+		return registerModel.Step(state, input, output)
+	}
+	model.DescribeOperation = func(input, output interface{}) string {
+		if output == (PendingOutput{}) {
+			return fmt.Sprintf("%v -> pending", input)
+		}
+		return registerModel.DescribeOperation(input, output)
+	}
+
+	// the write to 100 is pending at the end, but it must have taken
+	// effect for C1 to read 100
+	events := []Event{
+		{0, CallEvent, registerInput{false, 100}, 0},
+		{1, CallEvent, registerInput{true, 0}, 1},
+		{1, ReturnEvent, 100, 1},
+		{2, CallEvent, registerInput{true, 0}, 2},
+	}
+	res, info := CheckEventsVerbose(model, events, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// a pending read doesn't constrain anything
+	events = []Event{
+		{0, CallEvent, registerInput{false, 100}, 0},
+		{2, CallEvent, registerInput{true, 0}, 2},
+		{0, ReturnEvent, 0, 0},
+	}
+	if !CheckEvents(model, events) {
+		t.Fatal("expected operations to be linearizable")
+	}
+
+	// a pending write can't explain a read of a value that was never
+	// written
+	events = []Event{
+		{0, CallEvent, registerInput{false, 100}, 0},
+		{1, CallEvent, registerInput{true, 0}, 1},
+		{1, ReturnEvent, 200, 1},
+	}
+	if CheckEvents(model, events) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
+
+func TestBuiltinModelsPending(t *testing.T) {
+	lww := LWWRegisterModel()
+	tests := []struct {
+		name   string
+		model  Model
+		effect interface{} // input of an operation that is pending, but must have taken effect
+		read   interface{} // input of an operation that observes the effect
+		output interface{} // output of the read
+		other  interface{} // input of another operation that is pending
+	}{
+		{"set", SetModel(), SetInput{Op: SetAdd, Value: 1}, SetInput{Op: SetRead}, SetOutput{Values: []interface{}{1}}, SetInput{Op: SetRead}},
+		{"gset", GrowOnlySetModel(), SetInput{Op: SetAdd, Value: 1}, SetInput{Op: SetRead}, SetOutput{Values: []interface{}{1}}, SetInput{Op: SetRead}},
+		{"log", LogModel(), LogInput{Op: LogAppend, Value: 1}, LogInput{Op: LogReadLatest}, LogOutput{Value: 1, Exists: true}, LogInput{Op: LogRead}},
+		{"scan", ScanModel(), ScanInput{Op: ScanPut, Key: "x", Value: 1}, ScanInput{Op: ScanGet, Key: "x"}, ScanOutput{Value: 1, Exists: true}, ScanInput{Op: ScanRange}},
+		{"buffer", BoundedBufferModel(1), BufferInput{Op: BufferEnqueue, Value: 1}, BufferInput{Op: BufferEnqueue, Value: 2}, BufferOutput{}, BufferInput{Op: BufferDequeue}},
+		{"writeonce", WriteOnceRegisterModel(), WriteOnceInput{Op: WriteOnceWrite, Value: 1}, WriteOnceInput{Op: WriteOnceRead}, WriteOnceOutput{Ok: true, Value: 1}, WriteOnceInput{Op: WriteOnceWrite, Value: 2}},
+		{"lease", LeaseModel(), LeaseInput{Op: LeaseAcquire, Owner: "a", Term: 1, Duration: 100}, LeaseInput{Op: LeaseRead, Time: 10}, LeaseOutput{Owner: "a", Term: 1}, LeaseInput{Op: LeaseRenew, Owner: "a", Term: 1, Time: 20, Duration: 100}},
+		{"lww", lww.ToModel(), LWWInput{Op: LWWWrite, Value: 1, Timestamp: 5}, LWWInput{Op: LWWRead}, LWWOutput{Value: 1, Timestamp: 5}, LWWInput{Op: LWWRead}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []Event{
+				{0, CallEvent, tt.effect, 0},
+				{1, CallEvent, tt.read, 1},
+				{1, ReturnEvent, tt.output, 1},
+				{2, CallEvent, tt.other, 2},
+			}
+			res, info := CheckEventsVerbose(tt.model, events, 0)
+			if res != Ok {
+				t.Fatalf("expected output %v, got output %v", Ok, res)
+			}
+			data := computeVisualizationData(tt.model, info, VisualizeOptions{})
+			for _, elem := range data.Partitions[0].History {
+				if elem.ClientId != 1 && !strings.HasSuffix(elem.Description, "pending") {
+					t.Fatalf("expected pending description, got %q", elem.Description)
+				}
+			}
+
+			// without the pending operation, nothing explains the read
+			if CheckEvents(tt.model, events[1:]) {
+				t.Fatal("expected operations not to be linearizable")
+			}
+		})
+	}
+}
+
+func TestLazyVerbose(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 100},
+		{1, registerInput{true, 0}, 25, 100, 75},
+		{2, registerInput{true, 0}, 30, 0, 60},
+	}
+	res, info := CheckOperationsLazyVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if len(info.PartialLinearizations()) != 0 {
+		t.Fatal("expected no partial linearizations")
+	}
+
+	ops[2] = Operation{2, registerInput{true, 0}, 80, 0, 90}
+	res, info = CheckOperationsLazyVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if len(info.PartialLinearizations()) != 1 {
+		t.Fatal("expected partial linearizations")
+	}
+	visualizeTempFile(t, registerModel, info)
+
+	events := []Event{
+		{0, CallEvent, registerInput{false, 100}, 0},
+		{0, ReturnEvent, 0, 0},
+		{1, CallEvent, registerInput{true, 0}, 1},
+		{1, ReturnEvent, 0, 1},
+	}
+	res, info = CheckEventsLazyVerbose(registerModel, events, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if len(info.PartialLinearizations()) != 1 {
+		t.Fatal("expected partial linearizations")
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	events := []Event{
+		{0, CallEvent, kvInput{op: 1, key: "x", value: "a"}, 0},
+		{1, CallEvent, kvInput{op: 1, key: "y", value: "b"}, 1},
+		{0, ReturnEvent, kvOutput{}, 0},
+		{1, ReturnEvent, kvOutput{}, 1},
+		{0, CallEvent, kvInput{op: 0, key: "x"}, 2},
+		{1, CallEvent, kvInput{op: 0, key: "y"}, 3},
+		{0, ReturnEvent, kvOutput{"a"}, 2},
+		{1, ReturnEvent, kvOutput{"c"}, 3},
+	}
+	expected := `partition: linearizable
+  linearization:
+    0: {1 x a} -> {}
+    1: {0 x } -> {a}
+partition: not linearizable
+  linearization:
+    0: {1 y b} -> {}
+`
+	// kvModel's PartitionEvent returns partitions in a random order
+	for i := 0; i < 10; i++ {
+		_, info := CheckEventsVerbose(kvModel, events, 0)
+		if canonical := info.Canonical(); canonical != expected {
+			t.Fatalf("expected canonical form\n%s\ngot\n%s", expected, canonical)
+		}
+	}
+}
+
+func TestPartitionLabeled(t *testing.T) {
+	model := kvModel
+	model.Partition = nil
+	model.PartitionEvent = nil
+	model.PartitionLabeled = func(history []Operation) map[string][]Operation {
+		m := make(map[string][]Operation)
+		for _, v := range history {
+			label := "key=" + v.Input.(kvInput).key
+			m[label] = append(m[label], v)
+		}
+		return m
+	}
+	model.PartitionEventLabeled = func(history []Event) map[string][]Event {
+		m := make(map[string][]Event)
+		match := make(map[int]string) // id -> label
+		for _, v := range history {
+			if v.Kind == CallEvent {
+				match[v.Id] = "key=" + v.Value.(kvInput).key
+			}
+			m[match[v.Id]] = append(m[match[v.Id]], v)
+		}
+		return m
+	}
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "y", value: "b"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{0, kvInput{op: 0, key: "y"}, 20, kvOutput{"c"}, 30},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	expected := []PartitionResult{
+		{Label: "key=x", Linearizable: true, States: 1},
+		{Label: "key=y", Linearizable: false, States: 1},
+	}
+	if !reflect.DeepEqual(info.PartitionResults(), expected) {
+		t.Fatalf("expected partition results %v, got %v", expected, info.PartitionResults())
+	}
+	if !strings.HasPrefix(info.Canonical(), `partition "key=x": linearizable`) {
+		t.Fatalf("unexpected canonical form\n%s", info.Canonical())
+	}
+	data := computeVisualizationData(model, info, VisualizeOptions{})
+	if data.Partitions[1].Label != "key=y" {
+		t.Fatalf("expected visualization label %q, got %q", "key=y", data.Partitions[1].Label)
+	}
+	visualizeTempFile(t, model, info)
+
+	events := []Event{
+		{0, CallEvent, kvInput{op: 1, key: "y", value: "b"}, 0},
+		{0, ReturnEvent, kvOutput{}, 0},
+		{1, CallEvent, kvInput{op: 0, key: "y"}, 1},
+		{1, ReturnEvent, kvOutput{"b"}, 1},
+	}
+	res, info = CheckEventsVerbose(model, events, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if results := info.PartitionResults(); len(results) != 1 || results[0].Label != "key=y" {
+		t.Fatalf("unexpected partition results %v", results)
+	}
+}
+
+func TestPartitionSummary(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "y", value: "b"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{0, kvInput{op: 0, key: "y"}, 20, kvOutput{"c"}, 30},
+	}
+	expected := []PartitionSize{{Operations: 1}, {Operations: 2}}
+	if summary := PartitionSummary(kvModel, ops); !reflect.DeepEqual(summary, expected) {
+		t.Fatalf("expected summary %v, got %v", expected, summary)
+	}
+	expected = []PartitionSize{{Operations: 3}}
+	if summary := PartitionSummary(kvNoPartitionModel, ops); !reflect.DeepEqual(summary, expected) {
+		t.Fatalf("expected summary %v, got %v", expected, summary)
+	}
+
+	// labeled partitions, with a pending operation
+	model := kvModel
+	model.PartitionEventLabeled = func(history []Event) map[string][]Event {
+		m := make(map[string][]Event)
+		for _, partition := range kvModel.PartitionEvent(history) {
+			m["key="+partition[0].Value.(kvInput).key] = partition
+		}
+		return m
+	}
+	events := []Event{
+		{0, CallEvent, kvInput{op: 1, key: "y", value: "b"}, 0},
+		{1, CallEvent, kvInput{op: 0, key: "x"}, 1},
+		{0, ReturnEvent, kvOutput{}, 0},
+		{0, CallEvent, kvInput{op: 0, key: "y"}, 2},
+		{0, ReturnEvent, kvOutput{"b"}, 2},
+	}
+	expected = []PartitionSize{{Label: "key=x", Operations: 1}, {Label: "key=y", Operations: 2}}
+	if summary := PartitionSummaryEvents(model, events); !reflect.DeepEqual(summary, expected) {
+		t.Fatalf("expected summary %v, got %v", expected, summary)
+	}
+}
+
+func TestTimestampEpsilon(t *testing.T) {
+	// the read is called 150 time units after the write of 200 returns,
+	// but with imprecise clocks, it might actually have been concurrent
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 10},
+		{1, registerInput{false, 200}, 1000, 0, 1100},
+		{2, registerInput{true, 0}, 1250, 100, 1300},
+	}
+	if CheckOperations(registerModel, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	res, _ := CheckOperationsOptions(registerModel, ops, CheckOptions{TimestampEpsilon: 149})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{TimestampEpsilon: 150})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{TimestampEpsilon: 151, Order: [][2]int{}})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
+
+func TestMergeLinearizationInfo(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 100},
+		{1, registerInput{true, 0}, 10, 100, 30},
+		{2, registerInput{true, 0}, 40, 0, 90},
+	}
+	_, info := CheckOperationsVerbose(registerModel, ops, 0)
+	// simulate runs that found different partial linearizations
+	first, second := info, info
+	first.partialLinearizations = [][][]int{{{0, 1}}}
+	second.partialLinearizations = [][][]int{{{2}, {0, 1}}}
+	if err := first.Merge(second); err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+	expected := [][][]int{{{0, 1}, {2}}}
+	if !reflect.DeepEqual(first.PartialLinearizations(), expected) {
+		t.Fatalf("expected partial linearizations %v, got %v", expected, first.PartialLinearizations())
+	}
+	visualizeTempFile(t, registerModel, first)
+
+	ops[2].Output = 100
+	_, other := CheckOperationsVerbose(registerModel, ops, 0)
+	if err := first.Merge(other); err == nil {
+		t.Fatal("expected error merging different histories")
+	}
+	if !reflect.DeepEqual(first.PartialLinearizations(), expected) {
+		t.Fatal("expected failed merge not to modify info")
+	}
+}
+
+func TestTraced(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 100, 30},
+		{2, registerInput{true, 0}, 20, 0, 30},
+	}
+	var b strings.Builder
+	res := CheckOperations(registerModel.Traced(&b), ops)
+	if res != false {
+		t.Fatal("expected operations to not be linearizable")
+	}
+	expected := `Step(0, put('100')) -> 100
+Step(100, get() -> '100') -> 100
+Step(100, get() -> '0') -> rejected
+Step(100, get() -> '0') -> rejected
+`
+	if b.String() != expected {
+		t.Fatalf("expected trace\n%s\ngot\n%s", expected, b.String())
+	}
+}
+
+func TestPurityChecked(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 2, key: "x", value: "z"}, 5, kvOutput{}, 15},
+		{2, kvInput{op: 0, key: "x"}, 20, kvOutput{"yz"}, 30},
+	}
+	if !CheckOperations(kvNoPartitionModel.PurityChecked(), ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+
+	// like kvNoPartitionModel, but modifies the map in place
+	impure := kvNoPartitionModel
+	impure.Step = func(state, input, output interface{}) (bool, interface{}) {
+		inp := input.(kvInput)
+		st := state.(map[string]string)
+		if inp.op == 0 {
+			return output.(kvOutput).value == st[inp.key], state
+		}
+		st[inp.key] = inp.value
+		return true, st
+	}
+	model := impure.PurityChecked()
+	defer func() {
+		expected := "porcupine: model Step function mutated its arguments: Step(map[], {1 x y} -> {})"
+		if r := recover(); r != expected {
+			t.Fatalf("expected panic %q, got %v", expected, r)
+		}
+	}()
+	model.Step(model.Init(), kvInput{op: 1, key: "x", value: "y"}, kvOutput{})
+	t.Fatal("expected Step to panic")
+}
+
+func TestValidateSequence(t *testing.T) {
+	pairs := []struct{ In, Out interface{} }{
+		{kvInput{op: 1, key: "x", value: "y"}, kvOutput{}},
+		{kvInput{op: 2, key: "x", value: "z"}, kvOutput{}},
+		{kvInput{op: 0, key: "x"}, kvOutput{"yz"}},
+		{kvInput{op: 0, key: "y"}, kvOutput{""}},
+	}
+	if ok, i := kvNoPartitionModel.ValidateSequence(pairs); !ok || i != -1 {
+		t.Fatalf("expected sequence to be valid, got %v, %d", ok, i)
+	}
+	pairs[2].Out = kvOutput{"zy"}
+	if ok, i := kvNoPartitionModel.ValidateSequence(pairs); ok || i != 2 {
+		t.Fatalf("expected operation 2 to be rejected, got %v, %d", ok, i)
+	}
+}
+
+func TestMissingModelFunctions(t *testing.T) {
+	expectPanic := func(model Model, message string) {
+		t.Helper()
+		defer func() {
+			r := recover()
+			if r != message {
+				t.Fatalf("expected panic %q, got %v", message, r)
+			}
+		}()
+		CheckOperations(model, []Operation{{0, registerInput{false, 100}, 0, 0, 100}})
+	}
+	model := registerModel
+	model.Init = nil
+	expectPanic(model, "porcupine: model has no Init function")
+	model = registerModel
+	model.Step = nil
+	expectPanic(model, "porcupine: model has no Step function (or StepVerbose function)")
+}
+
+func TestRecoverPanics(t *testing.T) {
+	model := registerModel
+	step := model.Step
+	model.Step = func(state, input, output interface{}) (bool, interface{}) {
+		if input.(registerInput).value == 2 {
+			var m map[int]int
+			m[0] = 1
+		}
+		return step(state, input, output)
+	}
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 20, 0, 30},
+	}
+	res, info := CheckOperationsOptions(model, ops, CheckOptions{RecoverPanics: true})
+	if res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	expected := "porcupine: model panicked in partition 0: Step(1, put('2')) panicked: assignment to entry in nil map"
+	if err := info.Err(); err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, got %v", expected, err)
+	}
+
+	// a panic in one partition stops the others: here, the second
+	// partition would take seconds to check
+	model.Partition = func(history []Operation) [][]Operation {
+		return [][]Operation{history[:2], history[2:]}
+	}
+	panickingStep := model.Step
+	model.Step = func(state, input, output interface{}) (bool, interface{}) {
+		if input.(registerInput).value == 3 {
+			time.Sleep(time.Millisecond)
+		}
+		return panickingStep(state, input, output)
+	}
+	for i := 0; i < 5000; i++ {
+		ops = append(ops, Operation{i + 2, registerInput{false, 3}, 0, 0, 10})
+	}
+	start := time.Now()
+	res, info = CheckOperationsOptions(model, ops, CheckOptions{RecoverPanics: true, Verbose: true})
+	if res != Unknown || info.Err() == nil {
+		t.Fatalf("expected output %v with an error, got output %v with error %v", Unknown, res, info.Err())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the other partitions to be stopped, but the check took %v", elapsed)
+	}
+}
+
+func TestInvalidEventPartition(t *testing.T) {
+	events := []Event{
+		{0, CallEvent, registerInput{false, 1}, 0},
+		{1, CallEvent, registerInput{true, 0}, 1},
+		{0, ReturnEvent, 0, 0},
+		{1, ReturnEvent, 0, 1},
+	}
+	expectPanic := func(partition func(history []Event) [][]Event, message string) {
+		t.Helper()
+		defer func() {
+			r := recover()
+			if r != message {
+				t.Fatalf("expected panic %q, got %v", message, r)
+			}
+		}()
+		model := registerModel
+		model.PartitionEvent = partition
+		CheckEvents(model, events)
+	}
+	// splits by position rather than by id
+	expectPanic(func(history []Event) [][]Event {
+		return [][]Event{history[:2], history[2:]}
+	}, "porcupine: model's event partition function is invalid: partition 0: call for operation 0 has its return in a different partition")
+	// drops a call
+	expectPanic(func(history []Event) [][]Event {
+		return [][]Event{history[1:]}
+	}, "porcupine: model's event partition function is invalid: partition 0: return for operation 0 has no preceding call")
+	// a well-formed partition is fine
+	model := registerModel
+	model.PartitionEvent = func(history []Event) [][]Event {
+		return [][]Event{{history[0], history[2]}, {history[1], history[3]}}
+	}
+	if !CheckEvents(model, events) {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
+
+func TestReachableStates(t *testing.T) {
+	operations := []Operation{
+		{Input: registerInput{false, 1}, Output: 0},
+		{Input: registerInput{false, 2}, Output: 0},
+		{Input: registerInput{true, 0}, Output: 1},
+	}
+	states := ReachableStates(registerModel, operations, 0)
+	if !reflect.DeepEqual(states, []interface{}{0}) {
+		t.Fatalf("unexpected reachable states %v", states)
+	}
+	states = ReachableStates(registerModel, operations, 5)
+	if !reflect.DeepEqual(states, []interface{}{0, 1, 2}) {
+		t.Fatalf("unexpected reachable states %v", states)
+	}
+}
+
+func TestOrderConstraints(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{1, registerInput{false, 2}, 0, 0, 100},
+		{2, registerInput{true, 0}, 200, 2, 300},
+	}
+	if !CheckOperationsWithOrder(registerModel, ops, nil) {
+		t.Fatal("expected operations to be linearizable")
+	}
+	if !CheckOperationsWithOrder(registerModel, ops, [][2]int{{0, 1}}) {
+		t.Fatal("expected operations to be linearizable")
+	}
+	// if we know that put(2) happened before put(1), the read is illegal
+	if CheckOperationsWithOrder(registerModel, ops, [][2]int{{1, 0}}) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	// a cycle can never be satisfied
+	if CheckOperationsWithOrder(registerModel, ops, [][2]int{{0, 1}, {1, 0}}) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+
+	// same thing, with events, where constraints are on event ids
+	events := []Event{
+		{0, CallEvent, registerInput{false, 1}, 10},
+		{1, CallEvent, registerInput{false, 2}, 20},
+		{0, ReturnEvent, 0, 10},
+		{1, ReturnEvent, 0, 20},
+		{2, CallEvent, registerInput{true, 0}, 30},
+		{2, ReturnEvent, 2, 30},
+	}
+	res, _ := CheckEventsOptions(registerModel, events, CheckOptions{Order: [][2]int{{10, 20}}})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	res, _ = CheckEventsOptions(registerModel, events, CheckOptions{Order: [][2]int{{20, 10}}})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
+
+func TestCheckWithPartitions(t *testing.T) {
+	byKey := kvModel.Partition
+	whole := func(history []Operation) [][]Operation {
+		return [][]Operation{history}
+	}
+	// every key is read and written concurrently by several clients
+	var ops []Operation
+	for k := 0; k < 10; k++ {
+		key := fmt.Sprint(k)
+		for c := 0; c < 4; c++ {
+			ops = append(ops, Operation{c, kvInput{op: 1, key: key, value: fmt.Sprint(c)}, 0, kvOutput{}, 100})
+			ops = append(ops, Operation{c + 4, kvInput{op: 0, key: key}, 0, kvOutput{fmt.Sprint(c)}, 100})
+		}
+	}
+	res, i := CheckWithPartitions(kvNoPartitionModel, ops, 0, whole, byKey)
+	if res != Ok || i != 0 {
+		t.Fatalf("expected %v from partition function 0, got %v from %d", Ok, res, i)
+	}
+	// a read of a value that was never written is easy to rule out one key
+	// at a time, but intractable for the whole history at once
+	ops = append(ops, Operation{8, kvInput{op: 0, key: "0"}, 0, kvOutput{"x"}, 100})
+	res, i = CheckWithPartitions(kvNoPartitionModel, ops, 100*time.Millisecond, whole, byKey)
+	if res != Illegal || i != 1 {
+		t.Fatalf("expected %v from partition function 1, got %v from %d", Illegal, res, i)
+	}
+	res, i = CheckWithPartitions(kvNoPartitionModel, ops, 100*time.Millisecond, whole)
+	if res != Unknown || i != -1 {
+		t.Fatalf("expected %v, got %v from %d", Unknown, res, i)
+	}
+}
+
+func TestCheckByClient(t *testing.T) {
+	// client 1 reads the old value after client 0's put returns, which is
+	// sequentially consistent but not linearizable
+	history := map[int][]Operation{
+		0: {
+			{Input: kvInput{op: 1, key: "x", value: "a"}, Output: kvOutput{}},
+			{Input: kvInput{op: 1, key: "y", value: "b"}, Output: kvOutput{}},
+		},
+		1: {
+			{Input: kvInput{op: 0, key: "y"}, Output: kvOutput{"b"}},
+			{Input: kvInput{op: 0, key: "x"}, Output: kvOutput{"a"}},
+		},
+		2: {
+			{Input: kvInput{op: 0, key: "x"}, Output: kvOutput{""}},
+		},
+	}
+	if !CheckByClient(kvNoPartitionModel, history) {
+		t.Fatal("expected operations to be sequentially consistent")
+	}
+	// client 1 sees the put of y but not the earlier put of x, which is
+	// fine for each key on its own, so this relies on not partitioning
+	history[1][1].Output = kvOutput{""}
+	if CheckByClient(kvNoPartitionModel, history) {
+		t.Fatal("expected operations not to be sequentially consistent")
+	}
+}
+
 type etcdInput struct {
 	op   uint8 // 0 => read, 1 => write, 2 => cas
 	arg1 int   // used for write, or for CAS from argument
@@ -191,14 +1364,65 @@ var etcdModel = Model{
 	},
 }
 
-func parseJepsenLog(filename string) []Event {
+// maxLogLineLength is the maximum length of a line in a log, which is much
+// longer than any line in the test data
+const maxLogLineLength = 1 << 20
+
+func TestEquivalent(t *testing.T) {
+	a := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 5, 0, 15},
+	}
+	// the read is concurrent with the write in both histories, so either
+	// result is fine
+	b := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 5, 1, 15},
+	}
+	if !Equivalent(registerModel, a, b) {
+		t.Fatal("expected histories to be equivalent")
+	}
+	// the read follows the write, so a's result would be illegal
+	c := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+	}
+	if Equivalent(registerModel, a, c) || Equivalent(registerModel, c, a) {
+		t.Fatal("expected histories not to be equivalent")
+	}
+	defer func() {
+		expected := "porcupine: operation 1 differs between histories"
+		if r := recover(); r != expected {
+			t.Fatalf("expected panic %q, got %v", expected, r)
+		}
+	}()
+	d := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 20, 0, 30},
+	}
+	Equivalent(registerModel, a, d)
+	t.Fatal("expected Equivalent to panic")
+}
+
+func parseJepsenLog(t testing.TB, filename string) []Event {
+	t.Helper()
 	file, err := os.Open(filename)
 	if err != nil {
-		panic("can't open file")
+		t.Fatal(err)
 	}
 	defer file.Close()
+	events, err := readJepsenLog(file)
+	if err != nil {
+		t.Fatalf("%s: %v", filename, err)
+	}
+	return events
+}
 
-	reader := bufio.NewReader(file)
+// readJepsenLog parses a log, returning an error that gives the line number and content
+// of the first line that can't be parsed
+func readJepsenLog(r io.Reader) ([]Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLogLineLength)
 
 	invokeRead, _ := regexp.Compile(`^INFO\s+jepsen\.util\s+-\s+(\d+)\s+:invoke\s+:read\s+nil$`)
 	invokeWrite, _ := regexp.Compile(`^INFO\s+jepsen\.util\s+-\s+(\d+)\s+:invoke\s+:write\s+(\d+)$`)
@@ -212,17 +1436,20 @@ func parseJepsenLog(filename string) []Event {
 
 	id := 0
 	procIdMap := make(map[int]int)
-	for {
-		lineBytes, isPrefix, err := reader.ReadLine()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			panic("error while reading file: " + err.Error())
+	lineNum := 0
+	var line string
+	// returnId finds the id of the pending call of a process that returns
+	returnId := func(proc int) (int, error) {
+		matchId, ok := procIdMap[proc]
+		if !ok {
+			return 0, fmt.Errorf("line %d: return for process %d with no pending call: %s", lineNum, proc, line)
 		}
-		if isPrefix {
-			panic("can't handle isPrefix")
-		}
-		line := string(lineBytes)
+		delete(procIdMap, proc)
+		return matchId, nil
+	}
+	for scanner.Scan() {
+		lineNum++
+		line = scanner.Text()
 
 		switch {
 		case invokeRead.MatchString(line):
@@ -255,42 +1482,53 @@ func parseJepsenLog(filename string) []Event {
 				exists = true
 				value, _ = strconv.Atoi(args[2])
 			}
-			matchId := procIdMap[proc]
-			delete(procIdMap, proc)
+			matchId, err := returnId(proc)
+			if err != nil {
+				return nil, err
+			}
 			events = append(events, Event{proc, ReturnEvent, etcdOutput{exists: exists, value: value}, matchId})
 		case returnWrite.MatchString(line):
 			args := returnWrite.FindStringSubmatch(line)
 			proc, _ := strconv.Atoi(args[1])
-			matchId := procIdMap[proc]
-			delete(procIdMap, proc)
+			matchId, err := returnId(proc)
+			if err != nil {
+				return nil, err
+			}
 			events = append(events, Event{proc, ReturnEvent, etcdOutput{}, matchId})
 		case returnCas.MatchString(line):
 			args := returnCas.FindStringSubmatch(line)
 			proc, _ := strconv.Atoi(args[1])
-			matchId := procIdMap[proc]
-			delete(procIdMap, proc)
+			matchId, err := returnId(proc)
+			if err != nil {
+				return nil, err
+			}
 			events = append(events, Event{proc, ReturnEvent, etcdOutput{ok: args[2] == "ok"}, matchId})
 		case timeoutRead.MatchString(line):
 			// timing out a read and then continuing operations is fine
 			// we could just delete the read from the events, but we do this the lazy way
 			args := timeoutRead.FindStringSubmatch(line)
 			proc, _ := strconv.Atoi(args[1])
-			matchId := procIdMap[proc]
-			delete(procIdMap, proc)
+			matchId, err := returnId(proc)
+			if err != nil {
+				return nil, err
+			}
 			// okay to put the return here in the history
 			events = append(events, Event{proc, ReturnEvent, etcdOutput{unknown: true}, matchId})
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %v", lineNum+1, err)
+	}
 
 	for proc, matchId := range procIdMap {
 		events = append(events, Event{proc, ReturnEvent, etcdOutput{unknown: true}, matchId})
 	}
 
-	return events
+	return events, nil
 }
 
 func checkJepsen(t *testing.T, logNum int, correct bool) {
-	events := parseJepsenLog(fmt.Sprintf("test_data/jepsen/etcd_%03d.log", logNum))
+	events := parseJepsenLog(t, fmt.Sprintf("test_data/jepsen/etcd_%03d.log", logNum))
 	res := CheckEvents(etcdModel, events)
 	if res != correct {
 		t.Fatalf("expected output %t, got output %t", correct, res)
@@ -708,7 +1946,7 @@ func TestEtcdJepsen102(t *testing.T) {
 }
 
 func benchJepsen(b *testing.B, logNum int, correct bool) {
-	events := parseJepsenLog(fmt.Sprintf("test_data/jepsen/etcd_%03d.log", logNum))
+	events := parseJepsenLog(b, fmt.Sprintf("test_data/jepsen/etcd_%03d.log", logNum))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		res := CheckEvents(etcdModel, events)
@@ -718,6 +1956,78 @@ func benchJepsen(b *testing.B, logNum int, correct bool) {
 	}
 }
 
+// converts a history of events to operations, using the index of each event
+// as its timestamp, so the operations are sorted by call time
+func eventsToOperations(events []Event) []Operation {
+	var ops []Operation
+	index := make(map[int]int) // id -> index in ops
+	for i, e := range events {
+		if e.Kind == CallEvent {
+			index[e.Id] = len(ops)
+			ops = append(ops, Operation{ClientId: e.ClientId, Input: e.Value, Call: int64(i)})
+		} else {
+			ops[index[e.Id]].Output = e.Value
+			ops[index[e.Id]].Return = int64(i)
+		}
+	}
+	return ops
+}
+
+func TestMakeEntriesSorted(t *testing.T) {
+	events := parseJepsenLog(t, "test_data/jepsen/etcd_029.log")
+	ops := eventsToOperations(events)
+	entries := makeEntries(ops, 0)
+	if len(entries) != 2*len(ops) || !sort.IsSorted(byTime(entries)) {
+		t.Fatal("expected entries to be sorted")
+	}
+	// reversing the history must not change the order of entries, except
+	// for renumbering
+	reversed := make([]Operation, len(ops))
+	for i, op := range ops {
+		reversed[len(ops)-1-i] = op
+	}
+	for i, e := range makeEntries(reversed, 0) {
+		if e.time != entries[i].time || e.kind != entries[i].kind || e.id != len(ops)-1-entries[i].id {
+			t.Fatalf("entry %d differs", i)
+		}
+	}
+}
+
+func BenchmarkMakeEntriesJepsen029(b *testing.B) {
+	ops := eventsToOperations(parseJepsenLog(b, "test_data/jepsen/etcd_029.log"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		makeEntries(ops, 0)
+	}
+}
+
+func BenchmarkMakeEntriesJepsen029Unsorted(b *testing.B) {
+	ops := eventsToOperations(parseJepsenLog(b, "test_data/jepsen/etcd_029.log"))
+	rand.New(rand.NewSource(0)).Shuffle(len(ops), func(i, j int) {
+		ops[i], ops[j] = ops[j], ops[i]
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		makeEntries(ops, 0)
+	}
+}
+
+func BenchmarkMakeLinkedEntriesJepsen029(b *testing.B) {
+	entries := makeEntries(eventsToOperations(parseJepsenLog(b, "test_data/jepsen/etcd_029.log")), 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		makeLinkedEntries(entries)
+	}
+}
+
+func BenchmarkMakeLinkedEntriesKvC50(b *testing.B) {
+	entries := convertEntries(renumber(parseKvLog(b, "test_data/kv/c50-ok.txt")))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		makeLinkedEntries(entries)
+	}
+}
+
 func BenchmarkEtcdJepsen000(b *testing.B) {
 	benchJepsen(b, 0, false)
 }
@@ -1251,14 +2561,25 @@ func cloneMap(m map[string]string) map[string]string {
 	return m2
 }
 
-func parseKvLog(filename string) []Event {
+func parseKvLog(t testing.TB, filename string) []Event {
+	t.Helper()
 	file, err := os.Open(filename)
 	if err != nil {
-		panic("can't open file")
+		t.Fatal(err)
 	}
 	defer file.Close()
+	events, err := readKvLog(file)
+	if err != nil {
+		t.Fatalf("%s: %v", filename, err)
+	}
+	return events
+}
 
-	reader := bufio.NewReader(file)
+// readKvLog parses a log, returning an error that gives the line number and content
+// of the first line that can't be parsed
+func readKvLog(r io.Reader) ([]Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLogLineLength)
 
 	invokeGet, _ := regexp.Compile(`{:process (\d+), :type :invoke, :f :get, :key "(.*)", :value nil}`)
 	invokePut, _ := regexp.Compile(`{:process (\d+), :type :invoke, :f :put, :key "(.*)", :value "(.*)"}`)
@@ -1271,17 +2592,20 @@ func parseKvLog(filename string) []Event {
 
 	id := 0
 	procIdMap := make(map[int]int)
-	for {
-		lineBytes, isPrefix, err := reader.ReadLine()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			panic("error while reading file: " + err.Error())
-		}
-		if isPrefix {
-			panic("can't handle isPrefix")
+	lineNum := 0
+	var line string
+	// returnId finds the id of the pending call of a process that returns
+	returnId := func(proc int) (int, error) {
+		matchId, ok := procIdMap[proc]
+		if !ok {
+			return 0, fmt.Errorf("line %d: return for process %d with no pending call: %s", lineNum, proc, line)
 		}
-		line := string(lineBytes)
+		delete(procIdMap, proc)
+		return matchId, nil
+	}
+	for scanner.Scan() {
+		lineNum++
+		line = scanner.Text()
 
 		switch {
 		case invokeGet.MatchString(line):
@@ -1305,33 +2629,65 @@ func parseKvLog(filename string) []Event {
 		case returnGet.MatchString(line):
 			args := returnGet.FindStringSubmatch(line)
 			proc, _ := strconv.Atoi(args[1])
-			matchId := procIdMap[proc]
-			delete(procIdMap, proc)
+			matchId, err := returnId(proc)
+			if err != nil {
+				return nil, err
+			}
 			events = append(events, Event{proc, ReturnEvent, kvOutput{args[2]}, matchId})
 		case returnPut.MatchString(line):
 			args := returnPut.FindStringSubmatch(line)
 			proc, _ := strconv.Atoi(args[1])
-			matchId := procIdMap[proc]
-			delete(procIdMap, proc)
+			matchId, err := returnId(proc)
+			if err != nil {
+				return nil, err
+			}
 			events = append(events, Event{proc, ReturnEvent, kvOutput{}, matchId})
 		case returnAppend.MatchString(line):
 			args := returnAppend.FindStringSubmatch(line)
 			proc, _ := strconv.Atoi(args[1])
-			matchId := procIdMap[proc]
-			delete(procIdMap, proc)
+			matchId, err := returnId(proc)
+			if err != nil {
+				return nil, err
+			}
 			events = append(events, Event{proc, ReturnEvent, kvOutput{}, matchId})
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %v", lineNum+1, err)
+	}
 
 	for proc, matchId := range procIdMap {
 		events = append(events, Event{proc, ReturnEvent, kvOutput{}, matchId})
 	}
 
-	return events
+	return events, nil
+}
+
+func TestReadLogErrors(t *testing.T) {
+	valid := `{:process 0, :type :invoke, :f :get, :key "x", :value nil}
+{:process 0, :type :ok, :f :get, :key "x", :value "y"}
+`
+	events, err := readKvLog(strings.NewReader(valid))
+	if err != nil || len(events) != 2 {
+		t.Fatalf("expected 2 events, got %v, %v", events, err)
+	}
+	// a return without a call
+	log := valid + `{:process 1, :type :ok, :f :put, :key "x", :value "z"}
+`
+	_, err = readKvLog(strings.NewReader(log))
+	if err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), ":process 1") {
+		t.Fatalf("expected an error for line 3, got %v", err)
+	}
+	// a line that is too long
+	long := "INFO  jepsen.util - 0  :invoke :read  nil\n" + strings.Repeat("x", maxLogLineLength+1) + "\n"
+	_, err = readJepsenLog(strings.NewReader(long))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an error for line 2, got %v", err)
+	}
 }
 
 func checkKv(t *testing.T, logName string, correct bool, partition bool) {
-	events := parseKvLog(fmt.Sprintf("test_data/kv/%s.txt", logName))
+	events := parseKvLog(t, fmt.Sprintf("test_data/kv/%s.txt", logName))
 	var model Model
 	if partition {
 		model = kvModel
@@ -1392,8 +2748,75 @@ func TestKvNoPartition10ClientsBad(t *testing.T) {
 	checkKv(t, "c10-bad", false, false)
 }
 
+func hashKvState(state interface{}) uint64 {
+	st := state.(map[string]string)
+	keys := make([]string, 0, len(st))
+	for k := range st {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := fnv.New64a()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%q=%q;", k, st[k])
+	}
+	return h.Sum64()
+}
+
+func TestHashState(t *testing.T) {
+	// rounds of concurrent appends to a few keys, each followed by reads
+	// of every key; the appends take effect in the reverse of the order
+	// the checker tries first, so it has to backtrack, reaching the same
+	// set of linearized operations with different states
+	var ops []Operation
+	values := make(map[string]string)
+	for r := 0; r < 4; r++ {
+		start := int64(100 * r)
+		for c := 0; c < 6; c++ {
+			key := strconv.Itoa(c % 3)
+			value := fmt.Sprintf("(%d,%d)", r, c)
+			ops = append(ops, Operation{c, kvInput{op: 2, key: key, value: value}, start, kvOutput{}, start + 50})
+		}
+		for c := 5; c >= 0; c-- {
+			key := strconv.Itoa(c % 3)
+			values[key] += fmt.Sprintf("(%d,%d)", r, c)
+		}
+		for k := 0; k < 3; k++ {
+			key := strconv.Itoa(k)
+			ops = append(ops, Operation{6, kvInput{op: 0, key: key}, start + 60 + int64(k), kvOutput{values[key]}, start + 61 + int64(k)})
+		}
+	}
+	countEqual := func(model Model) (bool, int) {
+		calls := 0
+		equal := model.Equal
+		model.Equal = func(state1, state2 interface{}) bool {
+			calls++
+			return equal(state1, state2)
+		}
+		return CheckOperations(model, ops), calls
+	}
+	ok, calls := countEqual(kvNoPartitionModel)
+	if !ok {
+		t.Fatal("expected operations to be linearizable")
+	}
+	model := kvNoPartitionModel
+	model.HashState = hashKvState
+	okHashed, callsHashed := countEqual(model)
+	if !okHashed {
+		t.Fatal("expected operations to be linearizable")
+	}
+	t.Logf("equality checks: %d without state hash, %d with state hash", calls, callsHashed)
+	if callsHashed >= calls {
+		t.Fatalf("expected state hash to reduce equality checks")
+	}
+
+	ops[len(ops)-1].Output = kvOutput{"x"}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
+
 func benchKv(b *testing.B, logName string, correct bool, partition bool) {
-	events := parseKvLog(fmt.Sprintf("test_data/kv/%s.txt", logName))
+	events := parseKvLog(b, fmt.Sprintf("test_data/kv/%s.txt", logName))
 	var model Model
 	if partition {
 		model = kvModel
@@ -1457,6 +2880,79 @@ func BenchmarkKvNoPartition10ClientsBad(b *testing.B) {
 	benchKv(b, "c10-bad", false, false)
 }
 
+func TestMemoryLimit(t *testing.T) {
+	for _, test := range []struct {
+		logName string
+		correct bool
+	}{{"c10-ok", true}, {"c10-bad", false}} {
+		events := parseKvLog(t, fmt.Sprintf("test_data/kv/%s.txt", test.logName))
+		res, _ := CheckEventsOptions(kvModel, events, CheckOptions{MemoryLimit: 4096})
+		if (res == Ok) != test.correct {
+			t.Fatalf("%s: expected output %t, got output %v", test.logName, test.correct, res)
+		}
+	}
+}
+
+func TestCheckMany(t *testing.T) {
+	logs := []struct {
+		logName string
+		correct bool
+	}{{"c01-ok", true}, {"c01-bad", false}, {"c10-ok", true}, {"c10-bad", false}, {"c50-ok", true}, {"c50-bad", false}}
+	histories := make([][]Event, len(logs))
+	for i, log := range logs {
+		histories[i] = parseKvLog(t, fmt.Sprintf("test_data/kv/%s.txt", log.logName))
+	}
+	results := CheckMany(kvModel, histories, 0)
+	if len(results) != len(logs) {
+		t.Fatalf("expected %d results, got %d", len(logs), len(results))
+	}
+	for i, log := range logs {
+		if (results[i] == Ok) != log.correct {
+			t.Fatalf("%s: expected output %t, got output %v", log.logName, log.correct, results[i])
+		}
+	}
+	if results := CheckMany(kvModel, nil, 0); len(results) != 0 {
+		t.Fatalf("expected no results, got %v", results)
+	}
+}
+
+func TestHints(t *testing.T) {
+	events := parseKvLog(t, "test_data/kv/c01-ok.txt")
+	res, info := CheckEventsVerbose(kvNoPartitionModel, events, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	// with a complete linearization as a hint, there's nothing to search
+	hints := info.PartialLinearizations()[0]
+	res, info = CheckEventsOptions(kvNoPartitionModel, events, CheckOptions{Verbose: true, Hints: hints})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if states := info.PartitionResults()[0].States; states != 0 {
+		t.Fatalf("expected no states to be explored, got %d", states)
+	}
+
+	// bad hints shouldn't affect the result
+	for _, test := range []struct {
+		logName string
+		correct bool
+	}{{"c10-ok", true}, {"c10-bad", false}} {
+		events := parseKvLog(t, fmt.Sprintf("test_data/kv/%s.txt", test.logName))
+		var hints [][]int
+		for i := 0; i < 10; i++ {
+			var hint []int
+			for id := len(events); id >= -1; id-- {
+				hint = append(hint, id)
+			}
+			hints = append(hints, hint, []int{0, 2, 1, 3})
+		}
+		res, _ := CheckEventsOptions(kvModel, events, CheckOptions{Hints: hints})
+		if (res == Ok) != test.correct {
+			t.Fatalf("%s: expected output %t, got output %v", test.logName, test.correct, res)
+		}
+	}
+}
+
 func TestSetModel(t *testing.T) {
 
 	// Set Model is from Jepsen/Knossos Set.
@@ -1666,6 +3162,18 @@ var nondeterministicRegisterModel = NondeterministicModel{
 	},
 }
 
+func TestReachableStatesNondeterministic(t *testing.T) {
+	operations := []Operation{
+		{Input: nondeterministicRegisterInput{1, []int{1, 2}}, Output: []int{}},
+		{Input: nondeterministicRegisterInput{3, nil}, Output: []int{2}},
+	}
+	states := ReachableStatesNondeterministic(nondeterministicRegisterModel, operations, 2)
+	// {}, {2}, {1}, {1, 2}
+	if len(states) != 4 {
+		t.Fatalf("unexpected reachable states %v", states)
+	}
+}
+
 func TestNondeterministicRegisterModel(t *testing.T) {
 	events := []Event{
 		// C0: PutAny({1, 2, 3, 4})
@@ -1695,3 +3203,134 @@ func TestNondeterministicRegisterModel(t *testing.T) {
 
 	visualizeTempFile(t, model, info)
 }
+
+func TestInitPartition(t *testing.T) {
+	// each key starts out as either "a" or "b"
+	step := func(state, input, output interface{}) []interface{} {
+		st := state.(map[string]string)
+		inp := input.(kvInput)
+		out := output.(kvOutput)
+		if inp.op == 0 {
+			if st[inp.key] != out.value {
+				return nil
+			}
+			return []interface{}{st}
+		}
+		next := make(map[string]string, len(st))
+		for k, v := range st {
+			next[k] = v
+		}
+		next[inp.key] = inp.value
+		return []interface{}{next}
+	}
+	equal := func(state1, state2 interface{}) bool {
+		return reflect.DeepEqual(state1, state2)
+	}
+	partitionByKey := func(history []Operation) map[string][]Operation {
+		m := make(map[string][]Operation)
+		for _, v := range history {
+			key := v.Input.(kvInput).key
+			m[key] = append(m[key], v)
+		}
+		return m
+	}
+	initPartition := func(label string) []interface{} {
+		return []interface{}{
+			map[string]string{label: "a"},
+			map[string]string{label: "b"},
+		}
+	}
+	keysModel := func(keys []string, usePartition bool) Model {
+		nm := NondeterministicModel{
+			PartitionLabeled: partitionByKey,
+			Init: func() []interface{} {
+				states := []interface{}{map[string]string{}}
+				for _, key := range keys {
+					var next []interface{}
+					for _, state := range states {
+						for _, v := range []string{"a", "b"} {
+							m := make(map[string]string)
+							for k, v := range state.(map[string]string) {
+								m[k] = v
+							}
+							m[key] = v
+							next = append(next, m)
+						}
+					}
+					states = next
+				}
+				return states
+			},
+			Step:  step,
+			Equal: equal,
+		}
+		if usePartition {
+			nm.InitPartition = initPartition
+		}
+		return nm.ToModel()
+	}
+	get := func(key, value string, call, ret int64) Operation {
+		return Operation{Input: kvInput{op: 0, key: key}, Call: call, Output: kvOutput{value}, Return: ret}
+	}
+	put := func(key, value string, call, ret int64) Operation {
+		return Operation{Input: kvInput{op: 1, key: key, value: value}, Call: call, Output: kvOutput{}, Return: ret}
+	}
+
+	ok := []Operation{
+		get("x", "a", 0, 10),
+		get("y", "b", 5, 15),
+		get("x", "a", 20, 30),
+		put("y", "c", 20, 30),
+		get("y", "c", 40, 50),
+	}
+	bad := []Operation{
+		get("x", "a", 0, 10),
+		get("y", "b", 5, 15),
+		get("x", "b", 20, 30),
+	}
+	keys := []string{"x", "y"}
+	for _, usePartition := range []bool{false, true} {
+		model := keysModel(keys, usePartition)
+		res, info := CheckOperationsVerbose(model, ok, 0)
+		if res != Ok {
+			t.Fatalf("expected output %v, got output %v (InitPartition: %v)", Ok, res, usePartition)
+		}
+		if data := computeVisualizationData(model, info, VisualizeOptions{}); len(data.Partitions) != 2 {
+			t.Fatalf("expected 2 partitions, got %d", len(data.Partitions))
+		}
+		if CheckOperations(model, bad) {
+			t.Fatalf("expected operations to not be linearizable (InitPartition: %v)", usePartition)
+		}
+	}
+
+	// with many keys, the product of the initial states is too large to
+	// enumerate, so rely on InitPartition alone
+	var ops []Operation
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("k%d", i)
+		value := "a"
+		if i%2 == 1 {
+			value = "b"
+		}
+		ops = append(ops, get(key, value, int64(4*i), int64(4*i+2)), get(key, value, int64(4*i+1), int64(4*i+3)))
+	}
+	nm := &NondeterministicModel{
+		PartitionLabeled: partitionByKey,
+		Init: func() []interface{} {
+			return []interface{}{map[string]string{}}
+		},
+		InitPartition: initPartition,
+		Step:          step,
+		Equal:         equal,
+	}
+	model := nm.ToModel()
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+	ops = append(ops, get("k0", "b", 100, 101))
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations to not be linearizable")
+	}
+}
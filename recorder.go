@@ -0,0 +1,78 @@
+package porcupine
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// A Recorder records a history of [Operation] as a concurrent system is
+// exercised, e.g., by the goroutines of a stress test, with timestamps that
+// are guaranteed to be consistent with the real-time order of operations.
+//
+// Timestamps from [time.Now] can be inconsistent with the order in which
+// operations happen, e.g., because the reads of the clock can be reordered
+// with the operation on weakly-ordered architectures such as ARM, or
+// because clocks of different cores can be skewed, which can make the
+// checker report spurious violations. Instead, a Recorder uses a logical
+// clock: an atomic counter that is incremented right before each operation
+// is called and right after it returns. Because atomic operations are
+// sequentially consistent in Go, the order of the timestamps is consistent
+// with the happens-before order of operations.
+//
+// A Recorder is safe for concurrent use by multiple goroutines.
+type Recorder struct {
+	clock   int64
+	mu      sync.Mutex
+	history []Operation
+}
+
+// NewRecorder returns a [Recorder] with an empty history.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record calls f, which performs an operation with the given input on the
+// system under test, on behalf of the given client, and records the
+// operation, with the output returned by f. It returns that output.
+//
+// Each client should perform one operation at a time, e.g., each client
+// can be a goroutine of a stress test, identified by its worker id.
+func (r *Recorder) Record(clientId int, input interface{}, f func() interface{}) interface{} {
+	call := atomic.AddInt64(&r.clock, 1)
+	output := f()
+	ret := atomic.AddInt64(&r.clock, 1)
+	r.mu.Lock()
+	r.history = append(r.history, Operation{clientId, input, call, output, ret})
+	r.mu.Unlock()
+	return output
+}
+
+// Wrap returns a function that calls f, an adapter that performs an
+// operation with the given input on the system under test, and records the
+// operation on behalf of the given client, like [Recorder.Record].
+//
+// This makes it easy to instrument a system: write an adapter for each of
+// its methods that converts an input to a method call and its result to an
+// output, and give each client its own wrapped adapters.
+func (r *Recorder) Wrap(clientId int, f func(input interface{}) interface{}) func(input interface{}) interface{} {
+	return func(input interface{}) interface{} {
+		return r.Record(clientId, input, func() interface{} {
+			return f(input)
+		})
+	}
+}
+
+// History returns the operations that have been recorded so far, in order
+// of their Call times. Operations that are still in progress are not
+// included.
+func (r *Recorder) History() []Operation {
+	r.mu.Lock()
+	history := make([]Operation, len(r.history))
+	copy(history, r.history)
+	r.mu.Unlock()
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Call < history[j].Call
+	})
+	return history
+}
@@ -0,0 +1,55 @@
+package porcupine
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	var mu sync.Mutex
+	register := 0
+	access := func(input interface{}) interface{} {
+		inp := input.(registerInput)
+		mu.Lock()
+		defer mu.Unlock()
+		if inp.op {
+			return register
+		}
+		register = inp.value
+		return 0
+	}
+	recorder := NewRecorder()
+	var wg sync.WaitGroup
+	for client := 0; client < 4; client++ {
+		wg.Add(1)
+		go func(client int) {
+			defer wg.Done()
+			do := recorder.Wrap(client, access)
+			for i := 0; i < 20; i++ {
+				if i%2 == 0 {
+					do(registerInput{false, client*100 + i})
+				} else {
+					do(registerInput{true, 0})
+				}
+			}
+		}(client)
+	}
+	wg.Wait()
+	history := recorder.History()
+	if len(history) != 80 {
+		t.Fatalf("expected 80 operations, got %d", len(history))
+	}
+	for i, op := range history {
+		if op.Call >= op.Return || (i > 0 && history[i-1].Call >= op.Call) {
+			t.Fatalf("unexpected timestamps for operation %d: %v", i, op)
+		}
+	}
+	if !CheckOperations(registerModel, history) {
+		t.Fatal("expected history to be linearizable")
+	}
+	// a read of a value that was never written is detected
+	recorder.Record(0, registerInput{true, 0}, func() interface{} { return -1 })
+	if CheckOperations(registerModel, recorder.History()) {
+		t.Fatal("expected history not to be linearizable")
+	}
+}
@@ -0,0 +1,126 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ExplainRegisterConflicts explains why a history of a single register is
+// not linearizable, in the common case where a read observed a value that
+// the real-time order of operations rules out, by pinpointing the read and
+// the write that conflict with it. It returns one sentence for each such
+// read, in the order of the history, e.g., "read 3 (client 1) observed 2,
+// written by operation 1 (client 0), but operation 2 (client 2) overwrote
+// it with 3 after that write returned (at 10) and before the read was called
+// (at 20)". Operations are identified by their index in the history.
+//
+// The history must consist only of reads and writes of the register (e.g., a
+// single partition of a key-value store's history), and the access function
+// gives the value that an operation read or wrote, given its input and
+// output, and whether it is a write. Values are compared using
+// [reflect.DeepEqual], and initial is the value of the register before any
+// write. Reads with a [PendingOutput] are ignored, and writes with one never
+// overwrite a value, because they may not have taken effect.
+//
+// Every conflict that is reported shows that the history is not
+// linearizable, but the converse does not hold: some violations involve
+// more than a single read and write, and these are not explained. This
+// function doesn't need a model and doesn't search, so it is fast.
+func ExplainRegisterConflicts(history []Operation, initial interface{}, access func(input, output interface{}) (value interface{}, write bool)) []string {
+	type write struct {
+		id    int // -1 for the initial value
+		value interface{}
+	}
+	var writes []write
+	for id, op := range history {
+		if value, isWrite := access(op.Input, op.Output); isWrite {
+			writes = append(writes, write{id, value})
+		}
+	}
+	describe := func(id int) string {
+		return fmt.Sprintf("operation %d (client %d)", id, history[id].ClientId)
+	}
+	// overwriter returns a write of a different value that happened
+	// entirely between the write with the given id and the read, or -1 if
+	// there is none
+	overwriter := func(w write, read Operation) int {
+		for _, other := range writes {
+			op := history[other.id]
+			if _, pending := op.Output.(PendingOutput); pending || reflect.DeepEqual(other.value, w.value) {
+				continue
+			}
+			if (w.id == -1 || history[w.id].Return < op.Call) && op.Return < read.Call {
+				return other.id
+			}
+		}
+		return -1
+	}
+	var explanations []string
+	for id, read := range history {
+		if _, pending := read.Output.(PendingOutput); pending {
+			continue
+		}
+		value, isWrite := access(read.Input, read.Output)
+		if isWrite {
+			continue
+		}
+		var candidates []write
+		if reflect.DeepEqual(value, initial) {
+			candidates = append(candidates, write{-1, initial})
+		}
+		for _, w := range writes {
+			if reflect.DeepEqual(w.value, value) {
+				candidates = append(candidates, w)
+			}
+		}
+		if len(candidates) == 0 {
+			explanations = append(explanations, fmt.Sprintf("read %d (client %d) observed %v, which was never written", id, read.ClientId, value))
+			continue
+		}
+		// writes that were called before the read returned, and the
+		// earliest write that wasn't
+		var viable []write
+		earliest := -1
+		for _, w := range candidates {
+			if w.id == -1 || history[w.id].Call <= read.Return {
+				viable = append(viable, w)
+			} else if earliest == -1 || history[w.id].Call < history[earliest].Call {
+				earliest = w.id
+			}
+		}
+		if len(viable) == 0 {
+			only := "the only write"
+			if len(candidates) > 1 {
+				only = "the earliest write"
+			}
+			explanations = append(explanations, fmt.Sprintf("read %d (client %d) observed %v, but %s of %v, %s, was called at %d, after the read returned (at %d)", id, read.ClientId, value, only, value, describe(earliest), history[earliest].Call, read.Return))
+			continue
+		}
+		// the read conflicts if every viable write was overwritten before
+		// the read was called; we explain it using the latest one
+		conflict := true
+		var latest write
+		latestBy := -1
+		for _, w := range viable {
+			by := overwriter(w, read)
+			if by == -1 {
+				conflict = false
+				break
+			}
+			if latestBy == -1 || (w.id != -1 && (latest.id == -1 || history[w.id].Return > history[latest.id].Return)) {
+				latest, latestBy = w, by
+			}
+		}
+		if !conflict {
+			continue
+		}
+		overwrite := history[latestBy]
+		newValue, _ := access(overwrite.Input, overwrite.Output)
+		if latest.id == -1 {
+			explanations = append(explanations, fmt.Sprintf("read %d (client %d) observed the initial value %v, but %s overwrote it with %v before the read was called (at %d)", id, read.ClientId, value, describe(latestBy), newValue, read.Call))
+		} else {
+			explanations = append(explanations, fmt.Sprintf("read %d (client %d) observed %v, written by %s, but %s overwrote it with %v after that write returned (at %d) and before the read was called (at %d)", id, read.ClientId, value, describe(latest.id), describe(latestBy), newValue, history[latest.id].Return, read.Call))
+		}
+	}
+	return explanations
+}
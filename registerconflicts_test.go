@@ -0,0 +1,47 @@
+package porcupine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func registerAccess(input, output interface{}) (interface{}, bool) {
+	inp := input.(registerInput)
+	if inp.op {
+		return output, false
+	}
+	return inp.value, true
+}
+
+func TestExplainRegisterConflicts(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 15, 0, 20},
+		// stale read
+		{2, registerInput{true, 0}, 25, 1, 30},
+		// read from the future
+		{2, registerInput{true, 0}, 35, 3, 40},
+		{0, registerInput{false, 3}, 45, 0, 50},
+		// a value that was never written
+		{1, registerInput{true, 0}, 55, 4, 60},
+		// concurrent with a write, so fine
+		{2, registerInput{true, 0}, 46, 2, 48},
+		// stale read of the initial value
+		{1, registerInput{true, 0}, 65, 0, 70},
+		// pending reads are ignored
+		{2, registerInput{true, 0}, 65, PendingOutput{}, 70},
+	}
+	expected := []string{
+		"read 2 (client 2) observed 1, written by operation 0 (client 0), but operation 1 (client 1) overwrote it with 2 after that write returned (at 10) and before the read was called (at 25)",
+		"read 3 (client 2) observed 3, but the only write of 3, operation 4 (client 0), was called at 45, after the read returned (at 40)",
+		"read 5 (client 1) observed 4, which was never written",
+		"read 7 (client 1) observed the initial value 0, but operation 0 (client 0) overwrote it with 1 before the read was called (at 65)",
+	}
+	explanations := ExplainRegisterConflicts(ops, 0, registerAccess)
+	if !reflect.DeepEqual(expected, explanations) {
+		t.Fatalf("expected %q, got %q", expected, explanations)
+	}
+	if explanations := ExplainRegisterConflicts(ops[:2], 0, registerAccess); len(explanations) != 0 {
+		t.Fatalf("expected no explanations for a linearizable history, got %q", explanations)
+	}
+}
@@ -0,0 +1,89 @@
+package porcupine
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type resultJSON struct {
+	Result     CheckResult
+	Operations int
+	States     int
+	Partitions []partitionResultJSON
+}
+
+type partitionResultJSON struct {
+	Label        string `json:",omitempty"`
+	Linearizable bool
+	Operations   int
+	States       int
+	TimedOut     bool `json:",omitempty"`
+	Stopped      bool `json:",omitempty"`
+	// whether the partition exceeded CheckOptions.MaxPending
+	MaxPendingExceeded bool `json:",omitempty"`
+	// the error that stopped the check of the partition, if any
+	Error string `json:",omitempty"`
+	// ids of the operations that are not part of the longest partial
+	// linearization, only set if the partition is not linearizable
+	FailingOperations []int `json:",omitempty"`
+}
+
+// WriteResultJSON writes a machine-readable summary of a linearizability
+// check to w, as JSON, for consumption by tools such as CI dashboards.
+//
+// The summary includes the result, the total number of operations and
+// explored states, and for each partition, its label (if any), whether it is
+// linearizable, its number of operations and explored states, the error that
+// stopped its check (if any), and, if it is not linearizable, the ids of the
+// operations that are not part of the longest partial linearization.
+// Operation ids are the same as those in
+// [LinearizationInfo.PartialLinearizations]. The info must come from a
+// verbose check, such as [CheckOperationsVerbose].
+func WriteResultJSON(w io.Writer, result CheckResult, info LinearizationInfo) error {
+	data := resultJSON{
+		Result:     result,
+		Partitions: make([]partitionResultJSON, len(info.history)),
+	}
+	for i, partition := range info.history {
+		var pr PartitionResult
+		if i < len(info.partitions) {
+			pr = info.partitions[i]
+		}
+		p := partitionResultJSON{
+			Label:              pr.Label,
+			Linearizable:       pr.Linearizable,
+			Operations:         len(partition) / 2,
+			States:             pr.States,
+			TimedOut:           pr.TimedOut,
+			Stopped:            pr.Stopped,
+			MaxPendingExceeded: pr.MaxPendingExceeded,
+		}
+		if pr.Err != nil {
+			p.Error = pr.Err.Error()
+		}
+		if !pr.Linearizable {
+			var longest []int
+			for _, l := range info.partialLinearizations[i] {
+				if len(l) > len(longest) {
+					longest = l
+				}
+			}
+			linearized := make(map[int]bool, len(longest))
+			for _, id := range longest {
+				linearized[id] = true
+			}
+			p.FailingOperations = []int{}
+			for _, e := range partition {
+				if e.kind == callEntry && !linearized[e.id] {
+					p.FailingOperations = append(p.FailingOperations, e.id)
+				}
+			}
+		}
+		data.Operations += p.Operations
+		data.States += p.States
+		data.Partitions[i] = p
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
@@ -0,0 +1,46 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWriteResultJSON(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"a"}, 30},
+		{0, kvInput{op: 1, key: "y", value: "b"}, 40, kvOutput{}, 50},
+		{1, kvInput{op: 0, key: "y"}, 60, kvOutput{"c"}, 70},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	var b bytes.Buffer
+	if err := WriteResultJSON(&b, res, info); err != nil {
+		t.Fatalf("failed to write result: %v", err)
+	}
+	var summary struct {
+		Result     CheckResult
+		Operations int
+		Partitions []struct {
+			Linearizable      bool
+			Operations        int
+			FailingOperations []int
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &summary); err != nil {
+		t.Fatalf("failed to parse result: %v", err)
+	}
+	if summary.Result != Illegal || summary.Operations != 4 || len(summary.Partitions) != 2 {
+		t.Fatalf("unexpected summary: %s", b.String())
+	}
+	if p := summary.Partitions[0]; !p.Linearizable || p.Operations != 2 || p.FailingOperations != nil {
+		t.Fatalf("unexpected summary for partition 0: %+v", p)
+	}
+	if p := summary.Partitions[1]; p.Linearizable || !reflect.DeepEqual(p.FailingOperations, []int{1}) {
+		t.Fatalf("unexpected summary for partition 1: %+v", p)
+	}
+}
@@ -0,0 +1,126 @@
+package porcupine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// A ResumableCheck is a linearizability check of a history that can be run
+// for a limited time, saved, and resumed later, e.g., in a later CI run, so
+// that progress on a hard history accumulates rather than being discarded
+// every time a check times out.
+//
+// The history is checked as a single partition: the model's partition
+// functions are not used, so to check a partitioned history, use a
+// ResumableCheck for each partition. Between runs, the check keeps both the
+// position of its search and its cache of explored states, so running it
+// repeatedly does the same work as running it once. Only the position is
+// saved, though, because model states can't be serialized in general; a
+// check that is resumed from saved progress never repeats the part of the
+// search that was already finished, but without the cache, the rest of the
+// search can take much longer than it would have otherwise.
+type ResumableCheck struct {
+	model   Model
+	entries []entry
+	result  CheckResult
+	states  int
+	pos     searchPosition
+}
+
+// resumableCheckJSON is the serialized form of a ResumableCheck.
+type resumableCheckJSON struct {
+	Operations int
+	Result     CheckResult
+	States     int
+	Path       []int `json:",omitempty"`
+	Next       int
+	NextReturn bool `json:",omitempty"`
+}
+
+// NewResumableCheck returns a check of the given history, which hasn't been
+// run yet.
+func NewResumableCheck(model Model, history []Operation) *ResumableCheck {
+	return &ResumableCheck{
+		model:   fillDefault(model),
+		entries: makeEntries(history, 0),
+		result:  Unknown,
+	}
+}
+
+// Run runs the check for at most the given amount of time, continuing from
+// where the check previously stopped, if it was run (or loaded) before. A
+// timeout of 0 is interpreted as an unlimited timeout. It returns Unknown if
+// the check timed out, in which case it can be run again; once the check
+// finishes, running it again immediately returns the result.
+func (rc *ResumableCheck) Run(timeout time.Duration) CheckResult {
+	if rc.result != Unknown {
+		return rc.result
+	}
+	var kill int32
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&kill, 1)
+		})
+		defer timer.Stop()
+	}
+	ok, _, states, _ := checkSingle(rc.model, rc.entries, false, &kill, nil, nil, nil, 0, &rc.pos)
+	rc.states += states
+	switch {
+	case ok:
+		rc.result = Ok
+	case !rc.pos.saved:
+		rc.result = Illegal
+	}
+	return rc.result
+}
+
+// States returns the number of states that the check has explored, over all
+// of its runs, including those before it was saved.
+func (rc *ResumableCheck) States() int {
+	return rc.states
+}
+
+// Save writes the progress of the check to w, as JSON, so that it can be
+// resumed using [ResumableCheck.Load].
+func (rc *ResumableCheck) Save(w io.Writer) error {
+	data := resumableCheckJSON{
+		Operations: len(rc.entries) / 2,
+		Result:     rc.result,
+		States:     rc.states,
+	}
+	if rc.pos.saved {
+		data.Path = rc.pos.path
+		data.Next = rc.pos.next
+		data.NextReturn = rc.pos.nextReturn
+	}
+	return json.NewEncoder(w).Encode(data)
+}
+
+// Load reads the progress of a check, as written by [ResumableCheck.Save],
+// so that running this check continues from there. The progress must have
+// been saved by a check of the same model and history; Load returns an
+// error if the progress is inconsistent with them.
+func (rc *ResumableCheck) Load(r io.Reader) error {
+	var data resumableCheckJSON
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return err
+	}
+	if data.Operations != len(rc.entries)/2 {
+		return fmt.Errorf("saved check has %d operations, but history has %d", data.Operations, len(rc.entries)/2)
+	}
+	pos := searchPosition{}
+	if data.Result == Unknown && (data.Path != nil || data.Next != 0 || data.NextReturn) {
+		pos = searchPosition{saved: true, path: data.Path, next: data.Next, nextReturn: data.NextReturn}
+		head := insertBefore(&node{value: nil, match: nil, id: -1}, makeLinkedEntries(rc.entries))
+		if _, _, _, err := pos.restore(rc.model, head, newBitset(uint(len(rc.entries)/2))); err != nil {
+			return fmt.Errorf("saved check is inconsistent with history: %v", err)
+		}
+	}
+	rc.result = data.Result
+	rc.states = data.States
+	rc.pos = pos
+	return nil
+}
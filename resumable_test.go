@@ -0,0 +1,66 @@
+package porcupine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResumableCheck(t *testing.T) {
+	for _, name := range []string{"etcd_007", "etcd_075", "etcd_099"} {
+		history := eventsToOperations(parseJepsenLog(t, "test_data/jepsen/"+name+".log"))
+		expected := Illegal
+		if CheckOperations(etcdModel, history) {
+			expected = Ok
+		}
+		rc := NewResumableCheck(etcdModel, history)
+		res := rc.Run(time.Millisecond)
+		runs := 1
+		for res == Unknown {
+			res = rc.Run(time.Millisecond)
+			runs++
+		}
+		if res != expected {
+			t.Fatalf("%s: expected %v, got %v", name, expected, res)
+		}
+		if runs == 1 {
+			t.Logf("%s: check finished without being resumed", name)
+		}
+		if rc.Run(time.Millisecond) != expected {
+			t.Fatalf("%s: expected result to be kept", name)
+		}
+	}
+}
+
+func TestResumableCheckSaveLoad(t *testing.T) {
+	history := eventsToOperations(parseJepsenLog(t, "test_data/jepsen/etcd_075.log"))
+	expected := Illegal
+	if CheckOperations(etcdModel, history) {
+		expected = Ok
+	}
+	rc := NewResumableCheck(etcdModel, history)
+	res := rc.Run(time.Millisecond)
+	var saved bytes.Buffer
+	if err := rc.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	data := saved.String()
+	resumed := NewResumableCheck(etcdModel, history)
+	if err := resumed.Load(strings.NewReader(data)); err != nil {
+		t.Fatalf("failed to load check: %v", err)
+	}
+	if res == Unknown {
+		res = resumed.Run(0)
+	}
+	if res != expected {
+		t.Fatalf("expected %v, got %v", expected, res)
+	}
+	if resumed.States() < rc.States() {
+		t.Fatal("expected states to accumulate")
+	}
+
+	if err := NewResumableCheck(etcdModel, history[:len(history)-1]).Load(strings.NewReader(data)); err == nil {
+		t.Fatal("expected error loading check of a different history")
+	}
+}
@@ -0,0 +1,177 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A ScanOp is the kind of an operation on an ordered key-value store.
+type ScanOp int
+
+const (
+	ScanGet    ScanOp = iota // read the value of a key
+	ScanPut                  // write the value of a key
+	ScanDelete               // delete a key
+	ScanRange                // read all key-value pairs in a range of keys
+)
+
+// A ScanInput is the input of an operation for the model returned by
+// [ScanModel].
+type ScanInput struct {
+	Op    ScanOp
+	Key   string      // key to read, write, or delete, for ScanGet, ScanPut, and ScanDelete
+	Value interface{} // value to write, for ScanPut
+	Start string      // first key of the range (inclusive), for ScanRange
+	End   string      // last key of the range (exclusive), for ScanRange; empty means no limit
+}
+
+// A ScanPair is a key-value pair returned by a ScanRange operation.
+type ScanPair struct {
+	Key   string
+	Value interface{}
+}
+
+// A ScanOutput is the output of an operation for the model returned by
+// [ScanModel].
+type ScanOutput struct {
+	Value  interface{} // value that was read, for ScanGet
+	Exists bool        // whether the key exists, for ScanGet
+	Pairs  []ScanPair  // key-value pairs that were read in order of key, for ScanRange
+}
+
+// ScanModel returns a model of an ordered key-value store that supports
+// range queries ("scans").
+//
+// A ScanRange operation returns all of the key-value pairs with keys in a
+// range, in order of key, as a single output, and the model checks the whole
+// output against the state. Because a scan can observe many keys at once,
+// this model can't be partitioned by key, so checking it is much slower than
+// checking a model of a key-value store without scans that is partitioned by
+// key; histories should be kept small, e.g., by using few keys. Values are
+// compared using [reflect.DeepEqual].
+//
+// Inputs must be of type [ScanInput] and outputs must be of type
+// [ScanOutput].
+func ScanModel() Model {
+	return Model{
+		Init: func() interface{} {
+			return map[string]interface{}{}
+		},
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			st := state.(map[string]interface{})
+			inp := input.(ScanInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(ScanOutput)
+			switch inp.Op {
+			case ScanGet:
+				if pending {
+					return true, state
+				}
+				value, ok := st[inp.Key]
+				if !ok {
+					return !out.Exists, state
+				}
+				return out.Exists && reflect.DeepEqual(out.Value, value), state
+			case ScanPut:
+				newSt := make(map[string]interface{}, len(st)+1)
+				for k, v := range st {
+					newSt[k] = v
+				}
+				newSt[inp.Key] = inp.Value
+				return true, newSt
+			case ScanDelete:
+				if _, ok := st[inp.Key]; !ok {
+					return true, state
+				}
+				newSt := make(map[string]interface{}, len(st))
+				for k, v := range st {
+					if k != inp.Key {
+						newSt[k] = v
+					}
+				}
+				return true, newSt
+			case ScanRange:
+				if pending {
+					return true, state
+				}
+				var pairs []ScanPair
+				for _, k := range sortedKeys(st) {
+					if k >= inp.Start && (inp.End == "" || k < inp.End) {
+						pairs = append(pairs, ScanPair{k, st[k]})
+					}
+				}
+				if len(pairs) != len(out.Pairs) {
+					return false, state
+				}
+				for i := range pairs {
+					if pairs[i].Key != out.Pairs[i].Key || !reflect.DeepEqual(pairs[i].Value, out.Pairs[i].Value) {
+						return false, state
+					}
+				}
+				return true, state
+			}
+			return false, state
+		},
+		Equal: func(state1, state2 interface{}) bool {
+			return reflect.DeepEqual(state1, state2)
+		},
+		DescribeOperation: func(input, output interface{}) string {
+			inp := input.(ScanInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(ScanOutput)
+			switch inp.Op {
+			case ScanGet:
+				read := "none"
+				if pending {
+					read = "pending"
+				} else if out.Exists {
+					read = fmt.Sprintf("%v", out.Value)
+				}
+				return fmt.Sprintf("get('%s') -> %s", inp.Key, read)
+			case ScanPut:
+				if pending {
+					return fmt.Sprintf("put('%s', %v) -> pending", inp.Key, inp.Value)
+				}
+				return fmt.Sprintf("put('%s', %v)", inp.Key, inp.Value)
+			case ScanDelete:
+				if pending {
+					return fmt.Sprintf("delete('%s') -> pending", inp.Key)
+				}
+				return fmt.Sprintf("delete('%s')", inp.Key)
+			case ScanRange:
+				if pending {
+					return fmt.Sprintf("scan('%s', '%s') -> pending", inp.Start, inp.End)
+				}
+				return fmt.Sprintf("scan('%s', '%s') -> %s", inp.Start, inp.End, describePairs(out.Pairs))
+			}
+			return "<invalid>"
+		},
+		DescribeState: func(state interface{}) string {
+			st := state.(map[string]interface{})
+			pairs := make([]ScanPair, 0, len(st))
+			for _, k := range sortedKeys(st) {
+				pairs = append(pairs, ScanPair{k, st[k]})
+			}
+			return describePairs(pairs)
+		},
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func describePairs(pairs []ScanPair) string {
+	descriptions := make([]string, len(pairs))
+	for i, p := range pairs {
+		descriptions[i] = fmt.Sprintf("'%s' -> %v", p.Key, p.Value)
+	}
+	return fmt.Sprintf("{%s}", strings.Join(descriptions, ", "))
+}
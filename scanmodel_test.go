@@ -0,0 +1,43 @@
+package porcupine
+
+import "testing"
+
+func TestScanModel(t *testing.T) {
+	model := ScanModel()
+	// the scan is concurrent with the put of "b" and the delete of "c"
+	ops := []Operation{
+		{0, ScanInput{Op: ScanPut, Key: "a", Value: 1}, 0, ScanOutput{}, 10},
+		{0, ScanInput{Op: ScanPut, Key: "c", Value: 3}, 20, ScanOutput{}, 30},
+		{1, ScanInput{Op: ScanPut, Key: "b", Value: 2}, 40, ScanOutput{}, 60},
+		{2, ScanInput{Op: ScanDelete, Key: "c"}, 40, ScanOutput{}, 60},
+		{3, ScanInput{Op: ScanRange, Start: "a", End: "z"}, 45, ScanOutput{Pairs: []ScanPair{{"a", 1}, {"b", 2}, {"c", 3}}}, 55},
+		{3, ScanInput{Op: ScanGet, Key: "c"}, 70, ScanOutput{}, 80},
+		{3, ScanInput{Op: ScanRange, Start: "b"}, 90, ScanOutput{Pairs: []ScanPair{{"b", 2}}}, 100},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// the scan can't observe the delete of "c" without the put of "b",
+	// because the put returns before the delete is called
+	ops = []Operation{
+		{0, ScanInput{Op: ScanPut, Key: "c", Value: 3}, 0, ScanOutput{}, 10},
+		{1, ScanInput{Op: ScanPut, Key: "b", Value: 2}, 20, ScanOutput{}, 30},
+		{2, ScanInput{Op: ScanDelete, Key: "c"}, 40, ScanOutput{}, 50},
+		{3, ScanInput{Op: ScanRange, Start: "a", End: "z"}, 15, ScanOutput{}, 60},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+
+	// the range excludes its end
+	ops = []Operation{
+		{0, ScanInput{Op: ScanPut, Key: "b", Value: 2}, 0, ScanOutput{}, 10},
+		{1, ScanInput{Op: ScanRange, Start: "a", End: "b"}, 20, ScanOutput{Pairs: []ScanPair{{"b", 2}}}, 30},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
@@ -0,0 +1,145 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A SetOp is the kind of an operation on a set.
+type SetOp int
+
+const (
+	SetAdd    SetOp = iota // add an element
+	SetRemove              // remove an element
+	SetRead                // read all elements
+)
+
+// A SetInput is the input of an operation for the model returned by
+// [SetModel].
+type SetInput struct {
+	Op    SetOp
+	Value interface{} // element to add or remove, for SetAdd and SetRemove
+}
+
+// A SetOutput is the output of an operation for the model returned by
+// [SetModel].
+type SetOutput struct {
+	Values  []interface{} // elements that were read, in any order, for SetRead
+	Unknown bool          // whether the read failed, so its result is unknown, for SetRead
+}
+
+// SetModel returns a model of a set that supports adding, removing, and
+// reading elements.
+//
+// Adding an element that is already in the set and removing an element that
+// is not in the set have no effect. A read returns all of the elements in
+// the set, in any order, without duplicates. A read that failed, e.g.,
+// because of a timeout, can set Unknown in its output, in which case it is
+// consistent with any state. Elements must be comparable using ==.
+//
+// Inputs must be of type [SetInput] and outputs must be of type
+// [SetOutput].
+func SetModel() Model {
+	return Model{
+		Init: func() interface{} {
+			return map[interface{}]struct{}{}
+		},
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			st := state.(map[interface{}]struct{})
+			inp := input.(SetInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(SetOutput)
+			switch inp.Op {
+			case SetAdd:
+				if _, ok := st[inp.Value]; ok {
+					return true, state
+				}
+				newSt := make(map[interface{}]struct{}, len(st)+1)
+				for v := range st {
+					newSt[v] = struct{}{}
+				}
+				newSt[inp.Value] = struct{}{}
+				return true, newSt
+			case SetRemove:
+				if _, ok := st[inp.Value]; !ok {
+					return true, state
+				}
+				newSt := make(map[interface{}]struct{}, len(st))
+				for v := range st {
+					if v != inp.Value {
+						newSt[v] = struct{}{}
+					}
+				}
+				return true, newSt
+			case SetRead:
+				if pending || out.Unknown {
+					return true, state
+				}
+				if len(out.Values) != len(st) {
+					return false, state
+				}
+				seen := make(map[interface{}]struct{}, len(out.Values))
+				for _, v := range out.Values {
+					if _, ok := st[v]; !ok {
+						return false, state
+					}
+					if _, ok := seen[v]; ok {
+						return false, state
+					}
+					seen[v] = struct{}{}
+				}
+				return true, state
+			}
+			return false, state
+		},
+		Equal: func(state1, state2 interface{}) bool {
+			return reflect.DeepEqual(state1, state2)
+		},
+		DescribeOperation: func(input, output interface{}) string {
+			inp := input.(SetInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(SetOutput)
+			switch inp.Op {
+			case SetAdd:
+				if pending {
+					return fmt.Sprintf("add(%v) -> pending", inp.Value)
+				}
+				return fmt.Sprintf("add(%v)", inp.Value)
+			case SetRemove:
+				if pending {
+					return fmt.Sprintf("remove(%v) -> pending", inp.Value)
+				}
+				return fmt.Sprintf("remove(%v)", inp.Value)
+			case SetRead:
+				if pending {
+					return "read() -> pending"
+				}
+				if out.Unknown {
+					return "read() -> unknown"
+				}
+				return fmt.Sprintf("read() -> %s", describeSet(out.Values))
+			}
+			return "<invalid>"
+		},
+		DescribeState: func(state interface{}) string {
+			st := state.(map[interface{}]struct{})
+			values := make([]interface{}, 0, len(st))
+			for v := range st {
+				values = append(values, v)
+			}
+			return describeSet(values)
+		},
+	}
+}
+
+// describeSet describes a set of elements, in a deterministic order.
+func describeSet(values []interface{}) string {
+	descriptions := make([]string, len(values))
+	for i, v := range values {
+		descriptions[i] = fmt.Sprintf("%v", v)
+	}
+	sort.Strings(descriptions)
+	return fmt.Sprintf("{%s}", strings.Join(descriptions, ", "))
+}
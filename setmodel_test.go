@@ -0,0 +1,52 @@
+package porcupine
+
+import "testing"
+
+func TestSetModelRemove(t *testing.T) {
+	model := SetModel()
+	// the read is concurrent with the remove of 1
+	ops := []Operation{
+		{0, SetInput{SetAdd, 1}, 0, SetOutput{}, 10},
+		{0, SetInput{SetAdd, 2}, 20, SetOutput{}, 30},
+		{1, SetInput{SetRemove, 1}, 40, SetOutput{}, 60},
+		{2, SetInput{SetRead, nil}, 45, SetOutput{Values: []interface{}{2, 1}}, 55},
+		{2, SetInput{SetRead, nil}, 70, SetOutput{Values: []interface{}{2}}, 80},
+		{0, SetInput{SetRemove, 3}, 90, SetOutput{}, 100},
+		{2, SetInput{SetRead, nil}, 110, SetOutput{Unknown: true}, 120},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// the read observes 1 after its removal was acknowledged
+	ops = []Operation{
+		{0, SetInput{SetAdd, 1}, 0, SetOutput{}, 10},
+		{1, SetInput{SetRemove, 1}, 20, SetOutput{}, 30},
+		{2, SetInput{SetRead, nil}, 40, SetOutput{Values: []interface{}{1}}, 50},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+
+	// add, then remove, then add again
+	ops = []Operation{
+		{0, SetInput{SetAdd, 1}, 0, SetOutput{}, 10},
+		{1, SetInput{SetRemove, 1}, 20, SetOutput{}, 30},
+		{0, SetInput{SetAdd, 1}, 40, SetOutput{}, 50},
+		{2, SetInput{SetRead, nil}, 60, SetOutput{Values: []interface{}{1}}, 70},
+	}
+	if !CheckOperations(model, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+
+	// reads can't contain duplicates
+	ops = []Operation{
+		{0, SetInput{SetAdd, 1}, 0, SetOutput{}, 10},
+		{2, SetInput{SetRead, nil}, 20, SetOutput{Values: []interface{}{1, 1}}, 30},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
@@ -0,0 +1,112 @@
+package porcupine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// snapshotInput and snapshotOutput are the input and output of a group of
+// operations that are linearized together.
+type snapshotInput struct {
+	inputs []interface{}
+}
+
+type snapshotOutput struct {
+	outputs []interface{}
+}
+
+// CheckOperationsSnapshots checks whether a history is linearizable, where
+// each of the given groups of operations, e.g., a batch of reads from a
+// snapshot of an MVCC database, must share a single linearization point.
+//
+// Each group is given as a list of indices into the history. The operations
+// in a group are applied atomically, in order, with no other operation in
+// between, so a group of reads must be consistent with a single state. The
+// shared linearization point must lie within the interval of every
+// operation in the group, so a group of operations that don't all overlap
+// can't be linearized. Each operation can be in at most one group; indices
+// that are out of range or that are already in an earlier group are ignored.
+//
+// Because groups can span partitions, the model's partition functions are
+// not used, so the model must describe the entire system.
+func CheckOperationsSnapshots(model Model, history []Operation, snapshots [][]int) bool {
+	model = fillDefault(model)
+	grouped := make([]bool, len(history))
+	var combined []Operation
+	for _, group := range snapshots {
+		var op *Operation
+		for _, i := range group {
+			if i < 0 || i >= len(history) || grouped[i] {
+				continue
+			}
+			grouped[i] = true
+			h := history[i]
+			if op == nil {
+				op = &Operation{ClientId: h.ClientId, Input: snapshotInput{}, Call: h.Call, Output: snapshotOutput{}, Return: h.Return}
+			}
+			if h.Call > op.Call {
+				op.Call = h.Call
+			}
+			if h.Return < op.Return {
+				op.Return = h.Return
+			}
+			op.Input = snapshotInput{append(op.Input.(snapshotInput).inputs, h.Input)}
+			op.Output = snapshotOutput{append(op.Output.(snapshotOutput).outputs, h.Output)}
+		}
+		if op == nil {
+			continue
+		}
+		if op.Call > op.Return {
+			// there is no common linearization point
+			return false
+		}
+		combined = append(combined, *op)
+	}
+	for i, op := range history {
+		if !grouped[i] {
+			combined = append(combined, op)
+		}
+	}
+	res, _ := checkOperations(snapshotModel(model), combined, CheckOptions{})
+	return res == Ok
+}
+
+// snapshotModel extends a model to support groups of operations that are
+// linearized together.
+func snapshotModel(model Model) Model {
+	step := model.Step
+	describeOperation := model.DescribeOperation
+	model.Partition = nil
+	model.PartitionEvent = nil
+	model.PartitionLabeled = nil
+	model.PartitionEventLabeled = nil
+	model.StepVerbose = nil
+	model.Step = func(state, input, output interface{}) (bool, interface{}) {
+		inp, ok := input.(snapshotInput)
+		if !ok {
+			return step(state, input, output)
+		}
+		out := output.(snapshotOutput)
+		for i := range inp.inputs {
+			var ok bool
+			ok, state = step(state, inp.inputs[i], out.outputs[i])
+			if !ok {
+				return false, state
+			}
+		}
+		return true, state
+	}
+	model.DescribeOperation = func(input, output interface{}) string {
+		inp, ok := input.(snapshotInput)
+		if !ok {
+			return describeOperation(input, output)
+		}
+		out := output.(snapshotOutput)
+		descriptions := make([]string, len(inp.inputs))
+		for i := range inp.inputs {
+			descriptions[i] = describeOperation(inp.inputs[i], out.outputs[i])
+		}
+		return fmt.Sprintf("snapshot[%s]", strings.Join(descriptions, "; "))
+	}
+	return model
+}
@@ -0,0 +1,47 @@
+package porcupine
+
+import "testing"
+
+func TestCheckOperationsSnapshots(t *testing.T) {
+	// two reads that are concurrent with two writes see only the write of
+	// x, which is consistent with a single snapshot
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "0"}, 0, kvOutput{}, 100},
+		{0, kvInput{op: 1, key: "y", value: "1"}, 0, kvOutput{}, 100},
+		{1, kvInput{op: 0, key: "x"}, 10, kvOutput{"0"}, 90},
+		{2, kvInput{op: 0, key: "y"}, 20, kvOutput{""}, 80},
+	}
+	if !CheckOperations(kvNoPartitionModel, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+	if !CheckOperationsSnapshots(kvNoPartitionModel, ops, [][]int{{2, 3}}) {
+		t.Fatal("expected operations to be linearizable with a snapshot")
+	}
+
+	// the writes are sequential, so a snapshot can't see the second write
+	// without the first
+	ops = []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{0, kvInput{op: 1, key: "y", value: "b"}, 20, kvOutput{}, 30},
+		{1, kvInput{op: 0, key: "x"}, 5, kvOutput{""}, 50},
+		{2, kvInput{op: 0, key: "y"}, 5, kvOutput{"b"}, 50},
+	}
+	if !CheckOperations(kvNoPartitionModel, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+	if CheckOperationsSnapshots(kvNoPartitionModel, ops, [][]int{{2, 3}}) {
+		t.Fatal("expected operations not to be linearizable with a snapshot")
+	}
+
+	// operations in a snapshot must overlap
+	ops = []Operation{
+		{1, kvInput{op: 0, key: "x"}, 0, kvOutput{""}, 10},
+		{2, kvInput{op: 0, key: "y"}, 20, kvOutput{""}, 30},
+	}
+	if CheckOperationsSnapshots(kvNoPartitionModel, ops, [][]int{{0, 1}}) {
+		t.Fatal("expected operations not to be linearizable with a snapshot")
+	}
+	if !CheckOperationsSnapshots(kvNoPartitionModel, ops, [][]int{{0, 5}}) {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
@@ -0,0 +1,50 @@
+package porcupine
+
+import "time"
+
+// A Span is a traced operation, e.g., an OpenTelemetry span, with the time
+// at which it started and ended, along with its attributes.
+//
+// A span that never ended, e.g., because the client crashed or the trace was
+// exported while the operation was in progress, has a zero EndTime.
+type Span struct {
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+}
+
+// FromSpans converts traced operations to a history that can be checked
+// with functions like [CheckOperations], using the start and end times of
+// each span, in nanoseconds since the Unix epoch, as the Call and Return
+// times of the corresponding operation.
+//
+// The decode function gives the client ID, input, and output of the
+// operation that a span represents, given the span's attributes. A span that
+// never ended is an operation whose effect is indeterminate: it is converted
+// to an operation with a [PendingOutput], ignoring the output given by
+// decode, that returns after every other operation.
+func FromSpans(spans []Span, decode func(attrs map[string]interface{}) (clientId int, input, output interface{})) []Operation {
+	var last int64
+	for _, span := range spans {
+		if t := span.StartTime.UnixNano(); t > last {
+			last = t
+		}
+		if !span.EndTime.IsZero() {
+			if t := span.EndTime.UnixNano(); t > last {
+				last = t
+			}
+		}
+	}
+	history := make([]Operation, len(spans))
+	for i, span := range spans {
+		clientId, input, output := decode(span.Attributes)
+		ret := last + 1
+		if span.EndTime.IsZero() {
+			output = PendingOutput{}
+		} else {
+			ret = span.EndTime.UnixNano()
+		}
+		history[i] = Operation{clientId, input, span.StartTime.UnixNano(), output, ret}
+	}
+	return history
+}
@@ -0,0 +1,46 @@
+package porcupine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFromSpans(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	at := func(ms int) time.Time {
+		return t0.Add(time.Duration(ms) * time.Millisecond)
+	}
+	decode := func(attrs map[string]interface{}) (int, interface{}, interface{}) {
+		input := registerInput{op: attrs["op"] == "get"}
+		if v, ok := attrs["value"]; ok {
+			input.value = v.(int)
+		}
+		var output interface{} = 0
+		if v, ok := attrs["result"]; ok {
+			output = v.(int)
+		}
+		return attrs["client"].(int), input, output
+	}
+	spans := []Span{
+		{at(0), at(10), map[string]interface{}{"client": 0, "op": "put", "value": 1}},
+		{at(20), time.Time{}, map[string]interface{}{"client": 1, "op": "put", "value": 2}},
+		{at(30), at(40), map[string]interface{}{"client": 0, "op": "get", "result": 1}},
+		{at(50), at(60), map[string]interface{}{"client": 2, "op": "get", "result": 2}},
+	}
+	history := FromSpans(spans, decode)
+	if history[0].Call != t0.UnixNano() || history[0].Return != at(10).UnixNano() || history[2].ClientId != 0 {
+		t.Fatalf("unexpected operation %+v", history[0])
+	}
+	if _, ok := history[1].Output.(PendingOutput); !ok || history[1].Return <= at(60).UnixNano() {
+		t.Fatalf("expected a pending operation that returns last, got %+v", history[1])
+	}
+	// the pending put may take effect at any point after it is called, and
+	// the register model accepts puts with any output
+	if !CheckOperations(registerModel, history) {
+		t.Fatal("expected operations to be linearizable")
+	}
+	spans[3].Attributes["result"] = 3
+	if CheckOperations(registerModel, FromSpans(spans, decode)) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
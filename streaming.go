@@ -0,0 +1,85 @@
+package porcupine
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A Checker records a history of [Event] as it happens, e.g., while a stress
+// test is running, so that the operations recorded so far can be checked at
+// any time, without waiting for the test to finish. This makes it possible
+// to fail fast: a test can check periodically and stop as soon as the
+// history is no longer linearizable.
+//
+// Operations that have been called but have not returned yet are treated as
+// pending (see [PendingOutput]) when checking. Every check is of the entire
+// history recorded so far, so checks get slower as the history grows.
+//
+// A Checker is safe for concurrent use by multiple goroutines, and recording
+// events can proceed while a check is running.
+type Checker struct {
+	mu      sync.Mutex
+	model   Model
+	events  []Event
+	nextId  int
+	clients map[int]int // id -> client, for pending calls
+}
+
+// NewChecker returns a [Checker] for the given model, with an empty history.
+func NewChecker(model Model) *Checker {
+	return &Checker{model: model, clients: make(map[int]int)}
+}
+
+// Call records a call event for the given client with the given input,
+// returning the id to pass to [Checker.Return] when the operation returns.
+func (c *Checker) Call(clientId int, input interface{}) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextId
+	c.nextId++
+	c.events = append(c.events, Event{clientId, CallEvent, input, id})
+	c.clients[id] = clientId
+	return id
+}
+
+// Return records the return event, with the given output, for the call with
+// the given id.
+func (c *Checker) Return(id int, output interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clientId, ok := c.clients[id]
+	if !ok {
+		return fmt.Errorf("no pending call with id %d", id)
+	}
+	c.events = append(c.events, Event{clientId, ReturnEvent, output, id})
+	delete(c.clients, id)
+	return nil
+}
+
+// Events returns the history recorded so far.
+func (c *Checker) Events() []Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	events := make([]Event, len(c.events))
+	copy(events, c.events)
+	return events
+}
+
+// Check checks whether the history recorded so far is linearizable.
+func (c *Checker) Check() CheckResult {
+	res, _ := checkEvents(c.model, c.Events(), CheckOptions{})
+	return res
+}
+
+// CheckAndVisualizeOnFailure checks whether the history recorded so far is
+// linearizable, and if it is not, writes a visualization of it to w (see
+// [Visualize]). It returns an error only if writing the visualization
+// failed.
+func (c *Checker) CheckAndVisualizeOnFailure(w io.Writer) (CheckResult, error) {
+	res, info := CheckEventsLazyVerbose(c.model, c.Events(), 0)
+	if res != Illegal {
+		return res, nil
+	}
+	return res, Visualize(c.model, info, w)
+}
@@ -0,0 +1,51 @@
+package porcupine
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestChecker(t *testing.T) {
+	checker := NewChecker(registerModel)
+	var wg sync.WaitGroup
+	for client := 0; client < 4; client++ {
+		wg.Add(1)
+		go func(client int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				id := checker.Call(client, registerInput{true, 0})
+				if err := checker.Return(id, 0); err != nil {
+					t.Error(err)
+				}
+			}
+		}(client)
+	}
+	wg.Wait()
+	// a pending put doesn't make the history illegal
+	checker.Call(0, registerInput{false, 1})
+	var out bytes.Buffer
+	if res, err := checker.CheckAndVisualizeOnFailure(&out); res != Ok || err != nil {
+		t.Fatalf("expected output %v, got output %v (%v)", Ok, res, err)
+	}
+	if out.Len() != 0 {
+		t.Fatal("expected no visualization")
+	}
+	if len(checker.Events()) != 81 {
+		t.Fatalf("expected 81 events, got %d", len(checker.Events()))
+	}
+	id := checker.Call(1, registerInput{true, 0})
+	if err := checker.Return(id, 2); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := checker.CheckAndVisualizeOnFailure(&out); res != Illegal || err != nil {
+		t.Fatalf("expected output %v, got output %v (%v)", Illegal, res, err)
+	}
+	if !strings.Contains(out.String(), "<html") {
+		t.Fatal("expected a visualization")
+	}
+	if err := checker.Return(id, 2); err == nil {
+		t.Fatal("expected error returning twice")
+	}
+}
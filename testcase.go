@@ -0,0 +1,181 @@
+package porcupine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A TestCase is a history, along with the result of checking it and the
+// linearization that was found, that can be saved to a regression suite
+// using [LinearizationInfo.ExportTestCase] and loaded using [ReadTestCase].
+type TestCase struct {
+	Partitions []TestCasePartition
+}
+
+// A TestCasePartition is a partition of the history of a [TestCase].
+type TestCasePartition struct {
+	// Label of the partition, if the model's partition function gives
+	// partitions labels (see [Model]).
+	Label string
+	// Whether the partition is linearizable.
+	Linearizable bool
+	// Operations of the partition. For histories of [Event], the Call and
+	// Return times are the positions of the events in the history.
+	Operations []Operation
+	// A linearization of the partition, as indices into Operations, if
+	// the partition is linearizable; otherwise, the longest partial
+	// linearization that was found.
+	Linearization []int
+}
+
+type testCaseJSON struct {
+	Partitions []testCasePartitionJSON
+}
+
+type testCasePartitionJSON struct {
+	Label         string `json:",omitempty"`
+	Linearizable  bool
+	Operations    []testCaseOperationJSON
+	Linearization []int
+}
+
+type testCaseOperationJSON struct {
+	ClientId int
+	Input    json.RawMessage
+	Call     int64
+	Output   json.RawMessage
+	Return   int64
+}
+
+// ExportTestCase writes the checked history, along with the result of the
+// check and the linearization that was found for each partition, to w as
+// JSON, so that it can be added to a regression suite, read back using
+// [ReadTestCase], and re-verified using [TestCase.Verify]. Inputs and
+// outputs must be encodable using [encoding/json].
+//
+// The info must come from a verbose check, such as [CheckOperationsVerbose].
+func (li *LinearizationInfo) ExportTestCase(w io.Writer) error {
+	data := testCaseJSON{Partitions: make([]testCasePartitionJSON, len(li.history))}
+	for p, partition := range li.history {
+		ops := make([]testCaseOperationJSON, len(partition)/2)
+		for _, e := range partition {
+			value, err := json.Marshal(e.value)
+			if err != nil {
+				return err
+			}
+			if e.kind == callEntry {
+				ops[e.id].ClientId = e.clientId
+				ops[e.id].Input = value
+				ops[e.id].Call = e.time
+			} else {
+				ops[e.id].Output = value
+				ops[e.id].Return = e.time
+			}
+		}
+		linearization := []int{}
+		for _, partial := range li.partialLinearizations[p] {
+			if len(partial) > len(linearization) {
+				linearization = partial
+			}
+		}
+		pd := testCasePartitionJSON{
+			Linearizable:  len(linearization) == len(ops),
+			Operations:    ops,
+			Linearization: linearization,
+		}
+		if p < len(li.partitions) {
+			pd.Label = li.partitions[p].Label
+			pd.Linearizable = li.partitions[p].Linearizable
+		}
+		data.Partitions[p] = pd
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// ReadTestCase reads a test case that was written using
+// [LinearizationInfo.ExportTestCase], using the given functions to decode
+// the JSON encoding of inputs and outputs. If a function is nil, values are
+// decoded using [json.Unmarshal] into an interface{}.
+func ReadTestCase(r io.Reader, decodeInput, decodeOutput func(data []byte) (interface{}, error)) (TestCase, error) {
+	if decodeInput == nil {
+		decodeInput = decodeJSONValue
+	}
+	if decodeOutput == nil {
+		decodeOutput = decodeJSONValue
+	}
+	var data testCaseJSON
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return TestCase{}, err
+	}
+	tc := TestCase{Partitions: make([]TestCasePartition, len(data.Partitions))}
+	for p, pd := range data.Partitions {
+		ops := make([]Operation, len(pd.Operations))
+		for i, op := range pd.Operations {
+			input, err := decodeInput(op.Input)
+			if err != nil {
+				return TestCase{}, fmt.Errorf("partition %d: operation %d: %v", p, i, err)
+			}
+			output, err := decodeOutput(op.Output)
+			if err != nil {
+				return TestCase{}, fmt.Errorf("partition %d: operation %d: %v", p, i, err)
+			}
+			ops[i] = Operation{ClientId: op.ClientId, Input: input, Call: op.Call, Output: output, Return: op.Return}
+		}
+		tc.Partitions[p] = TestCasePartition{
+			Label:         pd.Label,
+			Linearizable:  pd.Linearizable,
+			Operations:    ops,
+			Linearization: pd.Linearization,
+		}
+	}
+	return tc, nil
+}
+
+// Verify re-checks a test case using the given model. It returns an error if
+// the linearization of some partition is not valid, i.e., it doesn't respect
+// the real-time order of the operations or the model rejects one of its
+// steps, or if the result of checking some partition differs from the
+// recorded result.
+func (tc TestCase) Verify(model Model) error {
+	model = fillDefault(model)
+	for p, partition := range tc.Partitions {
+		name := fmt.Sprintf("partition %d", p)
+		if partition.Label != "" {
+			name = fmt.Sprintf("partition %q", partition.Label)
+		}
+		model := model
+		if partition.Label != "" {
+			model = model.partitionModel(partition.Label)
+		}
+		ops := partition.Operations
+		state := model.Init()
+		seen := make([]bool, len(ops))
+		for i, id := range partition.Linearization {
+			if id < 0 || id >= len(ops) || seen[id] {
+				return fmt.Errorf("%s: invalid operation %d in linearization", name, id)
+			}
+			seen[id] = true
+			for _, later := range partition.Linearization[i+1:] {
+				if later >= 0 && later < len(ops) && ops[later].Return < ops[id].Call {
+					return fmt.Errorf("%s: operation %d is linearized before operation %d, which returned before it was called", name, id, later)
+				}
+			}
+			var ok bool
+			ok, state = model.Step(state, ops[id].Input, ops[id].Output)
+			if !ok {
+				return fmt.Errorf("%s: operation %d is not legal after the preceding operations in the linearization", name, id)
+			}
+		}
+		if partition.Linearizable && len(partition.Linearization) != len(ops) {
+			return fmt.Errorf("%s: linearization has %d operations, expected %d", name, len(partition.Linearization), len(ops))
+		}
+		res, _ := checkParallel(model, [][]entry{makeEntries(ops, 0)}, CheckOptions{}, nil, nil)
+		if (res == Ok) != partition.Linearizable {
+			return fmt.Errorf("%s: expected linearizable to be %v, got result %v", name, partition.Linearizable, res)
+		}
+	}
+	return nil
+}
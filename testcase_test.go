@@ -0,0 +1,86 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decodeSetInput(data []byte) (interface{}, error) {
+	var v SetInput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func decodeSetOutput(data []byte) (interface{}, error) {
+	var v SetOutput
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func TestExportTestCase(t *testing.T) {
+	model := SetModel()
+	ops := []Operation{
+		{0, SetInput{SetAdd, "x"}, 0, SetOutput{}, 10},
+		{1, SetInput{SetAdd, "y"}, 5, SetOutput{}, 15},
+		{2, SetInput{SetRead, nil}, 8, SetOutput{Values: []interface{}{"y"}}, 12},
+		{2, SetInput{SetRead, nil}, 20, SetOutput{Values: []interface{}{"x", "y"}}, 30},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	var b bytes.Buffer
+	if err := info.ExportTestCase(&b); err != nil {
+		t.Fatal(err)
+	}
+	exported := b.String()
+	tc, err := ReadTestCase(strings.NewReader(exported), decodeSetInput, decodeSetOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tc.Partitions) != 1 || !tc.Partitions[0].Linearizable || len(tc.Partitions[0].Operations) != 4 {
+		t.Fatalf("unexpected test case %+v", tc)
+	}
+	if err := tc.Verify(model); err != nil {
+		t.Fatalf("expected test case to verify, got %v", err)
+	}
+
+	// a linearization that doesn't respect real-time order
+	lin := tc.Partitions[0].Linearization
+	for i, id := range lin {
+		if id == 3 {
+			lin[0], lin[i] = lin[i], lin[0]
+		}
+	}
+	if err := tc.Verify(model); err == nil {
+		t.Fatal("expected invalid linearization not to verify")
+	}
+
+	// a history that is not linearizable
+	ops[3].Output = SetOutput{Values: []interface{}{"x"}}
+	res, info = CheckOperationsVerbose(model, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	b.Reset()
+	if err := info.ExportTestCase(&b); err != nil {
+		t.Fatal(err)
+	}
+	tc, err = ReadTestCase(&b, decodeSetInput, decodeSetOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.Partitions[0].Linearizable || len(tc.Partitions[0].Linearization) != 3 {
+		t.Fatalf("unexpected test case %+v", tc)
+	}
+	if err := tc.Verify(model); err != nil {
+		t.Fatalf("expected test case to verify, got %v", err)
+	}
+	// the recorded result doesn't match
+	tc.Partitions[0].Linearizable = true
+	if err := tc.Verify(model); err == nil {
+		t.Fatal("expected mismatched result not to verify")
+	}
+}
@@ -1,49 +1,146 @@
 package porcupine
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"sort"
+	"time"
 )
 
-type historyElement struct {
+// A HistoryElement is an operation in the history of a partition, as laid
+// out in a visualization.
+type HistoryElement struct {
+	// The lane in which the operation is drawn, which is the operation's
+	// ClientId unless lanes are assigned automatically (see
+	// [VisualizeOptions]).
 	ClientId    int
 	Start       int64
 	End         int64
-	Description string
+	Description string            // as given by the model's DescribeOperation function
+	Id          int               `json:",omitempty"` // only set if ShowIds is set
+	StartTime   string            `json:",omitempty"` // only set if RelativeTimestamps is set
+	EndTime     string            `json:",omitempty"` // only set if RelativeTimestamps is set
+	Metadata    map[string]string `json:",omitempty"` // see [LinearizationInfo.AddOperationMetadata]
+	Expected    string            `json:",omitempty"` // only set if ExpectedOutput is set
+	Mismatch    bool              `json:",omitempty"` // only set if ExpectedOutput is set
+	differs     bool              // only used when comparing histories
 }
 
-type annotation struct {
+// A VisualizationAnnotation is an [Annotation], as laid out in a
+// visualization.
+type VisualizationAnnotation struct {
 	ClientId        int
 	Tag             string
 	Start           int64
 	End             int64
 	Description     string
 	Details         string
-	Annotation      bool // always true
 	TextColor       string
 	BackgroundColor string
+	EndExclusive    bool `json:",omitempty"`
+	Row             int  `json:",omitempty"` // sub-row within the tag's lane
 }
 
-type linearizationStep struct {
-	Index            int
-	StateDescription string
+// A LinearizationStep is an operation in a partial linearization, given by its
+// index in the partition's History, along with the state after the operation
+// and, if the model has a DescribeTransition function, what the operation
+// changed.
+type LinearizationStep struct {
+	Index                 int
+	StateDescription      string
+	TransitionDescription string `json:",omitempty"`
 }
 
-type partialLinearization = []linearizationStep
+// PartitionVisualizationData is the data from which a single partition of a
+// history is visualized.
+type PartitionVisualizationData struct {
+	Label                 string `json:",omitempty"`
+	History               []HistoryElement
+	PartialLinearizations [][]LinearizationStep // sorted by decreasing length
+	Largest               map[int]int           // for each operation, the index of the largest partial linearization that includes it
+	Rejections            []map[int]string      `json:",omitempty"` // for each partial linearization, reasons the next operations were rejected
+}
+
+// VisualizationData is the data from which a visualization is rendered, as
+// computed by [ComputeVisualizationData].
+type VisualizationData struct {
+	Partitions   []PartitionVisualizationData
+	Annotations  []VisualizationAnnotation
+	ShowIds      bool
+	ShowStates   bool
+	Title        string              `json:",omitempty"`
+	Metadata     map[string]string   `json:",omitempty"`
+	TagStyles    map[string]TagStyle `json:",omitempty"` // by tag, as set by [LinearizationInfo.SetTagStyle]
+	clientLabels []string            // if not set, clients are labeled by ClientId
+	dividers     []int               // clients above which to draw a divider
+	historyOnly  bool                // whether this is a history without a check, from [VisualizeHistory]
+}
 
-type partitionVisualizationData struct {
-	History               []historyElement
-	PartialLinearizations []partialLinearization
-	Largest               map[int]int
+// VisualizeOptions configures the visualization produced by
+// [VisualizeWithOptions].
+//
+// The zero value corresponds to the behavior of [Visualize].
+type VisualizeOptions struct {
+	// Show the ID of each operation next to its description. These are the
+	// IDs used in [LinearizationInfo.PartialLinearizations].
+	ShowIds bool
+	// Show the state (as given by the model's DescribeState function)
+	// between every adjacent pair of operations along each linearization,
+	// rather than only in the tooltip. This can be useful for seeing how
+	// the state evolves, but it can be verbose.
+	ShowStates bool
+	// Ignore the ClientId of each operation, and instead assign operations
+	// to lanes by packing operations that don't overlap in time into the
+	// same lane. This is useful for histories that don't have a notion of
+	// clients, where all ClientIds are the same. Annotations are not
+	// reassigned, so annotations should use a Tag rather than a ClientId.
+	AutoLanes bool
+	// A title to show in a header of the visualization, and as the title
+	// of the HTML page, e.g., the name of the test that produced it.
+	Title string
+	// Metadata to show in a header of the visualization, e.g., a
+	// timestamp, a git commit, or the result of the check. Entries are
+	// shown in order of key.
+	Metadata map[string]string
+	// Show the call and return times of operations in tooltips relative to
+	// the earliest call time, formatted as durations (e.g., "+1.2ms"),
+	// rather than as raw timestamps. This is useful when timestamps are in
+	// nanoseconds, e.g., from [time.Since].
+	RelativeTimestamps bool
+	// Show only the longest partial linearization of each partition,
+	// rather than all of them. For a partition that is linearizable, this
+	// is a full linearization, and for one that is not, it is the longest
+	// prefix that could be linearized. This reduces clutter for large
+	// partitions.
+	OnlyLargest bool
+	// A function that gives the output that the model predicts for an
+	// operation with the given input in the given state. If set, each
+	// operation is shown along with the output predicted in the state
+	// before it in the longest partial linearization that includes it, or,
+	// for an operation that isn't part of any partial linearization, in
+	// the state at the end of the longest partial linearization, where the
+	// check got stuck. Operations whose observed output differs from the
+	// predicted one (according to the model's EqualOutput) are highlighted,
+	// which shows where observations diverge from the model.
+	ExpectedOutput func(state, input interface{}) interface{}
+	// If set, show only the operations and annotations that overlap this
+	// window of time, so that a very large history can be viewed one
+	// window at a time. The number of operations that were omitted is
+	// shown in the header. Partial linearizations are shown only for the
+	// operations in the window.
+	Window *TimeWindow
 }
 
-type visualizationData struct {
-	Partitions  []partitionVisualizationData
-	Annotations []annotation
+// A TimeWindow is the closed interval of time [From, To], in the same units
+// as the timestamps of the history.
+type TimeWindow struct {
+	From int64
+	To   int64
 }
 
 // Annotations to add to histories.
@@ -55,6 +152,16 @@ type visualizationData struct {
 // tooltip for the annotation. TextColor and BackgroundColor are both optional;
 // if specified, they should be valid CSS colors, e.g., "#efaefc".
 //
+// By default, like the interval of an operation, the interval of an
+// annotation is closed, [Start, End], so an annotation that ends at time t is
+// drawn overlapping operations and annotations that start at t. If
+// EndExclusive is set, the interval is half-open, [Start, End), so the
+// annotation is drawn ending before anything that starts at End. This has no
+// effect on point-in-time annotations.
+//
+// Annotations with the same Tag that overlap in time are drawn in separate
+// rows of the tag's lane, so that none of them are hidden.
+//
 // To attach annotations to a visualization, use
 // [LinearizationInfo.AddAnnotations].
 type Annotation struct {
@@ -66,6 +173,7 @@ type Annotation struct {
 	Details         string
 	TextColor       string
 	BackgroundColor string
+	EndExclusive    bool
 }
 
 // AddAnnotations adds extra annotations to a visualization.
@@ -81,27 +189,214 @@ func (li *LinearizationInfo) AddAnnotations(annotations []Annotation) {
 		if end < elem.Start {
 			end = elem.Start
 		}
-		li.annotations = append(li.annotations, annotation{
+		li.annotations = append(li.annotations, VisualizationAnnotation{
 			ClientId:        elem.ClientId,
 			Tag:             elem.Tag,
 			Start:           elem.Start,
 			End:             end,
 			Description:     elem.Description,
 			Details:         elem.Details,
-			Annotation:      true,
 			TextColor:       elem.TextColor,
 			BackgroundColor: elem.BackgroundColor,
+			EndExclusive:    elem.EndExclusive && end > elem.Start,
 		})
 	}
 }
 
-func computeVisualizationData(model Model, info LinearizationInfo) visualizationData {
+// AddAnnotationsJSON adds extra annotations to a visualization, decoding them
+// from a JSON array of objects with the same fields as [Annotation].
+//
+// This is useful when annotations are produced by a different process, e.g.,
+// a test framework that logs server events to a file. See
+// [LinearizationInfo.AddAnnotations] for details.
+func (li *LinearizationInfo) AddAnnotationsJSON(r io.Reader) error {
+	var annotations []Annotation
+	if err := json.NewDecoder(r).Decode(&annotations); err != nil {
+		return err
+	}
+	li.AddAnnotations(annotations)
+	return nil
+}
+
+// A TagStyle is the style of annotations with a given tag, as set by
+// [LinearizationInfo.SetTagStyle].
+type TagStyle struct {
+	BackgroundColor string `json:",omitempty"`
+	TextColor       string `json:",omitempty"`
+	Label           string `json:",omitempty"`
+}
+
+// SetTagStyle sets the default colors of annotations with the given tag, so
+// that annotations of the same kind, e.g., "leader" or "restart", have a
+// consistent look without setting colors on every annotation. Colors set on
+// an individual annotation take precedence. The label describes the tag in
+// a legend. Empty strings leave the corresponding defaults unset, and
+// setting the style of a tag again replaces its previous style.
+func (li *LinearizationInfo) SetTagStyle(tag string, color, textColor string, label string) {
+	if li.tagStyles == nil {
+		li.tagStyles = make(map[string]TagStyle)
+	}
+	li.tagStyles[tag] = TagStyle{BackgroundColor: color, TextColor: textColor, Label: label}
+}
+
+// OperationMetadata is extra information about an operation, e.g., the server
+// that handled it or the number of times it was retried, that is not part of
+// the operation's input or output.
+//
+// The operation is identified by its ClientId and Call time. Entries of the
+// Metadata map are shown in the operation's tooltip, in order of key.
+//
+// To attach metadata to operations in a visualization, use
+// [LinearizationInfo.AddOperationMetadata].
+type OperationMetadata struct {
+	ClientId int
+	Call     int64
+	Metadata map[string]string
+}
+
+// AddOperationMetadata adds metadata to operations in a visualization. The
+// checker ignores metadata; it is only used by the visualization.
+//
+// Operations are identified by their ClientId and Call time, as given in the
+// history, even if the check widened the intervals of operations (see
+// [CheckOptions.TimestampEpsilon]), so this is meant for histories of
+// [Operation]. For histories of [Event], the call time
+// of an operation is the position of its call event in the operation's
+// partition. Metadata that doesn't match any operation is ignored, and
+// metadata that matches several operations is added to all of them. If
+// metadata is added to an operation more than once, the entries are merged.
+func (li *LinearizationInfo) AddOperationMetadata(metadata []OperationMetadata) {
+	li.metadata = append(li.metadata, metadata...)
+}
+
+// AddStateAnnotations adds annotations to a visualization that show the
+// state of the system at the given times, like synthetic reads that observe
+// the state without affecting it. This can help with debugging, e.g., to see
+// how the state of a key-value store evolves along the timeline.
+//
+// The state at each time is found by replaying the longest (partial)
+// linearization of each partition, using the model's Step and DescribeState
+// functions: it is the state after all of the operations in the
+// linearization that were called by that time, which includes every
+// operation that returned by then. If the linearization doesn't include some
+// operation that returned by then, e.g., because the partition is not
+// linearizable, the state is unknown, and no annotation is added. Times are
+// in the same units as the history, e.g., the Call and Return times of
+// [Operation]. Annotations are tagged "State", followed by the partition's
+// label (or index, if partitions aren't labeled) if there are multiple
+// partitions.
+//
+// The info must come from a verbose check, such as [CheckOperationsVerbose].
+func (li *LinearizationInfo) AddStateAnnotations(model Model, times []int64) {
+	model = fillDefault(model)
+	var annotations []Annotation
+	for p, partition := range li.history {
+		model := li.partitionModel(model, p)
+		tag := "State"
+		if len(li.history) > 1 {
+			if p < len(li.partitions) && li.partitions[p].Label != "" {
+				tag = fmt.Sprintf("State: %s", li.partitions[p].Label)
+			} else {
+				tag = fmt.Sprintf("State: partition %d", p)
+			}
+		}
+		var longest []int
+		for _, partial := range li.partialLinearizations[p] {
+			if len(partial) > len(longest) {
+				longest = partial
+			}
+		}
+		n := len(partition) / 2
+		callValue := make([]interface{}, n)
+		returnValue := make([]interface{}, n)
+		callTime := make([]int64, n)
+		returnTime := make([]int64, n)
+		for _, elem := range partition {
+			if elem.kind == callEntry {
+				callValue[elem.id] = elem.value
+				callTime[elem.id] = elem.time
+			} else {
+				returnValue[elem.id] = elem.value
+				returnTime[elem.id] = elem.time
+			}
+		}
+		included := make([]bool, n)
+		for _, t := range times {
+			// the longest prefix of operations that were called by
+			// time t
+			state := model.Init()
+			for i := range included {
+				included[i] = false
+			}
+			for _, id := range longest {
+				if callTime[id] > t {
+					break
+				}
+				_, state = model.Step(state, callValue[id], returnValue[id])
+				included[id] = true
+			}
+			known := true
+			for id := 0; id < n; id++ {
+				if !included[id] && returnTime[id] <= t {
+					known = false
+					break
+				}
+			}
+			if !known {
+				continue
+			}
+			annotations = append(annotations, Annotation{
+				Tag:         tag,
+				Start:       t,
+				Description: model.DescribeState(state),
+			})
+		}
+	}
+	li.AddAnnotations(annotations)
+}
+
+// AnnotateState adds an annotation to a visualization that shows the given
+// state of the model, e.g., a state observed while replaying a history. The
+// annotation's Details are set to the description of the state given by the
+// model's DescribeState function, and if the annotation has no Description,
+// it is set to that as well. This is otherwise like
+// [LinearizationInfo.AddAnnotations]; for example, the annotation can be a
+// point-in-time annotation, or it can span a time range over which the
+// state holds.
+func (li *LinearizationInfo) AnnotateState(model Model, annotation Annotation, state interface{}) {
+	model = fillDefault(model)
+	annotation.Details = model.DescribeState(state)
+	if annotation.Description == "" {
+		annotation.Description = annotation.Details
+	}
+	li.AddAnnotations([]Annotation{annotation})
+}
+
+// ComputeVisualizationData computes the data from which [Visualize] renders
+// a visualization, without rendering it.
+//
+// This is useful for testing: the layout of a visualization, e.g., which
+// partial linearizations are shown and the states along them, can be checked
+// directly rather than by inspecting HTML.
+func ComputeVisualizationData(model Model, info LinearizationInfo) VisualizationData {
+	return computeVisualizationData(model, info, VisualizeOptions{})
+}
+
+// ComputeVisualizationDataWithOptions is like [ComputeVisualizationData], but
+// computes the data from which [VisualizeWithOptions] renders a
+// visualization with the given options.
+func ComputeVisualizationDataWithOptions(model Model, info LinearizationInfo, opts VisualizeOptions) VisualizationData {
+	return computeVisualizationData(model, info, opts)
+}
+
+func computeVisualizationData(model Model, info LinearizationInfo, opts VisualizeOptions) VisualizationData {
 	model = fillDefault(model)
-	partitions := make([]partitionVisualizationData, len(info.history))
+	partitions := make([]PartitionVisualizationData, len(info.history))
 	for partition := 0; partition < len(info.history); partition++ {
+		model := info.partitionModel(model, partition)
 		// history
 		n := len(info.history[partition]) / 2
-		history := make([]historyElement, n)
+		history := make([]HistoryElement, n)
 		callValue := make(map[int]interface{})
 		returnValue := make(map[int]interface{})
 		for _, elem := range info.history[partition] {
@@ -114,55 +409,358 @@ func computeVisualizationData(model Model, info LinearizationInfo) visualization
 				history[elem.id].End = elem.time
 				history[elem.id].Description = model.DescribeOperation(callValue[elem.id], elem.value)
 				returnValue[elem.id] = elem.value
+				if opts.ShowIds {
+					history[elem.id].Id = elem.id
+				}
 			}
-			// historyElement.Annotation defaults to false, so we
-			// don't need to explicitly set it here; all of these
-			// are non-annotation elements
 		}
 		// partial linearizations
 		largestIndex := make(map[int]int)
 		largestSize := make(map[int]int)
-		linearizations := make([]partialLinearization, len(info.partialLinearizations[partition]))
 		partials := info.partialLinearizations[partition]
 		sort.Slice(partials, func(i, j int) bool {
 			return len(partials[i]) > len(partials[j])
 		})
+		if opts.OnlyLargest && len(partials) > 1 {
+			partials = partials[:1]
+		}
+		linearizations := make([][]LinearizationStep, len(partials))
+		var rejections []map[int]string
+		if model.StepVerbose != nil {
+			rejections = make([]map[int]string, len(partials))
+		}
+		// the state at the end of the longest partial linearization, where
+		// the remaining operations could not be linearized
+		stuckState := model.Init()
 		for i, partial := range partials {
-			linearization := make(partialLinearization, len(partial))
+			linearization := make([]LinearizationStep, len(partial))
 			state := model.Init()
 			for j, histId := range partial {
+				prevState := state
 				var ok bool
 				ok, state = model.Step(state, callValue[histId], returnValue[histId])
 				if !ok {
 					panic("valid partial linearization returned non-ok result from model step")
 				}
 				stateDesc := model.DescribeState(state)
-				linearization[j] = linearizationStep{histId, stateDesc}
+				linearization[j] = LinearizationStep{Index: histId, StateDescription: stateDesc}
+				if model.DescribeTransition != nil {
+					linearization[j].TransitionDescription = model.DescribeTransition(prevState, state, callValue[histId], returnValue[histId])
+				}
 				if largestSize[histId] < len(partial) {
 					largestSize[histId] = len(partial)
 					largestIndex[histId] = i
+					if opts.ExpectedOutput != nil {
+						setExpected(model, &history[histId], prevState, callValue[histId], returnValue[histId], opts.ExpectedOutput)
+					}
 				}
 			}
 			linearizations[i] = linearization
+			if i == 0 {
+				stuckState = state
+			}
+			if rejections != nil {
+				rejections[i] = rejectionReasons(model, history, partial, state, callValue, returnValue)
+			}
 		}
-		partitions[partition] = partitionVisualizationData{
+		if opts.ExpectedOutput != nil {
+			for id := range history {
+				if _, ok := largestIndex[id]; !ok {
+					setExpected(model, &history[id], stuckState, callValue[id], returnValue[id], opts.ExpectedOutput)
+				}
+			}
+		}
+		var label string
+		if partition < len(info.partitions) {
+			label = info.partitions[partition].Label
+		}
+		partitions[partition] = PartitionVisualizationData{
+			Label:                 label,
 			History:               history,
 			PartialLinearizations: linearizations,
 			Largest:               largestIndex,
+			Rejections:            rejections,
+		}
+	}
+	if len(info.metadata) != 0 {
+		addMetadata(partitions, info.metadata, info.callOffset)
+	}
+	metadata := opts.Metadata
+	if opts.Window != nil {
+		omitted := applyWindow(partitions, *opts.Window)
+		metadata = make(map[string]string, len(opts.Metadata)+1)
+		for k, v := range opts.Metadata {
+			metadata[k] = v
 		}
+		metadata["Window"] = fmt.Sprintf("[%d, %d], %d operations omitted", opts.Window.From, opts.Window.To, omitted)
+	}
+	if opts.AutoLanes {
+		assignLanes(partitions)
 	}
-	annotations := info.annotations
-	if annotations == nil {
-		annotations = make([]annotation, 0)
+	if opts.RelativeTimestamps {
+		formatRelativeTimestamps(partitions)
 	}
-	data := visualizationData{
+	annotations := make([]VisualizationAnnotation, 0, len(info.annotations))
+	for _, annotation := range info.annotations {
+		if opts.Window == nil || (annotation.Start <= opts.Window.To && annotation.End >= opts.Window.From) {
+			annotations = append(annotations, annotation)
+		}
+	}
+	for i := range annotations {
+		style, ok := info.tagStyles[annotations[i].Tag]
+		if !ok || annotations[i].Tag == "" {
+			continue
+		}
+		if annotations[i].BackgroundColor == "" {
+			annotations[i].BackgroundColor = style.BackgroundColor
+		}
+		if annotations[i].TextColor == "" {
+			annotations[i].TextColor = style.TextColor
+		}
+	}
+	assignAnnotationRows(annotations)
+	data := VisualizationData{
 		Partitions:  partitions,
 		Annotations: annotations,
+		ShowIds:     opts.ShowIds,
+		ShowStates:  opts.ShowStates,
+		Title:       opts.Title,
+		Metadata:    metadata,
+	}
+	if len(info.tagStyles) != 0 {
+		data.TagStyles = make(map[string]TagStyle, len(info.tagStyles))
+		for tag, style := range info.tagStyles {
+			data.TagStyles[tag] = style
+		}
 	}
 
 	return data
 }
 
+// applyWindow removes the history elements that don't overlap the window
+// from each partition, along with the steps of the partial linearizations
+// that refer to them, renumbering the rest; partial linearizations that are
+// left empty are removed. It returns the number of elements that were
+// removed.
+func applyWindow(partitions []PartitionVisualizationData, window TimeWindow) int {
+	omitted := 0
+	for p := range partitions {
+		partition := &partitions[p]
+		index := make(map[int]int) // old index -> new index, for elements in the window
+		history := []HistoryElement{}
+		for i, elem := range partition.History {
+			if elem.Start <= window.To && elem.End >= window.From {
+				index[i] = len(history)
+				history = append(history, elem)
+			}
+		}
+		omitted += len(partition.History) - len(history)
+		if len(history) == len(partition.History) {
+			continue
+		}
+		partition.History = history
+		linIndex := make(map[int]int) // old index -> new index, for non-empty linearizations
+		linearizations := [][]LinearizationStep{}
+		var rejections []map[int]string
+		for i, linearization := range partition.PartialLinearizations {
+			var steps []LinearizationStep
+			for _, step := range linearization {
+				if j, ok := index[step.Index]; ok {
+					step.Index = j
+					steps = append(steps, step)
+				}
+			}
+			if len(steps) == 0 {
+				continue
+			}
+			linIndex[i] = len(linearizations)
+			linearizations = append(linearizations, steps)
+			if partition.Rejections != nil {
+				kept := make(map[int]string)
+				for k, reason := range partition.Rejections[i] {
+					if j, ok := index[k]; ok {
+						kept[j] = reason
+					}
+				}
+				rejections = append(rejections, kept)
+			}
+		}
+		partition.PartialLinearizations = linearizations
+		partition.Rejections = rejections
+		// an element in the window is in the window's part of its largest
+		// linearization, so that linearization is never removed
+		largest := make(map[int]int)
+		for i, l := range partition.Largest {
+			if j, ok := index[i]; ok {
+				largest[j] = linIndex[l]
+			}
+		}
+		partition.Largest = largest
+	}
+	return omitted
+}
+
+// addMetadata sets the Metadata of every history element that matches the
+// ClientId and Call time of some of the given metadata, where the Call time
+// of an element is its start plus callOffset, undoing the widening by
+// [CheckOptions.TimestampEpsilon]. This must be done before lanes are
+// reassigned.
+func addMetadata(partitions []PartitionVisualizationData, metadata []OperationMetadata, callOffset int64) {
+	type key struct {
+		clientId int
+		call     int64
+	}
+	byKey := make(map[key]map[string]string)
+	for _, m := range metadata {
+		k := key{m.ClientId, m.Call}
+		if byKey[k] == nil {
+			byKey[k] = make(map[string]string)
+		}
+		for name, value := range m.Metadata {
+			byKey[k][name] = value
+		}
+	}
+	for p := range partitions {
+		for i := range partitions[p].History {
+			elem := &partitions[p].History[i]
+			if m, ok := byKey[key{elem.ClientId, elem.Start + callOffset}]; ok && len(m) != 0 {
+				elem.Metadata = m
+			}
+		}
+	}
+}
+
+// setExpected sets the Expected output of a history element, as predicted in
+// the given state, and flags it if it doesn't match the observed output.
+// Pending operations have no observed output, so they are never flagged.
+func setExpected(model Model, elem *HistoryElement, state, input, output interface{}, expectedOutput func(state, input interface{}) interface{}) {
+	expected := expectedOutput(state, input)
+	elem.Expected = model.DescribeOperation(input, expected)
+	if _, pending := output.(PendingOutput); !pending {
+		elem.Mismatch = !model.EqualOutput(expected, output)
+	}
+}
+
+// assignLanes sets the ClientId of every history element so that elements
+// that overlap in time are in different lanes, using as few lanes as
+// possible.
+func assignLanes(partitions []PartitionVisualizationData) {
+	var elems []*HistoryElement
+	for p := range partitions {
+		for i := range partitions[p].History {
+			elems = append(elems, &partitions[p].History[i])
+		}
+	}
+	sort.SliceStable(elems, func(i, j int) bool {
+		if elems[i].Start != elems[j].Start {
+			return elems[i].Start < elems[j].Start
+		}
+		return elems[i].End < elems[j].End
+	})
+	var laneEnds []int64 // for each lane, the end of its last element
+	for _, elem := range elems {
+		lane := len(laneEnds)
+		for l, end := range laneEnds {
+			if end < elem.Start {
+				lane = l
+				break
+			}
+		}
+		if lane == len(laneEnds) {
+			laneEnds = append(laneEnds, elem.End)
+		} else {
+			laneEnds[lane] = elem.End
+		}
+		elem.ClientId = lane
+	}
+}
+
+// assignAnnotationRows sets the Row of every tagged annotation so that
+// annotations with the same tag that overlap in time are in different rows of
+// the tag's lane, using as few rows as possible. Annotations without a tag are
+// drawn in a client's lane, and are left in row 0.
+func assignAnnotationRows(annotations []VisualizationAnnotation) {
+	byTag := make(map[string][]*VisualizationAnnotation)
+	for i := range annotations {
+		if annotations[i].Tag != "" {
+			byTag[annotations[i].Tag] = append(byTag[annotations[i].Tag], &annotations[i])
+		}
+	}
+	for _, annots := range byTag {
+		sort.SliceStable(annots, func(i, j int) bool {
+			return annots[i].Start < annots[j].Start
+		})
+		// for each row, the last annotation placed in it
+		var rowLast []*VisualizationAnnotation
+		for _, annot := range annots {
+			row := len(rowLast)
+			for r, last := range rowLast {
+				if last.End < annot.Start || (last.EndExclusive && last.End == annot.Start) {
+					row = r
+					break
+				}
+			}
+			if row == len(rowLast) {
+				rowLast = append(rowLast, annot)
+			} else {
+				rowLast[row] = annot
+			}
+			annot.Row = row
+		}
+	}
+}
+
+// formatRelativeTimestamps sets the StartTime and EndTime of every history
+// element to its Start and End relative to the earliest Start, formatted as
+// durations.
+func formatRelativeTimestamps(partitions []PartitionVisualizationData) {
+	origin := int64(math.MaxInt64)
+	for _, partition := range partitions {
+		for _, elem := range partition.History {
+			if elem.Start < origin {
+				origin = elem.Start
+			}
+		}
+	}
+	for p := range partitions {
+		for i := range partitions[p].History {
+			elem := &partitions[p].History[i]
+			elem.StartTime = formatRelativeTimestamp(elem.Start - origin)
+			elem.EndTime = formatRelativeTimestamp(elem.End - origin)
+		}
+	}
+}
+
+func formatRelativeTimestamp(delta int64) string {
+	return "+" + time.Duration(delta).String()
+}
+
+// rejectionReasons computes, for each operation that could be linearized next
+// after the given partial linearization, why the model rejects it.
+func rejectionReasons(model Model, history []HistoryElement, partial []int, state interface{}, callValue, returnValue map[int]interface{}) map[int]string {
+	included := make(map[int]struct{})
+	for _, id := range partial {
+		included[id] = struct{}{}
+	}
+	minEnd := int64(math.MaxInt64)
+	for id, elem := range history {
+		if _, ok := included[id]; !ok && elem.End < minEnd {
+			minEnd = elem.End
+		}
+	}
+	reasons := make(map[int]string)
+	for id, elem := range history {
+		if _, ok := included[id]; ok || elem.Start > minEnd {
+			continue
+		}
+		ok, _, reason := model.StepVerbose(state, callValue[id], returnValue[id])
+		if !ok && reason != "" {
+			reasons[id] = reason
+		}
+	}
+	return reasons
+}
+
 // Visualize produces a visualization of a history and (partial) linearization
 // as an HTML file that can be viewed in a web browser.
 //
@@ -176,8 +774,140 @@ func computeVisualizationData(model Model, info LinearizationInfo) visualization
 // This function writes the visualization, an HTML file with embedded
 // JavaScript and data, to the given output.
 func Visualize(model Model, info LinearizationInfo, output io.Writer) error {
-	data := computeVisualizationData(model, info)
-	jsonData, err := json.Marshal(data)
+	return VisualizeWithOptions(model, info, output, VisualizeOptions{})
+}
+
+// VisualizeWithOptions is like [Visualize], but it allows customizing the
+// visualization with the given options.
+func VisualizeWithOptions(model Model, info LinearizationInfo, output io.Writer, opts VisualizeOptions) error {
+	return writeVisualization(computeVisualizationData(model, info, opts), output)
+}
+
+// VisualizeHistory writes a visualization of a history, without checking it,
+// to output. The visualization shows the real-time concurrency structure of
+// the history, i.e., which operations overlap, with no partial
+// linearizations, and operations are described using describe, which may be
+// nil, in which case they are rendered using the "%v" format specifier.
+//
+// This is useful for inspecting the shape of a recorded history, e.g., to
+// debug timestamp issues, before running a check, and it doesn't need a
+// model.
+func VisualizeHistory(history []Operation, describe func(input, output interface{}) string, output io.Writer) error {
+	model := Model{
+		Init: func() interface{} { return nil },
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			return true, state
+		},
+		DescribeOperation: describe,
+	}
+	info := LinearizationInfo{
+		history:               [][]entry{makeEntries(history, 0)},
+		partialLinearizations: [][][]int{nil},
+	}
+	data := computeVisualizationData(model, info, VisualizeOptions{})
+	data.historyOnly = true
+	return writeVisualization(data, output)
+}
+
+// VisualizeCtx is like [Visualize], but it stops writing the visualization
+// if the context is canceled, returning the context's error.
+//
+// The visualization is written in chunks, and the context is checked before
+// each chunk, so this bounds how long the function can block on a slow
+// output, e.g., a file on a network file system, to roughly the time it
+// takes to write a single chunk; a single call to the output's Write method
+// can't be interrupted. If the context is canceled, the output may contain a
+// partial visualization.
+func VisualizeCtx(ctx context.Context, model Model, info LinearizationInfo, output io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data := computeVisualizationData(model, info, VisualizeOptions{})
+	return writeVisualization(data, contextWriter{ctx, output})
+}
+
+// visualizationChunkSize is the size of the chunks in which [VisualizeCtx]
+// writes its output.
+const visualizationChunkSize = 64 * 1024
+
+// contextWriter is a writer that splits writes into chunks and stops writing
+// when its context is canceled.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw contextWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if err := cw.ctx.Err(); err != nil {
+			return n, err
+		}
+		chunk := p
+		if len(chunk) > visualizationChunkSize {
+			chunk = chunk[:visualizationChunkSize]
+		}
+		m, err := cw.w.Write(chunk)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// visualizationJSON is the data that is passed to the renderer, which
+// includes some fields that are only used for rendering, e.g., to tell
+// annotations apart from operations.
+type visualizationJSON struct {
+	VisualizationData
+	Partitions   []partitionJSON
+	Annotations  []annotationJSON
+	ClientLabels []string `json:",omitempty"`
+	Dividers     []int    `json:",omitempty"`
+	HistoryOnly  bool     `json:",omitempty"`
+}
+
+type partitionJSON struct {
+	PartitionVisualizationData
+	History []historyElementJSON
+}
+
+type historyElementJSON struct {
+	HistoryElement
+	Differs bool `json:",omitempty"`
+}
+
+type annotationJSON struct {
+	VisualizationAnnotation
+	Annotation bool
+}
+
+func marshalVisualization(data VisualizationData) ([]byte, error) {
+	v := visualizationJSON{
+		VisualizationData: data,
+		Partitions:        make([]partitionJSON, len(data.Partitions)),
+		Annotations:       make([]annotationJSON, len(data.Annotations)),
+		ClientLabels:      data.clientLabels,
+		Dividers:          data.dividers,
+		HistoryOnly:       data.historyOnly,
+	}
+	for i, partition := range data.Partitions {
+		history := make([]historyElementJSON, len(partition.History))
+		for j, elem := range partition.History {
+			history[j] = historyElementJSON{elem, elem.differs}
+		}
+		v.Partitions[i] = partitionJSON{partition, history}
+	}
+	for i, annot := range data.Annotations {
+		v.Annotations[i] = annotationJSON{annot, true}
+	}
+	return json.Marshal(v)
+}
+
+func writeVisualization(data VisualizationData, output io.Writer) error {
+	jsonData, err := marshalVisualization(data)
 	if err != nil {
 		return err
 	}
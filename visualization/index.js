@@ -52,6 +52,141 @@ function arrayEq(a, b) {
   return true
 }
 
+function historyText(data, el) {
+  let text = el['Description']
+  if (el['Expected']) {
+    text += ` (expected ${el['Expected']})`
+  }
+  if (data['ShowIds'] && !el['Annotation']) {
+    return `[${el['Id'] || 0}] ${text}`
+  }
+  return text
+}
+
+function renderHeader(title, metadata) {
+  const header = document.getElementById('header')
+  if (title) {
+    document.title = title + ' - Porcupine'
+    const h1 = header.appendChild(document.createElement('h1'))
+    h1.textContent = title
+  }
+  const keys = Object.keys(metadata || {}).sort()
+  if (keys.length > 0) {
+    const table = header.appendChild(document.createElement('table'))
+    keys.forEach((key) => {
+      const row = table.appendChild(document.createElement('tr'))
+      row.appendChild(document.createElement('th')).textContent = key
+      row.appendChild(document.createElement('td')).textContent = metadata[key]
+    })
+  }
+  if (title || keys.length > 0) {
+    header.classList.remove('inactive')
+  }
+}
+
+function renderLegend(annotations, tagStyles) {
+  const ROW_HEIGHT = 20
+  const LABEL_X = 40
+  const entries = [
+    { shape: 'rect', class: 'history-rect', label: 'Operation, from call to return' },
+    {
+      shape: 'rect',
+      class: 'history-rect selected',
+      label: 'Selected operation (click to select)',
+    },
+    { shape: 'rect', class: 'client-annotation-rect', label: 'Annotation' },
+    {
+      shape: 'point',
+      class: 'linearization linearization-point',
+      label: 'Linearization point in the shown partial linearization',
+    },
+    {
+      shape: 'point',
+      class: 'linearization-invalid linearization-point',
+      label: 'Illegal next linearization point',
+    },
+    {
+      shape: 'divider',
+      class: 'divider',
+      label: 'Rows above the divider are clients, rows below are annotation tags',
+    },
+  ]
+  // user-defined tag styles, labeled by their label (or tag)
+  const styles = tagStyles || {}
+  const tags = Object.keys(styles).sort()
+  tags.forEach((tag) => {
+    const color = styles[tag]['BackgroundColor']
+    entries.push({
+      shape: 'rect',
+      class: 'client-annotation-rect',
+      style: color ? `fill: ${color};` : '',
+      label: styles[tag]['Label'] || tag,
+    })
+  })
+  // other user-defined annotation colors, labeled by the annotations that use
+  // them
+  const colors = new Map()
+  annotations.forEach((annot) => {
+    const color = annot['BackgroundColor']
+    const style = styles[annot['Tag']]
+    if (color.length === 0 || (style && style['BackgroundColor'] === color)) {
+      return
+    }
+    if (!colors.has(color)) {
+      colors.set(color, new Set())
+    }
+    colors.get(color).add(annot['Description'])
+  })
+  colors.forEach((descriptions, color) => {
+    entries.push({
+      shape: 'rect',
+      class: 'client-annotation-rect',
+      style: `fill: ${color};`,
+      label: Array.from(descriptions).join(', '),
+    })
+  })
+
+  const details = document.getElementById('legend-details')
+  const svg = svgadd(details, 'svg', { width: 800, height: entries.length * ROW_HEIGHT })
+  entries.forEach((entry, i) => {
+    const y = i * ROW_HEIGHT
+    switch (entry.shape) {
+      case 'rect':
+        svgadd(svg, 'rect', {
+          x: 5,
+          y: y + 3,
+          width: 25,
+          height: ROW_HEIGHT - 6,
+          rx: 2,
+          ry: 2,
+          class: entry.class,
+          style: entry.style || '',
+        })
+        break
+      case 'point':
+        svgadd(svg, 'line', { x1: 17, x2: 17, y1: y, y2: y + ROW_HEIGHT, class: entry.class })
+        break
+      case 'divider':
+        svgadd(svg, 'line', {
+          x1: 5,
+          x2: 30,
+          y1: y + ROW_HEIGHT / 2,
+          y2: y + ROW_HEIGHT / 2,
+          class: entry.class,
+        })
+        break
+    }
+    const text = svgadd(svg, 'text', { x: LABEL_X, y: y + ROW_HEIGHT / 2 })
+    text.textContent = entry.label
+  })
+
+  const link = document.getElementById('legend-link')
+  link.onclick = () => {
+    const hidden = details.classList.toggle('inactive')
+    link.textContent = hidden ? '[ show legend ]' : '[ hide legend ]'
+  }
+}
+
 function render(data) {
   const PADDING = 10
   const BOX_HEIGHT = 30
@@ -64,6 +199,8 @@ function render(data) {
 
   const annotations = data['Annotations']
   const coreHistory = data['Partitions']
+  renderHeader(data['Title'], data['Metadata'])
+  renderLegend(annotations, data['TagStyles'])
   // for simplicity, make annotations look like more history
   const allData = [...coreHistory, { History: annotations }]
 
@@ -83,21 +220,41 @@ function render(data) {
       tags.add(tag)
     }
   })
-  // add synthetic client numbers
+  // annotations within a tag are placed in sub-rows so that they don't
+  // overlap, so each tag may need several synthetic clients
+  const tagRows = {}
+  annotations.forEach((annot) => {
+    const tag = annot['Tag']
+    if (tag.length !== 0) {
+      tagRows[tag] = Math.max(tagRows[tag] || 0, (annot['Row'] || 0) + 1)
+    }
+  })
+  // add synthetic client numbers, labeling only the first row of each tag
   const tag2ClientId = {}
+  const tagLabels = []
   const sortedTags = Array.from(tags).sort()
   sortedTags.forEach((tag) => {
-    maxClient = maxClient + 1
-    tag2ClientId[tag] = maxClient
+    tag2ClientId[tag] = maxClient + 1
+    for (let row = 0; row < tagRows[tag]; row++) {
+      tagLabels.push(row === 0 ? tag : '')
+    }
+    maxClient = maxClient + tagRows[tag]
   })
   annotations.forEach((annot) => {
     const tag = annot['Tag']
     if (tag.length !== 0) {
-      annot['ClientId'] = tag2ClientId[tag]
+      annot['ClientId'] = tag2ClientId[tag] + (annot['Row'] || 0)
     }
   })
   // total number of clients now includes these synthetic clients
   const nClient = maxClient + 1
+  const clientLabels = data['ClientLabels'] || []
+  function clientLabel(i) {
+    if (i >= realClients) {
+      return tagLabels[i - realClients]
+    }
+    return i < clientLabels.length ? clientLabels[i] : i.toString()
+  }
 
   // Prepare some useful data to be used later:
   // - Add a GID to each event
@@ -126,21 +283,38 @@ function render(data) {
   // time. We solve this by tweaking the events that share the end time,
   // updating the time to end+epsilon. In practice, rather than having to
   // choose an epsilon, we choose to average the value with the next largest
-  // timestamp.
+  // timestamp. If there is no larger timestamp (e.g., a zero-duration
+  // operation at the very end of the history, or a history where all
+  // timestamps are the same), we use end+1; the layout only depends on the
+  // order of timestamps, so this still gives the event a visible width.
+  //
+  // Annotations with an exclusive end are instead tweaked to end just before
+  // their end time, by averaging it with the next smallest timestamp, so
+  // they end before anything that starts at that time.
   const nextTs = {}
+  const prevTs = {}
   for (let i = 0; i < sortedTimestamps.length - 1; i++) {
     nextTs[sortedTimestamps[i]] = sortedTimestamps[i + 1]
+    prevTs[sortedTimestamps[i + 1]] = sortedTimestamps[i]
   }
   allData.forEach((partition) => {
     partition['History'].forEach((el) => {
       let end = el['End']
       el['OriginalEnd'] = end // for display purposes
-      if (startTimestamps.has(end)) {
+      if (el['EndExclusive']) {
+        // the start is an earlier timestamp, so prevTs[end] exists
+        const tweaked = (prevTs[end] + end) / 2
+        el['End'] = tweaked
+        allTimestamps.add(tweaked)
+      } else if (startTimestamps.has(end)) {
+        let tweaked
         if (Object.prototype.hasOwnProperty.call(nextTs, end)) {
-          const tweaked = (end + nextTs[end]) / 2
-          el['End'] = tweaked
-          allTimestamps.add(tweaked)
+          tweaked = (end + nextTs[end]) / 2
+        } else {
+          tweaked = end + 1
         }
+        el['End'] = tweaked
+        allTimestamps.add(tweaked)
       }
     })
   })
@@ -195,7 +369,7 @@ function render(data) {
           'text-anchor': 'middle',
           class: 'history-text',
         })
-        text.textContent = el['Description']
+        text.textContent = historyText(data, el)
         const bbox = text.getBBox()
         const width = bbox.width + 2 * BOX_TEXT_PADDING
         return {
@@ -308,7 +482,7 @@ function render(data) {
   // get maximum tag width
   let maxTagWidth = 0
   for (let i = 0; i < nClient; i++) {
-    const tag = i < realClients ? i.toString() : sortedTags[i - realClients]
+    const tag = clientLabel(i)
     const scratch = document.getElementById('calc')
     scratch.innerHTML = ''
     const svg = svgadd(scratch, 'svg')
@@ -353,7 +527,7 @@ function render(data) {
       y: PADDING + BOX_HEIGHT / 2 + i * (BOX_HEIGHT + BOX_SPACE),
       'text-anchor': 'end',
     })
-    text.textContent = i < realClients ? i : sortedTags[i - realClients]
+    text.textContent = clientLabel(i)
   }
   // vertical line at t=0
   svgadd(bg, 'line', {
@@ -375,6 +549,19 @@ function render(data) {
     })
   }
 
+  // horizontal lines dividing groups of clients, spanning the whole history
+  const dividers = data['Dividers'] || []
+  dividers.forEach((client) => {
+    const y = PADDING + client * (BOX_HEIGHT + BOX_SPACE) - BOX_SPACE / 2
+    svgadd(bg, 'line', {
+      x1: PADDING,
+      y1: y,
+      x2: width - PADDING,
+      y2: y,
+      class: 'divider',
+    })
+  })
+
   // draw history
   const historyLayers = []
   const historyRects = []
@@ -389,7 +576,13 @@ function render(data) {
       const width = xPos[el['End']] - rx
       const x = rx + t0x
       const y = PADDING + el['ClientId'] * (BOX_HEIGHT + BOX_SPACE)
-      const rectClass = el['Annotation'] ? 'client-annotation-rect' : 'history-rect'
+      let rectClass = el['Annotation'] ? 'client-annotation-rect' : 'history-rect'
+      if (el['Differs']) {
+        rectClass += ' history-rect-differs'
+      }
+      if (el['Mismatch']) {
+        rectClass += ' history-rect-mismatch'
+      }
       rects.push(
         svgadd(g, 'rect', {
           height: BOX_HEIGHT,
@@ -412,7 +605,7 @@ function render(data) {
         class: 'history-text',
         style: el['Annotation'] && el['TextColor'].length !== 0 ? `fill: ${el['TextColor']};` : '',
       })
-      text.textContent = el['Description']
+      text.textContent = historyText(data, el)
       // we don't add mouseTarget to g, but to targetRects, because we
       // want to layer this on top of everything at the end; otherwise, the
       // LPs and lines will be over the target, which will create holes
@@ -455,6 +648,7 @@ function render(data) {
       let prevX = null
       let prevY = null
       let prevEl = null
+      let prevStep = null
       const included = new Set()
       lin.forEach((id) => {
         const el = partition['History'][id['Index']]
@@ -463,13 +657,25 @@ function render(data) {
         const y = PADDING + el['ClientId'] * (BOX_HEIGHT + BOX_SPACE) - LINE_BLEED
         // line from previous
         if (prevEl !== null) {
+          const y1 = prevEl['ClientId'] >= el['ClientId'] ? prevY : prevY + BOX_HEIGHT + 2 * LINE_BLEED
+          const y2 = prevEl['ClientId'] <= el['ClientId'] ? y : y + BOX_HEIGHT + 2 * LINE_BLEED
           svgadd(g, 'line', {
             x1: prevX,
             x2: x,
-            y1: prevEl['ClientId'] >= el['ClientId'] ? prevY : prevY + BOX_HEIGHT + 2 * LINE_BLEED,
-            y2: prevEl['ClientId'] <= el['ClientId'] ? y : y + BOX_HEIGHT + 2 * LINE_BLEED,
+            y1: y1,
+            y2: y2,
             class: 'linearization linearization-line',
           })
+          // state between previous and current
+          if (data['ShowStates']) {
+            const text = svgadd(g, 'text', {
+              x: (prevX + x) / 2,
+              y: (y1 + y2) / 2 - LINE_BLEED,
+              'text-anchor': 'middle',
+              class: 'linearization-state',
+            })
+            text.textContent = prevStep['StateDescription']
+          }
         }
         // current line
         svgadd(g, 'line', {
@@ -482,6 +688,7 @@ function render(data) {
         prevX = x
         prevY = y
         prevEl = el
+        prevStep = id
         included.add(id['Index'])
       })
       // show possible but illegal next linearizations
@@ -607,6 +814,12 @@ function render(data) {
         // annotation
         const details = annotations[index]['Details']
         tooltip.innerHTML = details.length === 0 ? '&langle;no details&rangle;' : details
+      } else if (data['HistoryOnly']) {
+        // no linearizations to show
+        const el = allData[partition]['History'][index]
+        const call = el['StartTime'] || el['Start']
+        const ret = el['EndTime'] || el['OriginalEnd']
+        tooltip.innerHTML = el['Description'] + '<br><br>Call: ' + call + '<br><br>Return: ' + ret
       } else if (selected && sPartition !== partition) {
         tooltip.innerHTML = 'Not part of selected partition.'
       } else if (maxIndex === null) {
@@ -628,35 +841,47 @@ function render(data) {
             break
           }
         }
-        let call = allData[partition]['History'][index]['Start']
-        let ret = allData[partition]['History'][index]['OriginalEnd']
+        const el = allData[partition]['History'][index]
+        let call = el['StartTime'] || el['Start']
+        let ret = el['EndTime'] || el['OriginalEnd']
         let msg = ''
         if (found) {
           // part of linearization
           if (prev !== null) {
             msg = '<strong>Previous state:</strong><br>' + prev['StateDescription'] + '<br><br>'
           }
-          msg +=
-            '<strong>New state:</strong><br>' +
-            curr['StateDescription'] +
-            '<br><br>Call: ' +
-            call +
-            '<br><br>Return: ' +
-            ret
+          msg += '<strong>New state:</strong><br>' + curr['StateDescription'] + '<br><br>'
+          if (curr['TransitionDescription']) {
+            msg += '<strong>Change:</strong><br>' + curr['TransitionDescription'] + '<br><br>'
+          }
+          msg += 'Call: ' + call + '<br><br>Return: ' + ret
         } else if (illegalLast[partition][maxIndex].has(index)) {
           // illegal next one
           msg =
             '<strong>Previous state:</strong><br>' +
             lin[lin.length - 1]['StateDescription'] +
-            '<br><br><strong>New state:</strong><br>&langle;invalid op&rangle;' +
-            '<br><br>Call: ' +
-            call +
-            '<br><br>Return: ' +
-            ret
+            '<br><br><strong>New state:</strong><br>&langle;invalid op&rangle;'
+          const rejections = coreHistory[partition]['Rejections']
+          if (rejections && Object.prototype.hasOwnProperty.call(rejections[maxIndex], index)) {
+            msg += '<br><br><strong>Rejected:</strong><br>' + rejections[maxIndex][index]
+          }
+          msg += '<br><br>Call: ' + call + '<br><br>Return: ' + ret
         } else {
           // not part of this one
           msg = "Not part of selected element's partial linearization."
         }
+        const metadata = el['Metadata']
+        if (metadata) {
+          Object.keys(metadata)
+            .sort()
+            .forEach((key) => {
+              msg += '<br><br><strong>' + key + ':</strong> ' + metadata[key]
+            })
+        }
+        const label = coreHistory[partition]['Label']
+        if (label) {
+          msg = '<strong>Partition:</strong> ' + label + '<br><br>' + msg
+        }
         tooltip.innerHTML = msg
       }
       lastTooltip = thisTooltip
@@ -1,8 +1,13 @@
 package porcupine
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"os"
+	"os/exec"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -34,9 +39,9 @@ func TestVisualizationMultipleLengths(t *testing.T) {
 	if res != Illegal {
 		t.Fatalf("expected output %v, got output %v", Illegal, res)
 	}
-	data := computeVisualizationData(kvModel, info)
-	expected := []partitionVisualizationData{{
-		History: []historyElement{
+	data := ComputeVisualizationData(kvModel, info)
+	expected := []PartitionVisualizationData{{
+		History: []HistoryElement{
 			{ClientId: 0, Start: 0, End: 100, Description: "get('x') -> 'w'"},
 			{ClientId: 1, Start: 5, End: 10, Description: "put('x', 'y')"},
 			{ClientId: 2, Start: 0, End: 10, Description: "put('x', 'z')"},
@@ -45,18 +50,18 @@ func TestVisualizationMultipleLengths(t *testing.T) {
 			{ClientId: 5, Start: 25, End: 35, Description: "get('x') -> 'z'"},
 			{ClientId: 3, Start: 30, End: 40, Description: "get('x') -> 'y'"},
 		},
-		PartialLinearizations: []partialLinearization{
-			{{2, "z"}, {1, "y"}, {3, "y"}, {6, "y"}, {4, "w"}, {0, "w"}},
-			{{1, "y"}, {2, "z"}, {5, "z"}},
+		PartialLinearizations: [][]LinearizationStep{
+			{{Index: 2, StateDescription: "z"}, {Index: 1, StateDescription: "y"}, {Index: 3, StateDescription: "y"}, {Index: 6, StateDescription: "y"}, {Index: 4, StateDescription: "w"}, {Index: 0, StateDescription: "w"}},
+			{{Index: 1, StateDescription: "y"}, {Index: 2, StateDescription: "z"}, {Index: 5, StateDescription: "z"}},
 		},
 		Largest: map[int]int{0: 0, 1: 0, 2: 0, 3: 0, 4: 0, 5: 1, 6: 0},
 	}, {
-		History: []historyElement{
+		History: []HistoryElement{
 			{ClientId: 4, Start: 50, End: 90, Description: "get('y') -> 'a'"},
 			{ClientId: 2, Start: 55, End: 85, Description: "put('y', 'a')"},
 		},
-		PartialLinearizations: []partialLinearization{
-			{{1, "a"}, {0, "a"}},
+		PartialLinearizations: [][]LinearizationStep{
+			{{Index: 1, StateDescription: "a"}, {Index: 0, StateDescription: "a"}},
 		},
 		Largest: map[int]int{0: 0, 1: 0},
 	}}
@@ -119,7 +124,7 @@ func TestRegisterModelReadme(t *testing.T) {
 }
 
 func TestVisualizationLarge(t *testing.T) {
-	events := parseJepsenLog("test_data/jepsen/etcd_070.log")
+	events := parseJepsenLog(t, "test_data/jepsen/etcd_070.log")
 	res, info := CheckEventsVerbose(etcdModel, events, 0)
 	if res != Illegal {
 		t.Fatal("expected operations not to be linearizable")
@@ -163,3 +168,818 @@ func TestVisualizationAnnotations(t *testing.T) {
 	// we don't check much else here, this has to be visually inspected
 	visualizeTempFile(t, kvModel, info)
 }
+
+func TestVisualizationAnnotationsJSON(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	input := `[
+		{"Tag": "Server 1", "Start": 5, "Description": "leader"},
+		{"ClientId": 2, "Start": 12, "End": 25, "Description": "get('x') timeout", "BackgroundColor": "#ff9191"}
+	]`
+	err := info.AddAnnotationsJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("failed to add annotations: %v", err)
+	}
+	expected := []VisualizationAnnotation{
+		{Tag: "Server 1", Start: 5, End: 5, Description: "leader"},
+		{ClientId: 2, Start: 12, End: 25, Description: "get('x') timeout", BackgroundColor: "#ff9191"},
+	}
+	if !reflect.DeepEqual(expected, info.annotations) {
+		t.Fatalf("expected annotations to be \n%v\n, was \n%v", expected, info.annotations)
+	}
+
+	err = info.AddAnnotationsJSON(strings.NewReader(`{"Tag": "not an array"}`))
+	if err == nil {
+		t.Fatal("expected error decoding malformed annotations")
+	}
+}
+
+func TestVisualizationShowIds(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30},
+		{2, kvInput{op: 0, key: "x"}, 5, kvOutput{""}, 15},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(kvModel, info, VisualizeOptions{ShowIds: true})
+	if !data.ShowIds {
+		t.Fatal("expected ShowIds to be set")
+	}
+	for i, elem := range data.Partitions[0].History {
+		if elem.Id != i {
+			t.Fatalf("expected element %d to have id %d, was %d", i, i, elem.Id)
+		}
+	}
+	file, err := os.CreateTemp("", "*.html")
+	if err != nil {
+		t.Fatalf("failed to create temp file")
+	}
+	err = VisualizeWithOptions(kvModel, info, file, VisualizeOptions{ShowIds: true})
+	if err != nil {
+		t.Fatalf("visualization failed")
+	}
+	t.Logf("wrote visualization to %s", file.Name())
+}
+
+func TestVisualizationShowStates(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 2, key: "x", value: "z"}, 20, kvOutput{}, 30},
+		{2, kvInput{op: 0, key: "x"}, 40, kvOutput{"yz"}, 50},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(kvModel, info, VisualizeOptions{ShowStates: true})
+	if !data.ShowStates {
+		t.Fatal("expected ShowStates to be set")
+	}
+	var b bytes.Buffer
+	err := VisualizeWithOptions(kvModel, info, &b, VisualizeOptions{ShowStates: true})
+	if err != nil {
+		t.Fatalf("visualization failed")
+	}
+	if !strings.Contains(b.String(), `"ShowStates":true`) {
+		t.Fatal("expected visualization data to enable ShowStates")
+	}
+}
+
+func TestVisualizationAutoLanes(t *testing.T) {
+	// no client ids
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{0, kvInput{op: 0, key: "x"}, 5, kvOutput{""}, 15},
+		{0, kvInput{op: 1, key: "z", value: "w"}, 12, kvOutput{}, 30},
+		{0, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 25},
+		{0, kvInput{op: 0, key: "z"}, 31, kvOutput{"w"}, 40},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(kvModel, info, VisualizeOptions{AutoLanes: true})
+	lanes := make(map[int64]int) // start -> lane
+	for _, partition := range data.Partitions {
+		for _, elem := range partition.History {
+			lanes[elem.Start] = elem.ClientId
+		}
+	}
+	expected := map[int64]int{0: 0, 5: 1, 12: 0, 20: 1, 31: 0}
+	if !reflect.DeepEqual(expected, lanes) {
+		t.Fatalf("expected lanes %v, got %v", expected, lanes)
+	}
+	file, err := os.CreateTemp("", "*.html")
+	if err != nil {
+		t.Fatalf("failed to create temp file")
+	}
+	err = VisualizeWithOptions(kvModel, info, file, VisualizeOptions{AutoLanes: true})
+	if err != nil {
+		t.Fatalf("visualization failed")
+	}
+	t.Logf("wrote visualization to %s", file.Name())
+}
+
+func TestVisualizationOnlyLargest(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 0, key: "x"}, 0, kvOutput{"w"}, 100},
+		{1, kvInput{op: 1, key: "x", value: "y"}, 5, kvOutput{}, 10},
+		{2, kvInput{op: 1, key: "x", value: "z"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30},
+		{1, kvInput{op: 1, key: "x", value: "w"}, 35, kvOutput{}, 45},
+		{5, kvInput{op: 0, key: "x"}, 25, kvOutput{"z"}, 35},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	all := computeVisualizationData(kvModel, info, VisualizeOptions{})
+	if len(all.Partitions[0].PartialLinearizations) < 2 {
+		t.Fatal("expected multiple partial linearizations")
+	}
+	data := ComputeVisualizationDataWithOptions(kvModel, info, VisualizeOptions{OnlyLargest: true})
+	partials := data.Partitions[0].PartialLinearizations
+	if len(partials) != 1 || len(partials[0]) != len(all.Partitions[0].PartialLinearizations[0]) {
+		t.Fatalf("expected only the largest partial linearization, got %v", partials)
+	}
+	for id, index := range data.Partitions[0].Largest {
+		if index != 0 {
+			t.Fatalf("expected operation %d to refer to the largest partial linearization, got %d", id, index)
+		}
+	}
+	file, err := os.CreateTemp("", "*.html")
+	if err != nil {
+		t.Fatalf("failed to create temp file")
+	}
+	err = VisualizeWithOptions(kvModel, info, file, VisualizeOptions{OnlyLargest: true})
+	if err != nil {
+		t.Fatalf("visualization failed")
+	}
+	t.Logf("wrote visualization to %s", file.Name())
+}
+
+func TestVisualizationExpectedOutput(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+		{0, registerInput{false, 2}, 40, 0, 50},
+		{1, registerInput{true, 0}, 60, 1, 70},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	opts := VisualizeOptions{
+		ExpectedOutput: func(state, input interface{}) interface{} {
+			if input.(registerInput).op {
+				return state
+			}
+			return 0
+		},
+	}
+	data := ComputeVisualizationDataWithOptions(registerModel, info, opts)
+	type expected struct {
+		description string
+		mismatch    bool
+	}
+	got := make(map[int64]expected) // start -> expected
+	for _, elem := range data.Partitions[0].History {
+		got[elem.Start] = expected{elem.Expected, elem.Mismatch}
+	}
+	// the last read isn't part of any partial linearization, so it's
+	// compared against the state where the check got stuck
+	want := map[int64]expected{
+		0:  {"put('1')", false},
+		20: {"get() -> '1'", false},
+		40: {"put('2')", false},
+		60: {"get() -> '2'", true},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	file, err := os.CreateTemp("", "*.html")
+	if err != nil {
+		t.Fatalf("failed to create temp file")
+	}
+	err = VisualizeWithOptions(registerModel, info, file, opts)
+	if err != nil {
+		t.Fatalf("visualization failed")
+	}
+	t.Logf("wrote visualization to %s", file.Name())
+}
+
+func TestOperationMetadata(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 5, kvOutput{"y"}, 20},
+		{0, kvInput{op: 0, key: "y"}, 15, kvOutput{""}, 25},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	info.AddOperationMetadata([]OperationMetadata{
+		{ClientId: 0, Call: 0, Metadata: map[string]string{"server": "s1"}},
+		{ClientId: 1, Call: 5, Metadata: map[string]string{"server": "s2", "retries": "2"}},
+		{ClientId: 0, Call: 0, Metadata: map[string]string{"latency": "10ms"}},
+		{ClientId: 1, Call: 15, Metadata: map[string]string{"server": "s3"}},
+	})
+	data := computeVisualizationData(kvModel, info, VisualizeOptions{AutoLanes: true})
+	metadata := make(map[int64]map[string]string) // start -> metadata
+	for _, partition := range data.Partitions {
+		for _, elem := range partition.History {
+			metadata[elem.Start] = elem.Metadata
+		}
+	}
+	expected := map[int64]map[string]string{
+		0:  {"server": "s1", "latency": "10ms"},
+		5:  {"server": "s2", "retries": "2"},
+		15: nil,
+	}
+	if !reflect.DeepEqual(expected, metadata) {
+		t.Fatalf("expected metadata %v, got %v", expected, metadata)
+	}
+	visualizeTempFile(t, kvModel, info)
+
+	// widened operations are still matched by their Call time in the
+	// history
+	res, info = CheckOperationsOptions(kvModel, ops, CheckOptions{Verbose: true, TimestampEpsilon: 4})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	info.AddOperationMetadata([]OperationMetadata{
+		{ClientId: 1, Call: 5, Metadata: map[string]string{"server": "s2"}},
+	})
+	data = computeVisualizationData(kvModel, info, VisualizeOptions{})
+	if elem := data.Partitions[0].History[1]; elem.Start != 3 || elem.Metadata["server"] != "s2" {
+		t.Fatalf("expected metadata to match the widened operation, got %+v", elem)
+	}
+}
+
+func TestAnnotationEndExclusive(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{0, kvInput{op: 0, key: "x"}, 10, kvOutput{"y"}, 20},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	// annotations that describe the operations' intervals, where the
+	// exclusive one ends before the second operation starts
+	info.AddAnnotations([]Annotation{
+		{Tag: "Closed", Start: 0, End: 10, Description: "[0, 10]"},
+		{Tag: "Exclusive", Start: 0, End: 10, Description: "[0, 10)", EndExclusive: true},
+		{Tag: "Exclusive", Start: 20, Description: "point", EndExclusive: true},
+	})
+	expected := []VisualizationAnnotation{
+		{Tag: "Closed", Start: 0, End: 10, Description: "[0, 10]"},
+		{Tag: "Exclusive", Start: 0, End: 10, Description: "[0, 10)", EndExclusive: true},
+		{Tag: "Exclusive", Start: 20, End: 20, Description: "point"},
+	}
+	if !reflect.DeepEqual(expected, info.annotations) {
+		t.Fatalf("expected annotations to be \n%v\n, was \n%v", expected, info.annotations)
+	}
+	visualizeTempFile(t, kvModel, info)
+}
+
+func TestAnnotationRows(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 100},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	info.AddAnnotations([]Annotation{
+		{Tag: "Test Framework", Start: 0, End: 50, Description: "a"},
+		{Tag: "Test Framework", Start: 10, End: 20, Description: "b"},
+		{Tag: "Test Framework", Start: 20, End: 30, Description: "c"},
+		{Tag: "Test Framework", Start: 30, End: 40, Description: "d", EndExclusive: true},
+		{Tag: "Test Framework", Start: 40, End: 60, Description: "e"},
+		{Tag: "Test Framework", Start: 55, Description: "f"},
+		{Tag: "Server 1", Start: 10, End: 20, Description: "g"},
+		{ClientId: 0, Start: 10, End: 20, Description: "h"},
+	})
+	data := computeVisualizationData(kvModel, info, VisualizeOptions{})
+	rows := make(map[string]int)
+	for _, annot := range data.Annotations {
+		rows[annot.Description] = annot.Row
+	}
+	// b and c overlap at 20, d ends before e starts, and f goes back in a's
+	// row because a has ended; annotations without a tag aren't stacked
+	expected := map[string]int{"a": 0, "b": 1, "c": 2, "d": 1, "e": 1, "f": 0, "g": 0, "h": 0}
+	if !reflect.DeepEqual(expected, rows) {
+		t.Fatalf("expected rows %v, got %v", expected, rows)
+	}
+	if info.annotations[1].Row != 0 {
+		t.Fatal("expected rows not to be assigned in the LinearizationInfo")
+	}
+	visualizeTempFile(t, kvModel, info)
+}
+
+func TestVisualizationTitleMetadata(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 100},
+		{1, registerInput{true, 0}, 25, 100, 75},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	opts := VisualizeOptions{
+		Title:    "TestVisualizationTitleMetadata",
+		Metadata: map[string]string{"result": string(res), "commit": "0123abc"},
+	}
+	var b bytes.Buffer
+	if err := VisualizeWithOptions(registerModel, info, &b, opts); err != nil {
+		t.Fatalf("visualization failed")
+	}
+	if !strings.Contains(b.String(), `"Title":"TestVisualizationTitleMetadata","Metadata":{"commit":"0123abc","result":"Ok"}`) {
+		t.Fatal("expected visualization data to contain title and metadata")
+	}
+	file, err := os.CreateTemp("", "*.html")
+	if err != nil {
+		t.Fatalf("failed to create temp file")
+	}
+	if _, err := b.WriteTo(file); err != nil {
+		t.Fatalf("failed to write visualization")
+	}
+	t.Logf("wrote visualization to %s", file.Name())
+}
+
+func TestVisualizationRelativeTimestamps(t *testing.T) {
+	start := int64(1739938076171778000)
+	ops := []Operation{
+		{0, registerInput{false, 100}, start + 5000, 0, start + 1205000},
+		{1, registerInput{true, 0}, start, 0, start + 2000000},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(registerModel, info, VisualizeOptions{RelativeTimestamps: true})
+	times := make(map[int64][2]string) // start -> formatted start and end
+	for _, partition := range data.Partitions {
+		for _, elem := range partition.History {
+			times[elem.Start] = [2]string{elem.StartTime, elem.EndTime}
+		}
+	}
+	expected := map[int64][2]string{
+		start:        {"+0s", "+2ms"},
+		start + 5000: {"+5µs", "+1.205ms"},
+	}
+	if !reflect.DeepEqual(expected, times) {
+		t.Fatalf("expected times %v, got %v", expected, times)
+	}
+	file, err := os.CreateTemp("", "*.html")
+	if err != nil {
+		t.Fatalf("failed to create temp file")
+	}
+	err = VisualizeWithOptions(registerModel, info, file, VisualizeOptions{RelativeTimestamps: true})
+	if err != nil {
+		t.Fatalf("visualization failed")
+	}
+	t.Logf("wrote visualization to %s", file.Name())
+}
+
+func TestAddStateAnnotations(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 100},
+		{1, registerInput{true, 0}, 25, 100, 75},
+		{1, registerInput{false, 200}, 110, 0, 120},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	info.AddStateAnnotations(registerModel, []int64{-1, 5, 80, 115, 130})
+	var states []string
+	for _, a := range info.annotations {
+		if a.Tag != "State" {
+			t.Fatalf("unexpected tag %q", a.Tag)
+		}
+		states = append(states, fmt.Sprintf("%d:%s", a.Start, a.Description))
+	}
+	expected := []string{"-1:0", "5:100", "80:100", "115:200", "130:200"}
+	if !reflect.DeepEqual(expected, states) {
+		t.Fatalf("expected states %v, got %v", expected, states)
+	}
+	visualizeTempFile(t, registerModel, info)
+
+	// the state is unknown after an operation that can't be linearized
+	ops[1].Output = 300
+	res, info = CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	info.AddStateAnnotations(registerModel, []int64{5, 80})
+	if len(info.annotations) != 1 || info.annotations[0].Start != 5 || info.annotations[0].Description != "100" {
+		t.Fatalf("expected a single annotation at time 5, got %+v", info.annotations)
+	}
+}
+
+func TestAnnotateState(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+	}
+	res, info := CheckOperationsVerbose(kvNoPartitionModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	info.AnnotateState(kvNoPartitionModel, Annotation{Tag: "Replay", Start: 20}, map[string]string{"x": "y"})
+	info.AnnotateState(kvNoPartitionModel, Annotation{Tag: "Replay", Start: 30, Description: "after"}, map[string]string{})
+	expected := []VisualizationAnnotation{
+		{Tag: "Replay", Start: 20, End: 20, Description: "map[x:y]", Details: "map[x:y]"},
+		{Tag: "Replay", Start: 30, End: 30, Description: "after", Details: "map[]"},
+	}
+	if !reflect.DeepEqual(expected, info.annotations) {
+		t.Fatalf("expected annotations to be \n%v\n, was \n%v", expected, info.annotations)
+	}
+	visualizeTempFile(t, kvNoPartitionModel, info)
+}
+
+// cancelingWriter cancels a context after its first write
+type cancelingWriter struct {
+	cancel context.CancelFunc
+	writes int
+	bytes  int
+}
+
+func (w *cancelingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.bytes += len(p)
+	w.cancel()
+	return len(p), nil
+}
+
+func TestVisualizeCtx(t *testing.T) {
+	events := parseKvLog(t, "test_data/kv/c10-ok.txt")
+	res, info := CheckEventsVerbose(kvModel, events, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	var b bytes.Buffer
+	if err := VisualizeCtx(context.Background(), kvModel, info, &b); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	if b.Len() <= visualizationChunkSize {
+		t.Fatalf("expected visualization to be larger than a chunk, got %d bytes", b.Len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &cancelingWriter{cancel: cancel}
+	if err := VisualizeCtx(ctx, kvModel, info, w); err != context.Canceled {
+		t.Fatalf("expected error %v, got %v", context.Canceled, err)
+	}
+	if w.writes != 1 || w.bytes != visualizationChunkSize {
+		t.Fatalf("expected a single chunk to be written, got %d writes of %d bytes", w.writes, w.bytes)
+	}
+
+	// a context that's already canceled
+	w = &cancelingWriter{cancel: func() {}}
+	if err := VisualizeCtx(ctx, kvModel, info, w); err != context.Canceled {
+		t.Fatalf("expected error %v, got %v", context.Canceled, err)
+	}
+	if w.writes != 0 {
+		t.Fatalf("expected nothing to be written, got %d writes", w.writes)
+	}
+}
+
+func TestVisualizationRejectionReasons(t *testing.T) {
+	model := registerModel
+	model.Step = nil
+	model.StepVerbose = func(state, input, output interface{}) (bool, interface{}, string) {
+		regInput := input.(registerInput)
+		if regInput.op == false {
+			return true, regInput.value, ""
+		}
+		if output != state {
+			return false, state, fmt.Sprintf("expected value %d, got %d", state, output)
+		}
+		return true, state, ""
+	}
+	ops := []Operation{
+		{0, registerInput{false, 200}, 0, 0, 100},
+		{1, registerInput{true, 0}, 10, 200, 30},
+		{2, registerInput{true, 0}, 40, 0, 90},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	data := computeVisualizationData(model, info, VisualizeOptions{})
+	partition := data.Partitions[0]
+	// the longest partial linearization is put(200), get() -> 200, after
+	// which get() -> 0 is rejected
+	expected := map[int]string{2: "expected value 200, got 0"}
+	if !reflect.DeepEqual(expected, partition.Rejections[0]) {
+		t.Fatalf("expected rejections to be %v, was %v", expected, partition.Rejections[0])
+	}
+	visualizeTempFile(t, model, info)
+}
+
+func TestVisualizationZeroTimestamps(t *testing.T) {
+	// all operations are concurrent, and have zero duration; this has to
+	// be visually inspected to make sure that every operation is visible
+	ops := []Operation{
+		{0, registerInput{false, 100}, 0, 0, 0},
+		{1, registerInput{true, 0}, 0, 100, 0},
+		{2, registerInput{true, 0}, 0, 0, 0},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	info.AddAnnotations([]Annotation{
+		{Tag: "Test Framework", Start: 0, Description: "start"},
+	})
+	visualizeTempFile(t, registerModel, info)
+}
+
+func TestVisualizationDescribeTransition(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+		{0, registerInput{false, 2}, 40, 0, 50},
+	}
+	model := registerModel
+	model.DescribeTransition = func(before, after, input, output interface{}) string {
+		if before == after {
+			return "unchanged"
+		}
+		return fmt.Sprintf("%v -> %v", before, after)
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := ComputeVisualizationData(model, info)
+	var got []string
+	for _, step := range data.Partitions[0].PartialLinearizations[0] {
+		got = append(got, step.TransitionDescription)
+	}
+	want := []string{"0 -> 1", "unchanged", "1 -> 2"}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	visualizeTempFile(t, model, info)
+}
+
+func TestTagStyle(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+	}
+	_, info := CheckOperationsVerbose(registerModel, ops, 0)
+	info.AddAnnotations([]Annotation{
+		{Tag: "leader", Start: 0, Description: "elected"},
+		{Tag: "leader", Start: 5, Description: "stepped down", BackgroundColor: "#ff0000"},
+		{Tag: "other", Start: 5, Description: "unstyled"},
+	})
+	info.SetTagStyle("leader", "#efaefc", "", "Leader changes")
+	data := ComputeVisualizationData(registerModel, info)
+	var colors []string
+	for _, annot := range data.Annotations {
+		colors = append(colors, annot.BackgroundColor)
+	}
+	if want := []string{"#efaefc", "#ff0000", ""}; !reflect.DeepEqual(want, colors) {
+		t.Fatalf("expected colors %v, got %v", want, colors)
+	}
+	want := map[string]TagStyle{"leader": {BackgroundColor: "#efaefc", Label: "Leader changes"}}
+	if !reflect.DeepEqual(want, data.TagStyles) {
+		t.Fatalf("expected tag styles %v, got %v", want, data.TagStyles)
+	}
+	visualizeTempFile(t, registerModel, info)
+}
+
+// legendStub is a minimal DOM for running renderLegend in node, which prints
+// the labels of the legend's entries.
+const legendStub = `
+function element() {
+  return {
+    children: [],
+    classList: { toggle() {} },
+    setAttributeNS(ns, k, v) { this[k] = v },
+    appendChild(child) { this.children.push(child); return child },
+  }
+}
+const root = element()
+const document = {
+  createElementNS() { return element() },
+  getElementById() { return root },
+}
+`
+
+func TestTagStyleLegend(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found")
+	}
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+	}
+	_, info := CheckOperationsVerbose(registerModel, ops, 0)
+	info.AddAnnotations([]Annotation{
+		{Tag: "leader", Start: 0, Description: "elected"},
+		{Tag: "other", Start: 5, Description: "restart", BackgroundColor: "#ff0000"},
+	})
+	info.SetTagStyle("leader", "#efaefc", "", "Leader changes")
+	data, err := marshalVisualization(ComputeVisualizationData(registerModel, info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, _ := visualizationFS.ReadFile("visualization/index.js")
+	script := legendStub + string(js) + fmt.Sprintf(`
+const data = %s
+renderLegend(data['Annotations'], data['TagStyles'])
+root.children[0].children.forEach((el) => { if (el.textContent) console.log(el.textContent) })
+`, data)
+	cmd := exec.Command(node, "-")
+	cmd.Stdin = strings.NewReader(script)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("node failed: %v\n%s", err, out)
+	}
+	labels := strings.Split(strings.TrimSpace(string(out)), "\n")
+	// the styled tag is labeled by its style rather than by its
+	// annotations' descriptions
+	if got := labels[len(labels)-2:]; !reflect.DeepEqual([]string{"Leader changes", "restart"}, got) {
+		t.Fatalf("expected legend to end with the tag style's label, got %v", labels)
+	}
+}
+
+func TestVisualizationEqualOutput(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+	}
+	// the expected output has a different type than the observed one
+	opts := VisualizeOptions{
+		ExpectedOutput: func(state, input interface{}) interface{} {
+			return int64(state.(int))
+		},
+	}
+	mismatches := func(model Model) int {
+		_, info := CheckOperationsVerbose(model, ops, 0)
+		count := 0
+		for _, elem := range ComputeVisualizationDataWithOptions(model, info, opts).Partitions[0].History {
+			if elem.Mismatch {
+				count++
+			}
+		}
+		return count
+	}
+	model := registerModel
+	model.DescribeOperation = func(input, output interface{}) string {
+		return fmt.Sprint(output)
+	}
+	if count := mismatches(model); count != 2 {
+		t.Fatalf("expected 2 mismatches, got %d", count)
+	}
+	model.EqualOutput = func(output1, output2 interface{}) bool {
+		return fmt.Sprint(output1) == fmt.Sprint(output2)
+	}
+	if count := mismatches(model); count != 0 {
+		t.Fatalf("expected no mismatches, got %d", count)
+	}
+}
+
+func TestVisualizeHistory(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 5, 2, 30},
+	}
+	var out bytes.Buffer
+	err := VisualizeHistory(ops, func(input, output interface{}) string {
+		return fmt.Sprintf("op(%v) -> %v", input, output)
+	}, &out)
+	if err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	for _, s := range []string{`"HistoryOnly":true`, `op({true 0})`} {
+		if !strings.Contains(out.String(), s) {
+			t.Fatalf("expected visualization to contain %q", s)
+		}
+	}
+}
+
+func TestNemesis(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{-1, Nemesis{Description: "partition", Details: "{n1} {n2 n3}"}, 5, nil, 25},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30},
+	}
+	if !CheckOperations(kvModel, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+	// ordering constraints refer to indices in the original history
+	if CheckOperationsWithOrder(kvNoPartitionModel, []Operation{ops[2], ops[1], ops[0]}, [][2]int{{0, 2}}) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := ComputeVisualizationData(kvModel, info)
+	if len(data.Partitions) != 1 || len(data.Partitions[0].History) != 2 {
+		t.Fatalf("expected nemesis to be excluded from the history, got %v", data.Partitions)
+	}
+	expected := []VisualizationAnnotation{{
+		Tag:         "nemesis",
+		Start:       5,
+		End:         25,
+		Description: "partition",
+		Details:     "{n1} {n2 n3}",
+	}}
+	if !reflect.DeepEqual(expected, data.Annotations) {
+		t.Fatalf("expected annotations %v, got %v", expected, data.Annotations)
+	}
+	visualizeTempFile(t, kvModel, info)
+}
+
+func TestNemesisOrder(t *testing.T) {
+	// the write must precede the nemesis, which must precede the read, so
+	// the write precedes the read
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{-1, Nemesis{Description: "partition"}, 0, nil, 100},
+		{1, registerInput{true, 0}, 0, 0, 100},
+	}
+	res, _ := CheckOperationsOptions(registerModel, ops, CheckOptions{Order: [][2]int{{0, 1}, {1, 2}}})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	// likewise through a chain of nemeses
+	ops = []Operation{
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{-1, Nemesis{Description: "partition"}, 0, nil, 100},
+		{-1, Nemesis{Description: "heal"}, 0, nil, 100},
+		{1, registerInput{true, 0}, 0, 0, 100},
+	}
+	res, _ = CheckOperationsOptions(registerModel, ops, CheckOptions{Order: [][2]int{{0, 1}, {1, 2}, {2, 3}}})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	res, info := CheckOperationsOptions(registerModel, ops, CheckOptions{Order: [][2]int{{0, 1}, {2, 3}}, Verbose: true})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	// ids in the results skip the nemeses
+	if want := [][][]int{{{1, 0}}}; !reflect.DeepEqual(want, info.PartialLinearizations()) {
+		t.Fatalf("expected partial linearizations %v, got %v", want, info.PartialLinearizations())
+	}
+}
+
+func TestVisualizationWindow(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+		{0, registerInput{false, 2}, 40, 0, 50},
+		{1, registerInput{true, 0}, 60, 2, 70},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	info.AddAnnotations([]Annotation{
+		{Tag: "test", Start: 5, Description: "before"},
+		{Tag: "test", Start: 45, Description: "during"},
+	})
+	opts := VisualizeOptions{Window: &TimeWindow{From: 35, To: 55}}
+	data := ComputeVisualizationDataWithOptions(registerModel, info, opts)
+	partition := data.Partitions[0]
+	if len(partition.History) != 1 || partition.History[0].Start != 40 {
+		t.Fatalf("expected only the operation in the window, got %v", partition.History)
+	}
+	expected := [][]LinearizationStep{{{Index: 0, StateDescription: "2"}}}
+	if !reflect.DeepEqual(expected, partition.PartialLinearizations) {
+		t.Fatalf("expected linearizations %v, got %v", expected, partition.PartialLinearizations)
+	}
+	if !reflect.DeepEqual(map[int]int{0: 0}, partition.Largest) {
+		t.Fatalf("unexpected largest %v", partition.Largest)
+	}
+	if len(data.Annotations) != 1 || data.Annotations[0].Description != "during" {
+		t.Fatalf("expected only the annotation in the window, got %v", data.Annotations)
+	}
+	if window := data.Metadata["Window"]; window != "[35, 55], 3 operations omitted" {
+		t.Fatalf("unexpected window summary %q", window)
+	}
+	file, err := os.CreateTemp("", "*.html")
+	if err != nil {
+		t.Fatalf("failed to create temp file")
+	}
+	err = VisualizeWithOptions(registerModel, info, file, opts)
+	if err != nil {
+		t.Fatalf("visualization failed")
+	}
+	t.Logf("wrote visualization to %s", file.Name())
+}
@@ -0,0 +1,122 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A WriteOnceOp is the kind of an operation on a write-once register.
+type WriteOnceOp int
+
+const (
+	WriteOnceWrite WriteOnceOp = iota // write a value, if the register is undecided
+	WriteOnceRead                     // read the value, if the register is decided
+)
+
+// A WriteOnceInput is the input of an operation for the model returned by
+// [WriteOnceRegisterModel].
+type WriteOnceInput struct {
+	Op    WriteOnceOp
+	Value interface{} // value to write, for WriteOnceWrite
+}
+
+// A WriteOnceOutput is the output of an operation for the model returned by
+// [WriteOnceRegisterModel].
+type WriteOnceOutput struct {
+	Ok    bool        // whether the write succeeded, for WriteOnceWrite, or whether the register was decided, for WriteOnceRead
+	Value interface{} // value that was read, for WriteOnceRead
+}
+
+type writeOnceState struct {
+	decided bool
+	value   interface{}
+}
+
+// WriteOnceRegisterModel returns a model of a register that can be written
+// only once, such as the value decided by single-decree Paxos.
+//
+// The register starts out undecided. The first successful write decides its
+// value, and any later successful write is a violation, unless it writes the
+// same value, so retries of the write that decided the register are allowed.
+// A write that fails (i.e., its output's Ok is false) must have observed a
+// decided register, and it doesn't change the register. A read of an
+// undecided register returns an output with Ok set to false, and a read of a
+// decided register returns its value, with Ok set to true. Values are
+// compared using [reflect.DeepEqual].
+//
+// Inputs must be of type [WriteOnceInput] and outputs must be of type
+// [WriteOnceOutput].
+func WriteOnceRegisterModel() Model {
+	return Model{
+		Init: func() interface{} {
+			return writeOnceState{}
+		},
+		Step: func(state, input, output interface{}) (bool, interface{}) {
+			st := state.(writeOnceState)
+			inp := input.(WriteOnceInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(WriteOnceOutput)
+			switch inp.Op {
+			case WriteOnceWrite:
+				if pending {
+					// decides the register if it is undecided, and
+					// otherwise is rejected or retries the same value
+					if st.decided {
+						return true, state
+					}
+					return true, writeOnceState{decided: true, value: inp.Value}
+				}
+				if !out.Ok {
+					return st.decided, state
+				}
+				if !st.decided {
+					return true, writeOnceState{decided: true, value: inp.Value}
+				}
+				return reflect.DeepEqual(st.value, inp.Value), state
+			case WriteOnceRead:
+				if pending {
+					return true, state
+				}
+				if !out.Ok {
+					return !st.decided, state
+				}
+				return st.decided && reflect.DeepEqual(st.value, out.Value), state
+			}
+			return false, state
+		},
+		Equal: func(state1, state2 interface{}) bool {
+			return reflect.DeepEqual(state1, state2)
+		},
+		DescribeOperation: func(input, output interface{}) string {
+			inp := input.(WriteOnceInput)
+			_, pending := output.(PendingOutput)
+			out, _ := output.(WriteOnceOutput)
+			switch inp.Op {
+			case WriteOnceWrite:
+				if pending {
+					return fmt.Sprintf("write(%v) -> pending", inp.Value)
+				}
+				if !out.Ok {
+					return fmt.Sprintf("write(%v) -> rejected", inp.Value)
+				}
+				return fmt.Sprintf("write(%v)", inp.Value)
+			case WriteOnceRead:
+				if pending {
+					return "read() -> pending"
+				}
+				if !out.Ok {
+					return "read() -> undecided"
+				}
+				return fmt.Sprintf("read() -> %v", out.Value)
+			}
+			return "<invalid>"
+		},
+		DescribeState: func(state interface{}) string {
+			st := state.(writeOnceState)
+			if !st.decided {
+				return "undecided"
+			}
+			return fmt.Sprintf("%v", st.value)
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package porcupine
+
+import "testing"
+
+func TestWriteOnceRegisterModelCompetingWrites(t *testing.T) {
+	model := WriteOnceRegisterModel()
+	// three concurrent proposals, of which only "b" wins
+	ops := []Operation{
+		{0, WriteOnceInput{WriteOnceRead, nil}, 0, WriteOnceOutput{}, 5},
+		{1, WriteOnceInput{WriteOnceWrite, "a"}, 10, WriteOnceOutput{Ok: false}, 40},
+		{2, WriteOnceInput{WriteOnceWrite, "b"}, 10, WriteOnceOutput{Ok: true}, 40},
+		{3, WriteOnceInput{WriteOnceWrite, "c"}, 10, WriteOnceOutput{Ok: false}, 40},
+		{0, WriteOnceInput{WriteOnceRead, nil}, 15, WriteOnceOutput{}, 20},
+		{0, WriteOnceInput{WriteOnceRead, nil}, 50, WriteOnceOutput{Ok: true, Value: "b"}, 60},
+		// a retry of the winning write
+		{2, WriteOnceInput{WriteOnceWrite, "b"}, 70, WriteOnceOutput{Ok: true}, 80},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// two competing writes both succeed
+	ops = []Operation{
+		{1, WriteOnceInput{WriteOnceWrite, "a"}, 0, WriteOnceOutput{Ok: true}, 30},
+		{2, WriteOnceInput{WriteOnceWrite, "b"}, 10, WriteOnceOutput{Ok: true}, 40},
+	}
+	res, info = CheckOperationsVerbose(model, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	visualizeTempFile(t, model, info)
+
+	// the decided value changes between reads
+	ops = []Operation{
+		{1, WriteOnceInput{WriteOnceWrite, "a"}, 0, WriteOnceOutput{Ok: true}, 30},
+		{2, WriteOnceInput{WriteOnceWrite, "b"}, 0, WriteOnceOutput{Ok: false}, 40},
+		{0, WriteOnceInput{WriteOnceRead, nil}, 50, WriteOnceOutput{Ok: true, Value: "a"}, 60},
+		{0, WriteOnceInput{WriteOnceRead, nil}, 70, WriteOnceOutput{Ok: true, Value: "b"}, 80},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
+
+func TestWriteOnceRegisterModelUndecided(t *testing.T) {
+	model := WriteOnceRegisterModel()
+	// a write that fails although the register is undecided
+	ops := []Operation{
+		{1, WriteOnceInput{WriteOnceWrite, "a"}, 0, WriteOnceOutput{Ok: false}, 10},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+
+	// a read that observes an undecided register after a write succeeded
+	ops = []Operation{
+		{1, WriteOnceInput{WriteOnceWrite, "a"}, 0, WriteOnceOutput{Ok: true}, 10},
+		{0, WriteOnceInput{WriteOnceRead, nil}, 20, WriteOnceOutput{}, 30},
+	}
+	if CheckOperations(model, ops) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+
+	// the read is concurrent with the write
+	ops[1].Call = 5
+	if !CheckOperations(model, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+}